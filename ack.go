@@ -52,7 +52,14 @@ var dhcpAckValidation = []Validation{
 	ValidateMustNot(OptionDHCPMaxMsgSize),
 }
 
+// Validate checks the options of the reply, which depend on the type of
+// request (see RFC2131, table 3), and that 'yiaddr' is not a link-local
+// address, which a server must not lease.
 func (d *Ack) Validate() error {
+	if IsLinkLocal(d.GetYIAddr()) {
+		return ErrLinkLocalAddress
+	}
+
 	var err error
 
 	// Validation is subtly different based on type of request
@@ -70,7 +77,7 @@ func (d *Ack) Validate() error {
 	return Validate(d.Packet, dhcpAckValidation)
 }
 
-func (d *Ack) ToBytes() ([]byte, error) {
+func (d *Ack) toBytesOptions() *packetToBytesOptions {
 	opts := packetToBytesOptions{}
 
 	// Copy MaxMsgSize if set in the request
@@ -78,7 +85,22 @@ func (d *Ack) ToBytes() ([]byte, error) {
 		opts.maxLen = binary.BigEndian.Uint16(v)
 	}
 
-	return PacketToBytes(d.Packet, &opts)
+	// Write options in the order requested by the client
+	if l, ok := d.Message().GetOption(OptionParameterList); ok {
+		opts.parameterList = l
+	}
+
+	return &opts
+}
+
+func (d *Ack) ToBytes() ([]byte, error) {
+	return PacketToBytes(d.Packet, d.toBytesOptions())
+}
+
+// MarshalTo serializes the reply into buf, like ToBytes, and returns the
+// number of bytes written. It returns io.ErrShortBuffer if buf is too small.
+func (d *Ack) MarshalTo(buf []byte) (int, error) {
+	return packetMarshalTo(buf, d.Packet, d.toBytesOptions())
 }
 
 func (d *Ack) Message() *Packet {
@@ -1,6 +1,10 @@
 package dhcp4
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestAckOnRequestValidation(t *testing.T) {
 	testCase := replyValidationTestCase{
@@ -51,3 +55,68 @@ func TestAckOnInformValidation(t *testing.T) {
 
 	testCase.Test(t)
 }
+
+func TestAckWritesOptionsInRequestedOrder(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetOption(OptionParameterList, []byte{
+		byte(OptionDomainServer),
+		byte(OptionAddressTime),
+		byte(OptionSubnetMask),
+		byte(OptionRouter),
+	})
+
+	rep := CreateAck(&req)
+	rep.SetOption(OptionSubnetMask, []byte{255, 255, 255, 0})
+	rep.SetOption(OptionRouter, []byte{10, 0, 0, 1})
+	rep.SetOption(OptionDomainServer, []byte{10, 0, 0, 2})
+	rep.SetOption(OptionAddressTime, []byte{0, 0, 14, 16})
+	rep.SetOption(OptionDHCPServerID, []byte{10, 0, 0, 1})
+	rep.SetOption(OptionNTPServers, []byte{10, 0, 0, 3})
+
+	b, err := rep.ToBytes()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := []byte{
+		byte(OptionDomainServer), 4, 10, 0, 0, 2,
+		byte(OptionSubnetMask), 4, 255, 255, 255, 0,
+		byte(OptionRouter), 4, 10, 0, 0, 1,
+		byte(OptionDHCPMsgType), 1, byte(MessageTypeAck),
+		byte(OptionDHCPServerID), 4, 10, 0, 0, 1,
+		byte(OptionAddressTime), 4, 0, 0, 14, 16,
+		byte(OptionNTPServers), 4, 10, 0, 0, 3,
+		byte(OptionEnd),
+	}
+	assert.Equal(t, expected, b[240:])
+}
+
+func TestAckMarshalTo(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetOption(OptionParameterList, []byte{byte(OptionRouter), byte(OptionSubnetMask)})
+
+	rep := CreateAck(&req)
+	rep.SetOption(OptionSubnetMask, []byte{255, 255, 255, 0})
+	rep.SetOption(OptionRouter, []byte{10, 0, 0, 1})
+
+	expected, err := rep.ToBytes()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf := make([]byte, 1500)
+	n, err := rep.MarshalTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf[:n])
+
+	// Options are laid out in pooled buffers
+	if raceEnabled {
+		return
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		rep.MarshalTo(buf)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
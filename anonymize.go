@@ -0,0 +1,207 @@
+package dhcp4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net"
+)
+
+// AnonymizeOptions defines which fields of a packet are scrubbed by Anonymize.
+type AnonymizeOptions struct {
+	// Key is mixed into every pseudonym. Pseudonyms are stable for a given key,
+	// so the same client maps to the same pseudonym across a capture. Without a
+	// key, hardware addresses can be recovered by brute force.
+	Key []byte
+
+	// HardwareAddr scrubs the client hardware address (the `chaddr` field).
+	HardwareAddr bool
+
+	// ClientID scrubs the Client Identifier option (61).
+	ClientID bool
+
+	// Hostname scrubs the Hostname (12) and Client FQDN (81) options.
+	Hostname bool
+
+	// Addresses rewrites the address fields in the fixed header and the address
+	// valued options into the documentation range 192.0.2.0/24 (RFC5737).
+	Addresses bool
+}
+
+// DefaultAnonymizeOptions scrubs all client identifying fields, but leaves
+// addresses untouched.
+var DefaultAnonymizeOptions = AnonymizeOptions{
+	HardwareAddr: true,
+	ClientID:     true,
+	Hostname:     true,
+}
+
+// anonymizeAddressOptions lists the options holding one or more IPv4
+// addresses that are rewritten when AnonymizeOptions.Addresses is set.
+var anonymizeAddressOptions = map[Option]bool{
+	OptionRouter:           true,
+	OptionTimeServer:       true,
+	OptionNameServer:       true,
+	OptionDomainServer:     true,
+	OptionLogServer:        true,
+	OptionBroadcastAddress: true,
+	OptionNISServers:       true,
+	OptionNTPServers:       true,
+	OptionAddressRequest:   true,
+	OptionDHCPServerID:     true,
+}
+
+// Anonymize returns a copy of the packet pointed to by p with the fields
+// selected in opts replaced by pseudonyms. Values are rewritten in place in
+// the wire-level representation, retaining their length, so the anonymized
+// packet has exactly the same structure as the original one.
+func Anonymize(p *Packet, opts AnonymizeOptions) *Packet {
+	a := anonymizer{opts: opts}
+
+	q := Packet{
+		RawPacket: make(RawPacket, len(p.RawPacket)),
+	}
+
+	copy(q.RawPacket, p.RawPacket)
+
+	if len(q.RawPacket) >= 240 {
+		a.scrubHeader(q.RawPacket)
+
+		// Walk the options in every section they may have been stored in
+		overload := p.OptionMap[OptionOverload]
+		a.scrubOptions(q.Options())
+		if len(overload) > 0 && overload[0]&0x1 != 0 {
+			a.scrubOptions(q.File())
+		}
+		if len(overload) > 0 && overload[0]&0x2 != 0 {
+			a.scrubOptions(q.SName())
+		}
+	}
+
+	// The original packet parsed fine, and the structure is left intact.
+	q.OptionMap, _ = q.ParseOptions()
+	if q.OptionMap == nil {
+		q.OptionMap = make(OptionMap)
+	}
+
+	return &q
+}
+
+type anonymizer struct {
+	opts AnonymizeOptions
+}
+
+// sum returns a keyed digest of b, that is used as source for pseudonyms.
+func (a *anonymizer) sum(b []byte) []byte {
+	h := hmac.New(sha256.New, a.opts.Key)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func (a *anonymizer) scrubHeader(p RawPacket) {
+	if a.opts.HardwareAddr {
+		hlen := p.GetHLen()
+		if hlen > 16 {
+			hlen = 16
+		}
+		a.scrubHardwareAddr(p.CHAddr()[0:hlen])
+	}
+
+	if a.opts.Addresses {
+		a.scrubIPs(p.CIAddr())
+		a.scrubIPs(p.YIAddr())
+		a.scrubIPs(p.SIAddr())
+		a.scrubIPs(p.GIAddr())
+	}
+}
+
+func (a *anonymizer) scrubOptions(b []byte) {
+	walkOptions(b, func(o Option, v []byte) {
+		switch {
+		case o == OptionClientID && a.opts.ClientID:
+			// Hardware type 1 means the remainder is a MAC address; scrub it the
+			// same way as `chaddr` so both still match after anonymization.
+			if len(v) > 1 && v[0] == 1 {
+				a.scrubHardwareAddr(v[1:])
+			} else {
+				a.scrubBytes(v)
+			}
+		case o == OptionHostname && a.opts.Hostname:
+			a.scrubName(v)
+		case o == OptionClientFQDN && a.opts.Hostname:
+			// Flags, RCODE1 and RCODE2 precede the domain name (RFC4702, section 2).
+			if len(v) > 3 {
+				if v[0]&0x04 != 0 {
+					a.scrubWireName(v[3:])
+				} else {
+					a.scrubName(v[3:])
+				}
+			}
+		case anonymizeAddressOptions[o] && a.opts.Addresses:
+			a.scrubIPs(v)
+		}
+	})
+}
+
+// scrubHardwareAddr replaces b with a locally administered unicast address.
+func (a *anonymizer) scrubHardwareAddr(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	copy(b, a.sum(b))
+	b[0] = (b[0] | 0x02) &^ 0x01
+}
+
+// scrubBytes replaces b with pseudo random bytes.
+func (a *anonymizer) scrubBytes(b []byte) {
+	src := append([]byte(nil), b...)
+	for i := 0; i < len(b); i += sha256.Size {
+		copy(b[i:], a.sum(append(src, byte(i/sha256.Size))))
+	}
+}
+
+// scrubName replaces the letters and digits in b, retaining any separators.
+func (a *anonymizer) scrubName(b []byte) {
+	const (
+		letters = "abcdefghijklmnopqrstuvwxyz"
+		digits  = "0123456789"
+	)
+
+	s := a.sum(b)
+	for i, c := range b {
+		x := s[i%len(s)] + byte(i/len(s))
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			b[i] = letters[int(x)%len(letters)]
+		case c >= '0' && c <= '9':
+			b[i] = digits[int(x)%len(digits)]
+		}
+	}
+}
+
+// scrubWireName scrubs a domain name in DNS wire format, leaving the label
+// length octets untouched.
+func (a *anonymizer) scrubWireName(b []byte) {
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 || n >= len(b) {
+			return
+		}
+		a.scrubName(b[1 : 1+n])
+		b = b[1+n:]
+	}
+}
+
+// scrubIPs rewrites every IPv4 address in b into 192.0.2.0/24. The unspecified
+// and limited broadcast addresses carry meaning and are left as is.
+func (a *anonymizer) scrubIPs(b []byte) {
+	for i := 0; i+4 <= len(b); i += 4 {
+		ip := net.IP(b[i : i+4])
+		if ip.Equal(net.IPv4zero) || ip.Equal(net.IPv4bcast) {
+			continue
+		}
+
+		s := a.sum(ip)
+		copy(ip, []byte{192, 0, 2, 1 + s[0]%254})
+	}
+}
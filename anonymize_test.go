@@ -0,0 +1,100 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func anonymizeTestPacket(t *testing.T) *Packet {
+	mac := []byte{0x00, 0x0c, 0x29, 0x12, 0x34, 0x56}
+
+	p := NewPacket(BootRequest)
+	p.HType()[0] = 1
+	p.HLen()[0] = 6
+	copy(p.CHAddr(), mac)
+	p.SetCIAddr(net.IP{10, 0, 0, 5})
+	p.SetGIAddr(net.IP{10, 0, 0, 1})
+	p.SetMessageType(MessageTypeRequest)
+	p.SetOption(OptionClientID, append([]byte{1}, mac...))
+	p.SetString(OptionHostname, "alices-laptop")
+	p.SetIP(OptionAddressRequest, net.IP{10, 0, 0, 5})
+
+	b, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	q, err := PacketFromBytes(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return &q
+}
+
+func TestAnonymizeScrubsClientFields(t *testing.T) {
+	p := anonymizeTestPacket(t)
+	orig := append(RawPacket(nil), p.RawPacket...)
+
+	q := Anonymize(p, DefaultAnonymizeOptions)
+
+	// The original packet is left alone
+	assert.Equal(t, orig, p.RawPacket)
+
+	// The structure is retained
+	assert.Equal(t, len(p.RawPacket), len(q.RawPacket))
+	assert.Equal(t, p.GetSortedOptions(), q.GetSortedOptions())
+	assert.Equal(t, MessageTypeRequest, q.GetMessageType())
+
+	// Hardware address is scrubbed, and the client identifier still matches it
+	mac := q.GetCHAddr()
+	assert.Len(t, mac, 6)
+	assert.NotEqual(t, p.GetCHAddr(), mac)
+	assert.Equal(t, byte(0x02), mac[0]&0x03)
+	assert.Equal(t, append([]byte{1}, mac...), q.OptionMap[OptionClientID])
+
+	// Hostname is scrubbed, retaining its length and separators
+	hostname, _ := q.GetString(OptionHostname)
+	assert.Len(t, hostname, len("alices-laptop"))
+	assert.NotEqual(t, "alices-laptop", hostname)
+	assert.Equal(t, byte('-'), hostname[6])
+
+	// Addresses are left alone by default
+	assert.Equal(t, net.IP{10, 0, 0, 5}, q.GetCIAddr())
+	assert.Equal(t, net.IP{10, 0, 0, 1}, q.GetGIAddr())
+}
+
+func TestAnonymizeIsStable(t *testing.T) {
+	p := anonymizeTestPacket(t)
+	opts := AnonymizeOptions{Key: []byte("secret"), HardwareAddr: true}
+
+	q1 := Anonymize(p, opts)
+	q2 := Anonymize(p, opts)
+	assert.Equal(t, q1.RawPacket, q2.RawPacket)
+
+	opts.Key = []byte("other secret")
+	q3 := Anonymize(p, opts)
+	assert.NotEqual(t, q1.GetCHAddr(), q3.GetCHAddr())
+}
+
+func TestAnonymizeAddresses(t *testing.T) {
+	p := anonymizeTestPacket(t)
+	q := Anonymize(p, AnonymizeOptions{Addresses: true})
+
+	doc := net.IPNet{IP: net.IP{192, 0, 2, 0}, Mask: net.CIDRMask(24, 32)}
+	assert.True(t, doc.Contains(q.GetCIAddr()))
+	assert.True(t, doc.Contains(q.GetGIAddr()))
+
+	// Unset addresses remain unset
+	assert.Equal(t, net.IP{0, 0, 0, 0}, q.GetYIAddr())
+
+	// The same address maps to the same pseudonym everywhere
+	ip, ok := q.GetIP(OptionAddressRequest)
+	assert.True(t, ok)
+	assert.True(t, ip.Equal(q.GetCIAddr()))
+
+	// Other fields are left alone
+	assert.Equal(t, p.GetCHAddr(), q.GetCHAddr())
+}
@@ -0,0 +1,101 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticationRoundTrip(t *testing.T) {
+	om := make(OptionMap)
+
+	a := Authentication{
+		Protocol:        AuthProtocolDelayed,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: 0x0102030405060708,
+		Information:     []byte("info"),
+	}
+
+	om.SetAuthentication(a)
+
+	b, ok := om.GetAuthentication()
+	assert.True(t, ok)
+	assert.Equal(t, a, b)
+}
+
+func TestReconfigureKey(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	// The server hands out the key in its DHCPACK
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	ack := CreateAck(&req)
+	assert.Equal(t, ErrInvalidReconfKey, ack.SetReconfigureKey([]byte("short")))
+	assert.NoError(t, ack.SetReconfigureKey(key))
+
+	b, err := PacketToBytes(ack.Packet, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := PacketFromBytes(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	clientKey, ok := p.GetReconfigureKey()
+	assert.True(t, ok)
+	assert.Equal(t, key, clientKey)
+
+	// The server later sends an authenticated DHCPFORCERENEW
+	fr := NewPacket(BootReply)
+	fr.SetMessageType(MessageTypeForceRenew)
+	fr.SetReconfigureDigest(1)
+
+	b, err = PacketToBytes(fr, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, SignReconfigureKey(b, key))
+
+	// Relay agents may change `hops` and `giaddr` without breaking the digest
+	RawPacket(b).Hops()[0] = 1
+	copy(RawPacket(b).GIAddr(), []byte{10, 0, 0, 1})
+
+	p, err = PacketFromBytes(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, p.VerifyReconfigureKey(clientKey))
+	assert.Equal(t, ErrAuthentication, p.VerifyReconfigureKey([]byte("fedcba9876543210")))
+
+	a, ok := p.GetAuthentication()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), a.ReplayDetection)
+
+	// Tampering with the message invalidates the digest
+	copy(p.YIAddr(), []byte{10, 0, 0, 2})
+	assert.Equal(t, ErrAuthentication, p.VerifyReconfigureKey(clientKey))
+}
+
+func TestVerifyReconfigureKeyWithoutAuthentication(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	p := NewPacket(BootReply)
+	p.SetMessageType(MessageTypeForceRenew)
+
+	b, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err = PacketFromBytes(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, ErrNoAuthentication, p.VerifyReconfigureKey(key))
+	assert.Equal(t, ErrNoAuthentication, SignReconfigureKey(b, key))
+}
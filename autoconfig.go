@@ -0,0 +1,32 @@
+package dhcp4
+
+// Values of the Auto-Configure option (RFC2563, section 2).
+const (
+	DoNotAutoConfigure = uint8(0)
+	AutoConfigure      = uint8(1)
+)
+
+// AutoConfigure gets the Auto-Configure option (116). A client sets it to
+// AutoConfigure if it is willing to configure a link-local address when it
+// gets no lease; a server replies with DoNotAutoConfigure to prevent that.
+func (om OptionMap) AutoConfigure() (uint8, bool) {
+	return om.GetUint8(OptionAutoConfig)
+}
+
+// SetAutoConfigure sets the Auto-Configure option (116).
+func (om OptionMap) SetAutoConfigure(v uint8) error {
+	return om.SetUint8(OptionAutoConfig, v)
+}
+
+// CreateDoNotAutoConfigure returns the DHCPOFFER to send in response to the
+// DHCPDISCOVER msg if the server has no address to offer, but the client must
+// not configure a link-local address. It offers no address ('yiaddr' is
+// 0.0.0.0) and sets the Auto-Configure option to DoNotAutoConfigure (RFC2563,
+// section 3). The server should only send it if the client sent the
+// Auto-Configure option. As for any DHCPOFFER, the server must set the Server
+// Identifier and IP Address Lease Time options.
+func CreateDoNotAutoConfigure(msg *Packet) Offer {
+	rep := CreateOffer(msg)
+	rep.SetAutoConfigure(DoNotAutoConfigure)
+	return rep
+}
@@ -0,0 +1,32 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoConfigure(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	_, ok := req.AutoConfigure()
+	assert.False(t, ok)
+
+	assert.NoError(t, req.SetAutoConfigure(AutoConfigure))
+	v, ok := req.AutoConfigure()
+	assert.True(t, ok)
+	assert.Equal(t, AutoConfigure, v)
+
+	rep := CreateDoNotAutoConfigure(&req)
+	rep.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	rep.SetDuration(OptionAddressTime, time.Hour)
+	assert.NoError(t, rep.Validate())
+
+	assert.Equal(t, net.IPv4zero.To4(), rep.GetYIAddr().To4())
+	v, ok = rep.AutoConfigure()
+	assert.True(t, ok)
+	assert.Equal(t, DoNotAutoConfigure, v)
+}
@@ -0,0 +1,106 @@
+package dhcp4
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrNoBootFile = errors.New("dhcp4: no boot file for client architecture")
+)
+
+// Client system architecture types of the Client System Architecture option
+// (93), from the IANA "Processor Architecture Types" registry. RFC4578 had
+// types 7 and 9 swapped (erratum 4624), so x64 UEFI firmware sends either.
+const (
+	ArchX86BIOS  = uint16(0)
+	ArchEFIIA32  = uint16(6)
+	ArchEFIx64   = uint16(7)
+	ArchEFIBC    = uint16(9)
+	ArchEFIARM32 = uint16(10)
+	ArchEFIARM64 = uint16(11)
+)
+
+// DefaultBootFiles is the boot file mapping SelectBootFile uses if the caller
+// supplies none: PXELINUX for BIOS clients, and GRUB for x64 UEFI clients.
+var DefaultBootFiles = map[uint16]string{
+	ArchX86BIOS: "pxelinux.0",
+	ArchEFIx64:  "grubx64.efi",
+	ArchEFIBC:   "grubx64.efi",
+}
+
+// GetClientArch gets the architecture types of the Client System Architecture
+// option (93), in the client's order of preference. It fails if the option
+// is empty, or its length is odd.
+func (om OptionMap) GetClientArch() ([]uint16, bool) {
+	v, ok := om.GetOption(OptionClientSystem)
+	if !ok || len(v) == 0 || len(v)%2 != 0 {
+		return nil, false
+	}
+
+	arch := make([]uint16, 0, len(v)/2)
+	for ; len(v) > 0; v = v[2:] {
+		arch = append(arch, binary.BigEndian.Uint16(v))
+	}
+
+	return arch, true
+}
+
+// SetClientArch sets the Client System Architecture option (93).
+func (om OptionMap) SetClientArch(arch ...uint16) {
+	v := make([]byte, 2*len(arch))
+	for i, a := range arch {
+		binary.BigEndian.PutUint16(v[2*i:], a)
+	}
+
+	om.SetOption(OptionClientSystem, v)
+}
+
+// GetBootFile gets the Bootfile Name option (67).
+func (om OptionMap) GetBootFile() (string, bool) {
+	return om.GetString(OptionBootfileName)
+}
+
+// SetBootFile sets the Bootfile Name option (67). The name must not be empty.
+// Clients that only support BOOTP read the 'file' field instead.
+func (om OptionMap) SetBootFile(name string) error {
+	return om.SetString(OptionBootfileName, name)
+}
+
+// SelectBootFile returns the boot file for a client of architecture types
+// arch (see GetClientArch): the file mapping has for the first type, in the
+// client's order of preference, that it has a file for. Clients without the
+// Client System Architecture option are BIOS clients (ArchX86BIOS). If mapping
+// is nil, DefaultBootFiles is used.
+func SelectBootFile(arch []uint16, mapping map[uint16]string) (string, bool) {
+	if mapping == nil {
+		mapping = DefaultBootFiles
+	}
+
+	if len(arch) == 0 {
+		arch = []uint16{ArchX86BIOS}
+	}
+
+	for _, a := range arch {
+		if name, ok := mapping[a]; ok {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// SetBootFileFor sets the Bootfile Name option of reply r to the boot file
+// SelectBootFile selects from mapping for the architecture of the client. It
+// returns the selected file, or ErrNoBootFile if mapping has none for the
+// client.
+func SetBootFileFor(r Reply, mapping map[uint16]string) (string, error) {
+	arch, _ := r.Message().GetClientArch()
+
+	name, ok := SelectBootFile(arch, mapping)
+	if !ok {
+		return "", ErrNoBootFile
+	}
+
+	return name, r.SetString(OptionBootfileName, name)
+}
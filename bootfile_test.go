@@ -0,0 +1,75 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientArch(t *testing.T) {
+	om := make(OptionMap)
+	_, ok := om.GetClientArch()
+	assert.False(t, ok)
+
+	om.SetClientArch(ArchEFIx64, ArchEFIBC)
+	assert.Equal(t, []byte{0, 7, 0, 9}, om[OptionClientSystem])
+
+	arch, ok := om.GetClientArch()
+	assert.True(t, ok)
+	assert.Equal(t, []uint16{ArchEFIx64, ArchEFIBC}, arch)
+
+	om.SetOption(OptionClientSystem, []byte{0, 7, 0})
+	_, ok = om.GetClientArch()
+	assert.False(t, ok)
+}
+
+func TestSelectBootFile(t *testing.T) {
+	name, ok := SelectBootFile([]uint16{ArchX86BIOS}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "pxelinux.0", name)
+
+	name, ok = SelectBootFile([]uint16{ArchEFIBC}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "grubx64.efi", name)
+
+	// Without architecture, a BIOS client
+	name, ok = SelectBootFile(nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "pxelinux.0", name)
+
+	_, ok = SelectBootFile([]uint16{ArchEFIARM64}, nil)
+	assert.False(t, ok)
+
+	// The client's preference order
+	mapping := map[uint16]string{ArchEFIARM64: "grubaa64.efi", ArchEFIx64: "shimx64.efi"}
+	name, ok = SelectBootFile([]uint16{ArchEFIARM32, ArchEFIx64, ArchEFIARM64}, mapping)
+	assert.True(t, ok)
+	assert.Equal(t, "shimx64.efi", name)
+
+	_, ok = SelectBootFile(nil, mapping)
+	assert.False(t, ok)
+}
+
+func TestSetBootFileFor(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	req.SetClientArch(ArchEFIx64)
+
+	offer := CreateOffer(&req)
+	name, err := SetBootFileFor(&offer, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "grubx64.efi", name)
+
+	file, ok := offer.GetBootFile()
+	assert.True(t, ok)
+	assert.Equal(t, "grubx64.efi", file)
+
+	req.SetClientArch(ArchEFIARM64)
+	offer = CreateOffer(&req)
+	_, err = SetBootFileFor(&offer, nil)
+	assert.Equal(t, ErrNoBootFile, err)
+	_, ok = offer.GetBootFile()
+	assert.False(t, ok)
+
+	assert.Error(t, offer.SetBootFile(""))
+}
@@ -0,0 +1,44 @@
+package dhcp4
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidPath = errors.New("dhcp4: path contains NUL")
+)
+
+// GetRootPath gets the Root Path option (17): the path of the client's root
+// disk, e.g. an NFS export "10.0.0.1:/srv/root", or an iSCSI target
+// "iscsi:10.0.0.1::::iqn.2001-04.com.example:root" (RFC4173).
+func (om OptionMap) GetRootPath() (string, bool) {
+	return om.GetString(OptionRootPath)
+}
+
+// SetRootPath sets the Root Path option (17). The path is free-form; it
+// returns ErrInvalidPath if it contains a NUL character.
+func (om OptionMap) SetRootPath(path string) error {
+	return om.setPath(OptionRootPath, path)
+}
+
+// GetExtensionsPath gets the Extensions Path option (18): the path of a file,
+// retrievable with TFTP, holding more options for the client in the format of
+// the options field (RFC2132, section 3.20).
+func (om OptionMap) GetExtensionsPath() (string, bool) {
+	return om.GetString(OptionExtensionFile)
+}
+
+// SetExtensionsPath sets the Extensions Path option (18). It returns
+// ErrInvalidPath if the path contains a NUL character.
+func (om OptionMap) SetExtensionsPath(path string) error {
+	return om.setPath(OptionExtensionFile, path)
+}
+
+func (om OptionMap) setPath(o Option, path string) error {
+	if strings.IndexByte(path, 0) != -1 {
+		return ErrInvalidPath
+	}
+
+	return om.SetString(o, path)
+}
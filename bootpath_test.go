@@ -0,0 +1,29 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionMapBootPaths(t *testing.T) {
+	om := make(OptionMap)
+
+	_, ok := om.GetRootPath()
+	assert.False(t, ok)
+
+	assert.NoError(t, om.SetRootPath("iscsi:10.0.0.1::::iqn.2001-04.com.example:root"))
+	v, ok := om.GetRootPath()
+	assert.True(t, ok)
+	assert.Equal(t, "iscsi:10.0.0.1::::iqn.2001-04.com.example:root", v)
+
+	assert.NoError(t, om.SetExtensionsPath("/tftp/ext"))
+	v, ok = om.GetExtensionsPath()
+	assert.True(t, ok)
+	assert.Equal(t, "/tftp/ext", v)
+
+	assert.Equal(t, ErrInvalidPath, om.SetRootPath("/srv\x00root"))
+	assert.Equal(t, ErrInvalidPath, om.SetExtensionsPath("\x00"))
+	v, _ = om.GetRootPath()
+	assert.Equal(t, "iscsi:10.0.0.1::::iqn.2001-04.com.example:root", v)
+}
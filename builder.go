@@ -0,0 +1,154 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+var (
+	ErrNoServerID       = errors.New("dhcp4: reply has no server identifier")
+	ErrInvalidReplyType = errors.New("dhcp4: invalid reply message type")
+)
+
+// ReplyBuilder assembles a reply to a request. Its methods can be chained,
+// and the first error is returned by Build.
+type ReplyBuilder struct {
+	msg *Packet
+	typ MessageType
+
+	yiaddr   net.IP
+	serverID net.IP
+	opts     OptionMap
+
+	err error
+}
+
+// NewReplyBuilder returns a builder for a reply to req. The reply type
+// defaults to DHCPOFFER for a DHCPDISCOVER, and DHCPACK otherwise.
+func NewReplyBuilder(req *Packet) *ReplyBuilder {
+	b := &ReplyBuilder{
+		msg:  req,
+		typ:  MessageTypeAck,
+		opts: make(OptionMap),
+	}
+
+	if req.GetMessageType() == MessageTypeDiscover {
+		b.typ = MessageTypeOffer
+	}
+
+	return b
+}
+
+func (b *ReplyBuilder) check(err error) *ReplyBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+
+	return b
+}
+
+// Type sets the message type of the reply: DHCPOFFER, DHCPACK or DHCPNAK.
+func (b *ReplyBuilder) Type(t MessageType) *ReplyBuilder {
+	switch t {
+	case MessageTypeOffer, MessageTypeAck, MessageTypeNak:
+		b.typ = t
+		return b
+	}
+
+	return b.check(ErrInvalidReplyType)
+}
+
+// YourIP sets the address offered or assigned to the client ('yiaddr'). A
+// link-local address fails validation with ErrLinkLocalAddress.
+func (b *ReplyBuilder) YourIP(ip net.IP) *ReplyBuilder {
+	if ip.To4() == nil {
+		return b.check(ErrInvalidAddress)
+	}
+
+	b.yiaddr = ip
+	return b
+}
+
+// ServerID sets the Server Identifier option.
+func (b *ReplyBuilder) ServerID(ip net.IP) *ReplyBuilder {
+	if ip.To4() == nil {
+		return b.check(ErrInvalidAddress)
+	}
+
+	b.serverID = ip
+	return b
+}
+
+// Mask sets the Subnet Mask option, see OptionMap.SetSubnetMask.
+func (b *ReplyBuilder) Mask(mask net.IPMask) *ReplyBuilder {
+	return b.check(b.opts.SetSubnetMask(mask))
+}
+
+// Router appends to the Router option.
+func (b *ReplyBuilder) Router(ips ...net.IP) *ReplyBuilder {
+	return b.check(b.opts.AddRouter(ips...))
+}
+
+// DNS appends to the Domain Name Server option.
+func (b *ReplyBuilder) DNS(ips ...net.IP) *ReplyBuilder {
+	return b.check(b.opts.AddDNS(ips...))
+}
+
+// LeaseTime sets the IP Address Lease Time option.
+func (b *ReplyBuilder) LeaseTime(d time.Duration) *ReplyBuilder {
+	return b.check(b.opts.SetDuration(OptionAddressTime, d))
+}
+
+// Option sets an arbitrary option.
+func (b *ReplyBuilder) Option(o Option, v []byte) *ReplyBuilder {
+	b.opts.SetOption(o, v)
+	return b
+}
+
+// Build returns the validated reply. Fields copied from the request ('xid',
+// 'flags', 'giaddr', 'chaddr') are set by NewReply. It returns the first error
+// of the chained methods, ErrNoServerID if no server identifier was set, or
+// the validation error of the reply.
+func (b *ReplyBuilder) Build() (Reply, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if b.serverID == nil {
+		return nil, ErrNoServerID
+	}
+
+	var r Reply
+	switch b.typ {
+	case MessageTypeOffer:
+		rep := CreateOffer(b.msg)
+		r = &rep
+	case MessageTypeAck:
+		rep := CreateAck(b.msg)
+		r = &rep
+	case MessageTypeNak:
+		rep := CreateNak(b.msg)
+		r = &rep
+	}
+
+	if b.yiaddr != nil {
+		r.SetYIAddr(b.yiaddr)
+	}
+
+	sid := b.serverID
+	if ip, ok := b.msg.ServerIDOverride(); ok {
+		sid = ip
+	}
+
+	r.SetIP(OptionDHCPServerID, sid)
+	for o, v := range b.opts {
+		r.SetOption(o, v)
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,86 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyBuilder(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetCHAddr(net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	copy(req.XID(), []byte{1, 2, 3, 4})
+	req.SetGIAddr(net.IPv4(10, 0, 1, 1))
+
+	r, err := NewReplyBuilder(&req).
+		Type(MessageTypeAck).
+		YourIP(net.IPv4(10, 0, 1, 5)).
+		Mask(net.CIDRMask(24, 32)).
+		Router(net.IPv4(10, 0, 1, 1)).
+		DNS(net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 3)).
+		LeaseTime(time.Hour).
+		ServerID(net.IPv4(10, 0, 0, 1)).
+		Build()
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rep := r.Reply()
+	assert.Equal(t, MessageTypeAck, rep.GetMessageType())
+	assert.Equal(t, net.IP{10, 0, 1, 5}, rep.GetYIAddr())
+	assert.Equal(t, req.XID(), rep.XID())
+	assert.Equal(t, req.GetCHAddr(), rep.GetCHAddr())
+	assert.Equal(t, req.GetGIAddr(), rep.GetGIAddr())
+
+	mask, _ := rep.GetIP(OptionSubnetMask)
+	assert.Equal(t, net.IPv4(255, 255, 255, 0), mask)
+	dns, _ := rep.GetIPs(OptionDomainServer)
+	assert.Len(t, dns, 2)
+	sid, _ := rep.GetIP(OptionDHCPServerID)
+	assert.Equal(t, net.IPv4(10, 0, 0, 1), sid)
+}
+
+func TestReplyBuilderDefaultType(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	r, err := NewReplyBuilder(&req).LeaseTime(time.Hour).ServerID(net.IPv4(10, 0, 0, 1)).Build()
+	if assert.NoError(t, err) {
+		assert.Equal(t, MessageTypeOffer, r.Reply().GetMessageType())
+	}
+}
+
+func TestReplyBuilderErrors(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+
+	_, err := NewReplyBuilder(&req).LeaseTime(time.Hour).Build()
+	assert.Equal(t, ErrNoServerID, err)
+
+	_, err = NewReplyBuilder(&req).Type(MessageTypeDiscover).ServerID(net.IPv4(10, 0, 0, 1)).Build()
+	assert.Equal(t, ErrInvalidReplyType, err)
+
+	// The first error is returned
+	_, err = NewReplyBuilder(&req).YourIP(net.ParseIP("::1")).DNS(net.ParseIP("::2")).Build()
+	assert.Equal(t, ErrInvalidAddress, err)
+
+	// Validation errors, e.g. missing lease time on DHCPACK
+	_, err = NewReplyBuilder(&req).ServerID(net.IPv4(10, 0, 0, 1)).Build()
+	assert.Equal(t, &ValidationError{Option: OptionAddressTime, MustHave: true}, err)
+}
+
+func TestReplyBuilderRejectsLinkLocal(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+
+	_, err := NewReplyBuilder(&req).
+		YourIP(net.IPv4(169, 254, 0, 5)).
+		LeaseTime(time.Hour).
+		ServerID(net.IPv4(10, 0, 0, 1)).
+		Build()
+	assert.Equal(t, ErrLinkLocalAddress, err)
+}
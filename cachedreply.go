@@ -0,0 +1,35 @@
+package dhcp4
+
+import "net"
+
+// CachedReply is a serialized reply with its destination, as sent by the
+// server, so that it can be sent again without rebuilding it, e.g. when a
+// client retransmits a DHCPREQUEST that was already answered. Caches should
+// key replies by CorrelationID, and drop them once the transaction is over,
+// e.g. after the client's retransmission timeout.
+type CachedReply struct {
+	// CorrelationID identifies the exchange of the reply, see
+	// RawPacket.CorrelationID.
+	CorrelationID string
+
+	Bytes   []byte
+	Addr    net.UDPAddr
+	IfIndex int
+
+	// Src is the source address the reply was sent from, if one is set for
+	// the interface (see Server.SetInterfaceSource).
+	Src net.IP
+}
+
+// CachingReplyWriter is implemented by a ReplyWriter that can return the
+// replies it writes for retransmission, like the one the server passes to
+// handlers.
+type CachingReplyWriter interface {
+	WriteReplyCached(r Reply) (*CachedReply, error)
+}
+
+// Resend sends the cached reply again over pw, to the same destination and
+// network interface.
+func (c *CachedReply) Resend(pw PacketWriter) error {
+	return writeTo(pw, c.Bytes, &c.Addr, c.IfIndex, c.Src)
+}
@@ -0,0 +1,57 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReplyWriterCachedReply(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetCHAddr(net.HardwareAddr{0, 5, 0x3c, 4, 0x8d, 0x59})
+
+	ack := CreateAck(&req)
+	ack.SetDuration(OptionAddressTime, time.Hour)
+	ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	var sent [][]byte
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, 2).Return(0, nil).Run(func(args mock.Arguments) {
+		sent = append(sent, append([]byte(nil), args.Get(0).([]byte)...))
+	})
+
+	var w ReplyWriter = &replyWriter{
+		pw:      pw,
+		srv:     &Server{},
+		addr:    net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: ClientPort},
+		ifindex: 2,
+	}
+
+	c, err := w.(CachingReplyWriter).WriteReplyCached(&ack)
+	if !assert.NoError(t, err) || !assert.NotNil(t, c) {
+		return
+	}
+	assert.Equal(t, req.CorrelationID(), c.CorrelationID)
+	assert.Equal(t, net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: ClientPort}, c.Addr)
+	assert.Equal(t, 2, c.IfIndex)
+	assert.Nil(t, c.Src)
+
+	// The cached bytes don't alias the pooled buffer
+	assert.NoError(t, w.WriteReply(&ack))
+	assert.NoError(t, c.Resend(pw))
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, sent[0], c.Bytes)
+		assert.Equal(t, sent[0], sent[2])
+	}
+	pw.AssertNumberOfCalls(t, "WriteTo", 3)
+
+	// Nothing to cache for dropped replies
+	w = &replyWriter{pw: pw, srv: &Server{ReplyInterceptor: func(req *Packet, r Reply) Reply { return nil }}}
+	c, err = w.(CachingReplyWriter).WriteReplyCached(&ack)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
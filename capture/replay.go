@@ -0,0 +1,115 @@
+// Package capture replays DHCP traffic from packet captures through a
+// dhcp4.Handler. It is kept separate from the dhcp4 package so that package
+// doesn't depend on gopacket.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+
+	"github.com/betawaffle/dhcp4-go"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// pcapngMagic is the block type of the pcapng Section Header Block.
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+type packetDataSource interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// ReplayPcap reads the .pcap or .pcapng file at path, and feeds the DHCP
+// packets it contains (UDP, port 67 or 68) through the same serve logic as
+// dhcp4.Serve, calling the handler h. Replies written by the handler are
+// discarded. It returns once all packets have been replayed.
+func ReplayPcap(path string, h dhcp4.Handler) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	src, err := newPacketDataSource(f)
+	if err != nil {
+		return err
+	}
+
+	err = dhcp4.Serve(&replayConn{src: src}, h)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func newPacketDataSource(r io.Reader) (packetDataSource, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, pcapngMagic) {
+		return pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	}
+
+	return pcapgo.NewReader(br)
+}
+
+// replayConn implements dhcp4.PacketConn on top of a packet capture.
+type replayConn struct {
+	src packetDataSource
+}
+
+// ReadFrom returns the payload of the next DHCP packet in the capture. The
+// interface index is not known, and always returned as 0.
+func (c *replayConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
+	for {
+		data, _, err := c.src.ReadPacketData()
+		if err != nil {
+			return 0, nil, -1, err
+		}
+
+		pkt := gopacket.NewPacket(data, c.src.LinkType(), gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+
+		ip, ok := pkt.NetworkLayer().(*layers.IPv4)
+		if !ok {
+			continue
+		}
+
+		udp, ok := pkt.TransportLayer().(*layers.UDP)
+		if !ok || !isDHCPPort(udp.SrcPort) && !isDHCPPort(udp.DstPort) {
+			continue
+		}
+
+		addr := &net.UDPAddr{
+			IP:   ip.SrcIP,
+			Port: int(udp.SrcPort),
+		}
+
+		return copy(b, udp.Payload), addr, 0, nil
+	}
+}
+
+// WriteTo discards the packet.
+func (c *replayConn) WriteTo(b []byte, addr net.Addr, ifindex int) (int, error) {
+	return len(b), nil
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4zero, Port: 67}
+}
+
+func isDHCPPort(p layers.UDPPort) bool {
+	return p == 67 || p == 68
+}
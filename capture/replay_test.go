@@ -0,0 +1,100 @@
+package capture
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/betawaffle/dhcp4-go"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHandler struct {
+	msgs []dhcp4.MessageType
+}
+
+func (h *testHandler) ServeDHCP(w dhcp4.ReplyWriter, p *dhcp4.Packet) {
+	h.msgs = append(h.msgs, p.GetMessageType())
+}
+
+func udpFrame(t *testing.T, src, dst layers.UDPPort, payload []byte) []byte {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4zero,
+		DstIP:    net.IPv4bcast,
+	}
+	udp := &layers.UDP{SrcPort: src, DstPort: dst}
+	udp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload(payload))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return buf.Bytes()
+}
+
+func dhcpPayload(t *testing.T, op dhcp4.OpCode, mt dhcp4.MessageType) []byte {
+	p := dhcp4.NewPacket(op)
+	p.SetMessageType(mt)
+
+	b, err := dhcp4.PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return b
+}
+
+func TestReplayPcap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcp.pcap")
+
+	f, err := os.Create(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := pcapgo.NewWriter(f)
+	assert.NoError(t, w.WriteFileHeader(65536, layers.LinkTypeEthernet))
+
+	frames := [][]byte{
+		udpFrame(t, 68, 67, dhcpPayload(t, dhcp4.BootRequest, dhcp4.MessageTypeDiscover)),
+		udpFrame(t, 53, 53, []byte("not dhcp")),
+		udpFrame(t, 67, 68, dhcpPayload(t, dhcp4.BootReply, dhcp4.MessageTypeOffer)),
+		udpFrame(t, 68, 67, dhcpPayload(t, dhcp4.BootRequest, dhcp4.MessageTypeRequest)),
+	}
+
+	for _, frame := range frames {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     time.Now(),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}
+		assert.NoError(t, w.WritePacket(ci, frame))
+	}
+	assert.NoError(t, f.Close())
+
+	h := &testHandler{}
+	assert.NoError(t, ReplayPcap(path, h))
+
+	// Only requests reach the handler
+	expected := []dhcp4.MessageType{
+		dhcp4.MessageTypeDiscover,
+		dhcp4.MessageTypeRequest,
+	}
+	assert.Equal(t, expected, h.msgs)
+}
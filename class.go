@@ -0,0 +1,126 @@
+package dhcp4
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// Classifier assigns requests to a class, for handlers that serve classes of
+// clients with different options or pools, like the classes of ISC dhcpd.
+type Classifier interface {
+	Classify(msg *Packet) (class string, ok bool)
+}
+
+// ClassifierFunc is an adapter to use an ordinary function as Classifier.
+type ClassifierFunc func(msg *Packet) (string, bool)
+
+// Classify calls f(msg).
+func (f ClassifierFunc) Classify(msg *Packet) (string, bool) {
+	return f(msg)
+}
+
+// ClassMatch matches requests to the class Name. A request matches if it
+// matches all of the criteria that are set.
+type ClassMatch struct {
+	Name string
+
+	// VendorClass is a substring of the Vendor Class Identifier option (60),
+	// e.g. "PXEClient".
+	VendorClass string
+
+	// UserClass is one of the user classes of the User Class option (77),
+	// see GetUserClasses.
+	UserClass string
+
+	// Enterprise is an enterprise number in the Vendor-Identifying Vendor
+	// Class option (124), see VIVendorEnterprises.
+	Enterprise uint32
+}
+
+// Matches returns whether msg matches m. A match without criteria matches no
+// request.
+func (m ClassMatch) Matches(msg *Packet) bool {
+	if m.VendorClass == "" && m.UserClass == "" && m.Enterprise == 0 {
+		return false
+	}
+
+	if m.VendorClass != "" {
+		v, ok := msg.GetString(OptionClassID)
+		if !ok || !strings.Contains(v, m.VendorClass) {
+			return false
+		}
+	}
+
+	if m.UserClass != "" {
+		classes, _ := msg.GetUserClasses()
+		if !containsString(classes, m.UserClass) {
+			return false
+		}
+	}
+
+	if m.Enterprise != 0 && !containsUint32(msg.VIVendorEnterprises(), m.Enterprise) {
+		return false
+	}
+
+	return true
+}
+
+// Classes is a Classifier assigning requests to the class of the first
+// matching ClassMatch.
+type Classes []ClassMatch
+
+// Classify implements Classifier.
+func (c Classes) Classify(msg *Packet) (string, bool) {
+	for _, m := range c {
+		if m.Matches(msg) {
+			return m.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// VIVendorEnterprises returns the enterprise numbers of the vendor classes in
+// the Vendor-Identifying Vendor Class option (124, RFC3925). It stops at the
+// first malformed entry.
+func (om OptionMap) VIVendorEnterprises() []uint32 {
+	v, _ := om.GetOption(OptionVIVendorClass)
+
+	var enterprises []uint32
+	for len(v) >= 5 && len(v) >= 5+int(v[4]) {
+		enterprises = append(enterprises, binary.BigEndian.Uint32(v))
+		v = v[5+int(v[4]):]
+	}
+
+	return enterprises
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsUint32(list []uint32, n uint32) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClassConfig is the configuration of a class of clients in a SimpleServer.
+type ClassConfig struct {
+	// Options to include in replies to the class, in addition to, and taking
+	// precedence over, the server's options.
+	Options OptionMap
+
+	// Pool to lease addresses from, instead of the server's pool, if not nil.
+	Pool *LeasePool
+}
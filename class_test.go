@@ -0,0 +1,72 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClasses(t *testing.T) {
+	classes := Classes{
+		{Name: "ipxe", VendorClass: "PXEClient", UserClass: "iPXE"},
+		{Name: "pxe", VendorClass: "PXEClient"},
+		{Name: "cablelabs", Enterprise: 4491},
+		{Name: "empty"},
+	}
+
+	p := NewPacket(BootRequest)
+	_, ok := classes.Classify(&p)
+	assert.False(t, ok)
+
+	p.SetString(OptionClassID, "PXEClient:Arch:00000:UNDI:002001")
+	class, _ := classes.Classify(&p)
+	assert.Equal(t, "pxe", class)
+
+	p.SetOption(OptionUserClass, []byte{4, 'i', 'P', 'X', 'E'})
+	class, _ = classes.Classify(&p)
+	assert.Equal(t, "ipxe", class)
+
+	q := NewPacket(BootRequest)
+	q.SetOption(OptionVIVendorClass, []byte{
+		0, 0, 0, 9, 2, 1, 'a',
+		0, 0, 0x11, 0x8b, 0,
+	})
+	assert.Equal(t, []uint32{9, 4491}, q.VIVendorEnterprises())
+	class, _ = classes.Classify(&q)
+	assert.Equal(t, "cablelabs", class)
+}
+
+func TestSimpleServerClasses(t *testing.T) {
+	s := testSimpleServer(t)
+
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	pool, _ := NewLeasePoolRanges([]IPRange{{Start: net.IPv4(192, 168, 1, 200), End: net.IPv4(192, 168, 1, 250)}})
+	opts := make(OptionMap)
+	opts.SetString(OptionDomainName, "pxe.example.com")
+
+	s.Network = network
+	s.Classifier = Classes{{Name: "pxe", VendorClass: "PXEClient"}}
+	s.Classes = map[string]ClassConfig{"pxe": {Options: opts, Pool: pool}}
+
+	w := &testReplyRecorder{}
+	dis := testSimpleRequest(MessageTypeDiscover, net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	dis.SetString(OptionClassID, "PXEClient")
+	dis.SetOption(OptionParameterList, []byte{byte(OptionRouter), byte(OptionDomainName)})
+	s.ServeDHCP(w, dis)
+
+	offer := w.last()
+	if assert.NotNil(t, offer) {
+		assert.Equal(t, net.IPv4(192, 168, 1, 200).To4(), offer.GetYIAddr().To4())
+		name, _ := offer.GetString(OptionDomainName)
+		assert.Equal(t, "pxe.example.com", name)
+		_, ok := offer.GetOption(OptionRouter)
+		assert.True(t, ok, "server options are included")
+	}
+
+	// Other clients lease from the server's pool
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, net.HardwareAddr{2, 0, 0, 0, 0, 2}))
+	if offer := w.last(); assert.NotNil(t, offer) {
+		assert.False(t, pool.Contains(offer.GetYIAddr()))
+	}
+}
@@ -0,0 +1,179 @@
+package dhcp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+var (
+	ErrNoResponse = errors.New("dhcp4: no response")
+)
+
+// ClientConn is a PacketConn that supports read deadlines. The PacketConn
+// returned by Listen and NewPacketConn implements this interface.
+type ClientConn interface {
+	PacketConn
+
+	SetReadDeadline(t time.Time) error
+}
+
+// Client defines parameters for exchanging messages with DHCP servers.
+type Client struct {
+	// Conn to send requests and receive replies on.
+	Conn ClientConn
+
+	// Addr is the address requests are sent to. If nil, requests are broadcast
+	// to port 67.
+	Addr net.Addr
+
+	// IfIndex is the index of the network interface requests are sent on.
+	IfIndex int
+
+	// Timeout is the maximum time an exchange may take, including
+	// retransmissions. If zero, the request is retransmitted until the
+	// retransmission timeout reaches its maximum of 64 seconds.
+	Timeout time.Duration
+
+	// Clock, if not nil, provides the time the 'secs' field of requests and
+	// read deadlines are computed from, instead of the time package. As Conn
+	// enforces the deadlines, it must use the same clock, e.g. in tests.
+	Clock Clock
+
+	// For testing: returns the retransmission timeout for the n-th attempt,
+	// and whether it is the maximum timeout.
+	retransmitTimeout func(n int) (time.Duration, bool)
+}
+
+// retransmitTimeout returns the time to wait for a reply to the n-th
+// (zero-based) transmission of a request, and whether the timeout reached its
+// maximum, so that the client stops retransmitting. The maximum is decided
+// before randomization, which varies from call to call.
+//
+// From RFC2131 section 4.1:
+// For example, in a 10Mb/sec Ethernet internetwork, the delay before the first
+// retransmission SHOULD be 4 seconds randomized by the value of a uniform
+// random number chosen from the range -1 to +1. [...] The delay before the
+// next retransmission SHOULD be 8 seconds randomized by the value of a uniform
+// number chosen from the range -1 to +1. The retransmission delay SHOULD be
+// doubled with subsequent retransmissions up to a maximum of 64 seconds.
+func retransmitTimeout(n int) (time.Duration, bool) {
+	d := 4 * time.Second << uint(n)
+	max := d >= 64*time.Second || d <= 0
+	if max {
+		d = 64 * time.Second
+	}
+
+	return d - time.Second + time.Duration(rand.Int63n(int64(2*time.Second))), max
+}
+
+// Exchange sends request req and returns the first reply with the same
+// transaction ID. The request is retransmitted every time the retransmission
+// timeout expires. Replies arriving late, after the request has been
+// retransmitted, are accepted as well. If no reply arrives before the
+// client's timeout, Exchange returns ErrNoResponse.
+func (c *Client) Exchange(req *Packet) (*Packet, error) {
+	b, err := PacketToBytes(*req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := c.Addr
+	if addr == nil {
+		addr = &net.UDPAddr{IP: net.IPv4bcast, Port: 67}
+	}
+
+	timeoutFn := c.retransmitTimeout
+	if timeoutFn == nil {
+		timeoutFn = retransmitTimeout
+	}
+
+	clk := c.clock()
+	start := clk.Now()
+
+	var deadline time.Time
+	if c.Timeout > 0 {
+		deadline = clk.Now().Add(c.Timeout)
+	}
+
+	buf := make([]byte, 65536)
+	for n := 0; ; n++ {
+		// The last transmission is the one waiting the maximum timeout
+		timeout, max := timeoutFn(n)
+		last := deadline.IsZero() && max
+
+		// Seconds elapsed since the start of the exchange (RFC2131 section 2)
+		binary.BigEndian.PutUint16(RawPacket(b).Secs(), uint16(clk.Now().Sub(start)/time.Second))
+
+		if _, err := c.Conn.WriteTo(b, addr, c.IfIndex); err != nil {
+			return nil, err
+		}
+
+		readDeadline := clk.Now().Add(timeout)
+		if !deadline.IsZero() && readDeadline.After(deadline) {
+			readDeadline = deadline
+			last = true
+		}
+
+		rep, err := c.readReply(buf, req.XID(), readDeadline)
+		if err == nil {
+			return rep, nil
+		}
+
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return nil, err
+		}
+
+		if last {
+			return nil, ErrNoResponse
+		}
+	}
+}
+
+// readReply reads packets until it finds a reply with transaction ID xid, or
+// the deadline passes.
+func (c *Client) readReply(buf, xid []byte, deadline time.Time) (*Packet, error) {
+	var rep *Packet
+	err := c.readReplies(buf, xid, deadline, func(p *Packet, addr net.Addr) bool {
+		rep = p
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rep, nil
+}
+
+// readReplies reads packets until the deadline passes, calling fn for every
+// reply with transaction ID xid, until fn returns false.
+func (c *Client) readReplies(buf, xid []byte, deadline time.Time, fn func(p *Packet, addr net.Addr) bool) error {
+	if err := c.Conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+
+	for {
+		n, addr, _, err := c.Conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		p, err := PacketFromBytes(buf[:n])
+		if err != nil {
+			clog.Warning(err)
+			continue
+		}
+
+		// Filter everything but replies to this request
+		if OpCode(p.Op()[0]) != BootReply || !bytes.Equal(p.XID(), xid) {
+			continue
+		}
+
+		if !fn(&p, addr) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package dhcp4
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTimeoutError struct{}
+
+func (testTimeoutError) Error() string   { return "i/o timeout" }
+func (testTimeoutError) Timeout() bool   { return true }
+func (testTimeoutError) Temporary() bool { return true }
+
+// testClientConn delivers the packets returned by onWrite after every write.
+type testClientConn struct {
+	mu       sync.Mutex
+	writes   [][]byte
+	deadline time.Time
+
+	onWrite func(n int) [][]byte
+	queue   chan []byte
+}
+
+func newTestClientConn(onWrite func(n int) [][]byte) *testClientConn {
+	return &testClientConn{
+		onWrite: onWrite,
+		queue:   make(chan []byte, 16),
+	}
+}
+
+func (c *testClientConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
+	c.mu.Lock()
+	d := time.Until(c.deadline)
+	c.mu.Unlock()
+
+	select {
+	case p := <-c.queue:
+		return copy(b, p), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 67}, 1, nil
+	case <-time.After(d):
+		return 0, nil, -1, testTimeoutError{}
+	}
+}
+
+func (c *testClientConn) WriteTo(b []byte, addr net.Addr, ifindex int) (int, error) {
+	c.mu.Lock()
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	n := len(c.writes)
+	c.mu.Unlock()
+
+	if c.onWrite != nil {
+		for _, p := range c.onWrite(n) {
+			c.queue <- p
+		}
+	}
+
+	return len(b), nil
+}
+
+func (c *testClientConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *testClientConn) Close() error {
+	return nil
+}
+
+func (c *testClientConn) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4zero, Port: 68}
+}
+
+func testClientReply(t *testing.T, xid []byte) []byte {
+	rep := NewPacket(BootReply)
+	copy(rep.XID(), xid)
+	rep.SetMessageType(MessageTypeOffer)
+
+	b, err := PacketToBytes(rep, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return b
+}
+
+func testClientRequest() Packet {
+	req := NewPacket(BootRequest)
+	copy(req.XID(), []byte{1, 2, 3, 4})
+	req.SetMessageType(MessageTypeDiscover)
+	return req
+}
+
+func TestRetransmitTimeout(t *testing.T) {
+	for n, d := range []time.Duration{4, 8, 16, 32, 64, 64, 64} {
+		d *= time.Second
+		for i := 0; i < 10; i++ {
+			actual, max := retransmitTimeout(n)
+			assert.True(t, actual >= d-time.Second && actual < d+time.Second,
+				"expected %s to be within 1s of %s", actual, d)
+			assert.Equal(t, n >= 4, max, "maximum timeout at attempt %d", n)
+		}
+	}
+}
+
+func TestClientExchangeNoResponseJittered(t *testing.T) {
+	// With the randomized timeouts, scaled down, the client stops after the
+	// first transmission waiting the maximum timeout
+	for i := 0; i < 3; i++ {
+		req := testClientRequest()
+		conn := newTestClientConn(nil)
+
+		c := Client{
+			Conn: conn,
+			retransmitTimeout: func(n int) (time.Duration, bool) {
+				d, max := retransmitTimeout(n)
+				return d / 1000, max
+			},
+		}
+
+		_, err := c.Exchange(&req)
+		assert.Equal(t, ErrNoResponse, err)
+		assert.Len(t, conn.writes, 5)
+	}
+}
+
+func TestClientExchangeAcceptsLateReply(t *testing.T) {
+	req := testClientRequest()
+
+	// Reply to the first transmission arrives after the second transmission,
+	// preceded by a reply for another transaction
+	conn := newTestClientConn(func(n int) [][]byte {
+		if n < 2 {
+			return nil
+		}
+
+		return [][]byte{
+			testClientReply(t, []byte{5, 6, 7, 8}),
+			testClientReply(t, req.XID()),
+		}
+	})
+
+	c := Client{
+		Conn:              conn,
+		Timeout:           time.Second,
+		retransmitTimeout: func(n int) (time.Duration, bool) { return 10 * time.Millisecond, false },
+	}
+
+	rep, err := c.Exchange(&req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, req.XID(), rep.XID())
+		assert.Equal(t, MessageTypeOffer, rep.GetMessageType())
+	}
+
+	assert.Len(t, conn.writes, 2)
+}
+
+func TestClientExchangeNoResponse(t *testing.T) {
+	req := testClientRequest()
+	conn := newTestClientConn(nil)
+
+	// Without timeout, stop once the retransmission timeout stops growing
+	c := Client{
+		Conn: conn,
+		retransmitTimeout: func(n int) (time.Duration, bool) {
+			if n > 3 {
+				n = 3
+			}
+			return time.Millisecond << uint(n), n == 3
+		},
+	}
+
+	_, err := c.Exchange(&req)
+	assert.Equal(t, ErrNoResponse, err)
+	assert.Len(t, conn.writes, 4)
+}
+
+func TestClientExchangeTimeout(t *testing.T) {
+	req := testClientRequest()
+	conn := newTestClientConn(nil)
+
+	c := Client{
+		Conn:              conn,
+		Timeout:           25 * time.Millisecond,
+		retransmitTimeout: func(n int) (time.Duration, bool) { return 10 * time.Millisecond, false },
+	}
+
+	start := time.Now()
+	_, err := c.Exchange(&req)
+	assert.Equal(t, ErrNoResponse, err)
+	assert.Len(t, conn.writes, 3)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,81 @@
+package dhcp4
+
+import (
+	"bytes"
+	"errors"
+	"net"
+)
+
+var (
+	ErrClientIDConflict = errors.New("dhcp4: client identifier claimed by another hardware address")
+)
+
+// ClientIDPolicy defines how a LeasePool handles a client identifier that is
+// presented with another hardware address than the one that claimed it.
+//
+// Clients choose their own client identifier (option 61), so on an open
+// network any host can present the identifier of another client, and renew,
+// release or decline the other client's lease. ClientIDStrict prevents this
+// by binding the identifier to the hardware address of the client that
+// claimed it, for as long as the lease lasts. Hosts sharing one identifier
+// between interfaces, or moving it to new hardware, are then refused until
+// the lease expires; ClientIDLenient lets the identifier win in that case.
+// Hardware addresses can be spoofed as well, so neither policy replaces
+// access control on the network.
+type ClientIDPolicy int
+
+const (
+	// ClientIDStrict refuses a client identifier presented with another
+	// hardware address than the one of its unexpired lease. It is the
+	// default.
+	ClientIDStrict ClientIDPolicy = iota
+
+	// ClientIDLenient accepts a client identifier regardless of the hardware
+	// address it is presented with; the lease follows the identifier.
+	ClientIDLenient
+)
+
+// ClientIDConflictEvent is sent when a client presents a client identifier
+// with another hardware address than the one recorded in its lease, or a
+// hardware address recorded in the lease of another client identifier. Lease
+// is the conflicting lease.
+type ClientIDConflictEvent struct {
+	ClientID     []byte
+	HardwareAddr net.HardwareAddr
+	Lease        Lease
+}
+
+func (ClientIDConflictEvent) event() {}
+
+// CheckClient checks the client identifier clientID presented with hardware
+// address mac against the recorded leases (see SetHardwareAddr). It returns
+// the conflict, if any, and ErrClientIDConflict if the pool's ClientIDPolicy
+// refuses the client. A changed client identifier for the same hardware
+// address is reported, but never refused: the client gets a new lease, and the
+// old lease is left to its owner.
+func (p *LeasePool) CheckClient(clientID []byte, mac net.HardwareAddr) (*ClientIDConflictEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock().Now()
+
+	if v, ok := p.byClient[string(clientID)]; ok {
+		l := p.leases[v]
+		if l.HardwareAddr != nil && !bytes.Equal(l.HardwareAddr, mac) && !now.After(l.Expiry) {
+			c := &ClientIDConflictEvent{ClientID: clientID, HardwareAddr: mac, Lease: *l}
+			if p.ClientIDPolicy == ClientIDStrict {
+				return c, ErrClientIDConflict
+			}
+			return c, nil
+		}
+	}
+
+	if v, ok := p.byMAC[string(mac)]; ok {
+		l := p.leases[v]
+		if !bytes.Equal(l.ClientID, clientID) && !now.After(l.Expiry) {
+			return &ClientIDConflictEvent{ClientID: clientID, HardwareAddr: mac, Lease: *l}, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,56 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasePoolCheckClient(t *testing.T) {
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	other := net.HardwareAddr{2, 0, 0, 0, 0, 2}
+
+	p := testLeasePool(t, "10.0.0.0/24")
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, p.SetHardwareAddr([]byte("a"), mac))
+
+	c, err := p.CheckClient([]byte("a"), mac)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	// Another hardware address presenting the client identifier
+	c, err = p.CheckClient([]byte("a"), other)
+	assert.Equal(t, ErrClientIDConflict, err)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, other, c.HardwareAddr)
+		assert.Equal(t, mac, c.Lease.HardwareAddr)
+		assert.Equal(t, l.IP, c.Lease.IP)
+	}
+
+	p.ClientIDPolicy = ClientIDLenient
+	c, err = p.CheckClient([]byte("a"), other)
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+
+	// The same hardware address with another client identifier
+	p.ClientIDPolicy = ClientIDStrict
+	c, err = p.CheckClient([]byte("b"), mac)
+	assert.NoError(t, err)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, []byte("a"), c.Lease.ClientID)
+	}
+}
+
+func TestLeasePoolCheckClientExpired(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	_, err := p.Allocate([]byte("a"), nil, -time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, p.SetHardwareAddr([]byte("a"), net.HardwareAddr{2, 0, 0, 0, 0, 1}))
+
+	c, err := p.CheckClient([]byte("a"), net.HardwareAddr{2, 0, 0, 0, 0, 2})
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
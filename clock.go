@@ -0,0 +1,43 @@
+package dhcp4
+
+import "time"
+
+// Clock provides the time to lease pools, servers and clients, so that tests
+// can control it. The default uses the time package.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOrReal returns c, or the real clock if c is nil.
+func clockOrReal(c Clock) Clock {
+	if c == nil {
+		return realClock{}
+	}
+
+	return c
+}
+
+// clock returns the pool's clock.
+func (p *LeasePool) clock() Clock {
+	return clockOrReal(p.Clock)
+}
+
+// clock returns the server's clock.
+func (s *Server) clock() Clock {
+	if s == nil {
+		return realClock{}
+	}
+
+	return clockOrReal(s.Clock)
+}
+
+// clock returns the client's clock.
+func (c *Client) clock() Clock {
+	return clockOrReal(c.Clock)
+}
@@ -0,0 +1,170 @@
+package dhcp4
+
+import (
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeClock is a Clock whose time only moves with Advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance moves the time forward by d, firing the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = timers
+}
+
+// waitTimers waits until n timers are pending.
+func (c *fakeClock) waitTimers(t *testing.T, n int) {
+	for i := 0; i < 1000; i++ {
+		c.mu.Lock()
+		pending := len(c.timers)
+		c.mu.Unlock()
+
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for %d timers", n)
+}
+
+func TestLeasePoolClock(t *testing.T) {
+	clk := newFakeClock()
+	p := testLeasePool(t, "10.0.0.0/30")
+	p.Clock = clk
+
+	a, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, clk.Now().Add(time.Hour), a.Expiry)
+	_, err = p.Allocate([]byte("b"), nil, 2*time.Hour)
+	assert.NoError(t, err)
+
+	_, err = p.Allocate([]byte("c"), nil, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// The address of a is leased again once its lease expired
+	clk.Advance(time.Hour + time.Second)
+	c, err := p.Allocate([]byte("c"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, a.IP, c.IP)
+
+	// As is a conflicting address once its hold-down passed
+	assert.NoError(t, p.MarkConflict(a.IP))
+	assert.NoError(t, p.Release([]byte("c"), nil))
+	_, err = p.Allocate([]byte("c"), a.IP, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	clk.Advance(DefaultConflictHoldDown)
+	assert.Empty(t, p.Conflicts())
+	c, err = p.Allocate([]byte("c"), a.IP, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, a.IP, c.IP)
+}
+
+func TestLeasePoolSweeperClock(t *testing.T) {
+	clk := newFakeClock()
+	p := testLeasePool(t, "10.0.0.0/30")
+	p.Clock = clk
+
+	events := make(chan Event, 1)
+	assert.NoError(t, p.StartSweeper(time.Minute, 0, func(e Event) { events <- e }))
+	defer p.Close()
+
+	l, err := p.Allocate([]byte("a"), nil, 30*time.Second)
+	assert.NoError(t, err)
+
+	clk.waitTimers(t, 1)
+	clk.Advance(time.Minute)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, ExpireEvent{Lease: l}, e)
+	case <-time.After(time.Second):
+		t.Error("no ExpireEvent")
+	}
+}
+
+func TestReplyWriterRetryClock(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeInform)
+	ack := CreateAck(&req)
+	ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	clk := newFakeClock()
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, syscall.ENOBUFS).Once()
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil).Once()
+
+	rw := replyWriter{
+		pw:  pw,
+		srv: &Server{Clock: clk, WriteRetry: &RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour}},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- rw.WriteReply(&ack) }()
+
+	// The retry waits for the clock, not the real time
+	clk.waitTimers(t, 1)
+	clk.Advance(time.Hour)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("write not retried")
+	}
+	pw.AssertNumberOfCalls(t, "WriteTo", 2)
+}
@@ -0,0 +1,95 @@
+package dhcp4
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultConflictHoldDown is the time a conflicting address is not leased, if
+// the pool's ConflictHoldDown is 0.
+const DefaultConflictHoldDown = time.Hour
+
+// Conflict is an address found to be in use by a host that has no lease for
+// it, which is not leased until Expiry.
+type Conflict struct {
+	IP     net.IP
+	Expiry time.Time
+}
+
+// MarkConflict marks address ip as in use by another host, e.g. after a
+// client declined it, or a ping or ARP probe got an answer. Allocate and
+// AllocateIP don't lease the address, not even to its current client, until
+// the pool's ConflictHoldDown passes; marking it again restarts the
+// hold-down. It returns ErrInvalidAddress if ip is not an IPv4 address.
+func (p *LeasePool) MarkConflict(ip net.IP) error {
+	v, ok := ipToUint32(ip)
+	if !ok {
+		return ErrInvalidAddress
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.markConflict(v, p.clock().Now())
+	return nil
+}
+
+// markConflict marks address v as conflicting from now on. The caller must
+// hold the lock.
+func (p *LeasePool) markConflict(v uint32, now time.Time) {
+	d := p.ConflictHoldDown
+	if d <= 0 {
+		d = DefaultConflictHoldDown
+	}
+
+	if p.conflicts == nil {
+		p.conflicts = make(map[uint32]time.Time)
+	}
+
+	p.conflicts[v] = now.Add(d)
+}
+
+// inConflict returns whether address v is held down after a conflict,
+// forgetting the conflict once the hold-down passed. The caller must hold the
+// lock.
+func (p *LeasePool) inConflict(v uint32, now time.Time) bool {
+	expiry, ok := p.conflicts[v]
+	if !ok {
+		return false
+	}
+
+	if now.Before(expiry) {
+		return true
+	}
+
+	delete(p.conflicts, v)
+	return false
+}
+
+// Conflicts returns the addresses that are held down after a conflict (see
+// MarkConflict), ordered by address.
+func (p *LeasePool) Conflicts() []Conflict {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.conflictsAt(p.clock().Now())
+}
+
+// conflictsAt implements Conflicts. The caller must hold the lock.
+func (p *LeasePool) conflictsAt(now time.Time) []Conflict {
+	var vs []uint32
+	for v := range p.conflicts {
+		if p.inConflict(v, now) {
+			vs = append(vs, v)
+		}
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+
+	conflicts := make([]Conflict, len(vs))
+	for i, v := range vs {
+		conflicts[i] = Conflict{IP: uint32ToIP(v), Expiry: p.conflicts[v]}
+	}
+
+	return conflicts
+}
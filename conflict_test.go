@@ -0,0 +1,86 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasePoolMarkConflict(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+	p.ConflictHoldDown = time.Minute
+
+	ip := net.IPv4(10, 0, 0, 1)
+	assert.NoError(t, p.MarkConflict(ip))
+	assert.Equal(t, ErrInvalidAddress, p.MarkConflict(net.ParseIP("2001:db8::1")))
+
+	conflicts := p.Conflicts()
+	if assert.Len(t, conflicts, 1) {
+		assert.True(t, conflicts[0].IP.Equal(ip))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), conflicts[0].Expiry, time.Second)
+	}
+
+	// The address is skipped while held down
+	now := time.Now()
+	l, err := p.allocate([]byte("a"), ip, now, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, l.IP.Equal(net.IPv4(10, 0, 0, 2)), "got %s", l.IP)
+
+	_, err = p.allocate([]byte("b"), nil, now, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	_, err = p.AllocateIP([]byte("b"), ip, time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+
+	// And leased again after the hold-down
+	later := now.Add(2 * time.Minute)
+	l, err = p.allocate([]byte("b"), nil, later, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, l.IP.Equal(ip), "got %s", l.IP)
+	assert.Empty(t, p.conflictsAt(later))
+}
+
+func TestLeasePoolMarkConflictCurrentClient(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, p.MarkConflict(l.IP))
+
+	// Not even the client holding the address keeps it
+	renewed, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, renewed.IP.Equal(l.IP), "got %s", renewed.IP)
+
+	_, err = p.AllocateIP([]byte("a"), l.IP, time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+}
+
+func TestLeasePoolDeclineConflict(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+
+	// An offer expires quickly, the hold-down doesn't
+	now := time.Now()
+	l, err := p.allocate([]byte("a"), nil, now, time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, p.Decline([]byte("a"), nil))
+
+	conflicts := p.conflictsAt(now)
+	if assert.Len(t, conflicts, 1) {
+		assert.True(t, conflicts[0].IP.Equal(l.IP))
+	}
+
+	l2, err := p.allocate([]byte("b"), l.IP, now.Add(time.Minute), time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, l2.IP.Equal(l.IP))
+
+	l3, err := p.allocate([]byte("c"), l.IP, now.Add(DefaultConflictHoldDown+time.Minute), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, l3.IP.Equal(l.IP), "got %s", l3.IP)
+}
@@ -0,0 +1,374 @@
+// Package conformance contains constructed DHCP packets, valid and malformed,
+// with the outcomes the dhcp4 package is expected to produce for them. The
+// Test functions can be called from a regular Go test, to verify the dhcp4
+// package itself, or to check that a dhcp4.Handler replies to the requests of
+// RFC2131 client state transitions with the replies the RFC allows.
+package conformance
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/betawaffle/dhcp4-go"
+)
+
+// ParseCase is a wire-level packet with the expected outcome of parsing it.
+type ParseCase struct {
+	Name  string
+	Bytes []byte
+
+	// Err is the error dhcp4.PacketFromBytes is expected to return.
+	Err error
+
+	// Options holds options the parsed packet is expected to have, if Err is nil.
+	Options dhcp4.OptionMap
+}
+
+// ValidateCase is a reply with the expected outcome of validating it.
+type ValidateCase struct {
+	Name  string
+	Reply func() dhcp4.Reply
+	Valid bool
+}
+
+// Transition is a request a client sends in some state of the RFC2131 client
+// state machine (RFC2131, figure 5), with the reply message types a server
+// may send in response. If Replies is empty, the server must not reply.
+type Transition struct {
+	Name    string
+	Request func() *dhcp4.Packet
+	Replies []dhcp4.MessageType
+}
+
+var (
+	xid      = []byte{0xde, 0xad, 0xbe, 0xef}
+	chaddr   = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	clientIP = net.IPv4(192, 0, 2, 10).To4()
+	serverIP = net.IPv4(192, 0, 2, 1).To4()
+)
+
+// header returns the fixed 240 octet part of a request, including cookie.
+func header() []byte {
+	b := make([]byte, 240)
+	b[0] = byte(dhcp4.BootRequest)
+	b[1] = 1 // Ethernet
+	b[2] = byte(len(chaddr))
+	copy(b[4:8], xid)
+	copy(b[28:44], chaddr)
+	copy(b[236:240], []byte{99, 130, 83, 99})
+	return b
+}
+
+// withOptions returns a request with the raw options field opts.
+func withOptions(opts ...byte) []byte {
+	return append(header(), opts...)
+}
+
+// withOverload returns a request with the raw options, file and sname fields.
+func withOverload(opts, file, sname []byte) []byte {
+	b := append(header(), opts...)
+	copy(b[108:236], file)
+	copy(b[44:108], sname)
+	return b
+}
+
+// ParseCases returns the parse cases.
+func ParseCases() []ParseCase {
+	return []ParseCase{
+		{
+			Name:    "minimal",
+			Bytes:   withOptions(53, 1, 1, 255),
+			Options: dhcp4.OptionMap{53: {1}},
+		},
+		{
+			Name:    "padding",
+			Bytes:   withOptions(0, 0, 53, 1, 1, 0, 255, 0, 0),
+			Options: dhcp4.OptionMap{53: {1}},
+		},
+		{
+			Name:    "empty option",
+			Bytes:   withOptions(53, 1, 1, 12, 0, 255),
+			Options: dhcp4.OptionMap{53: {1}, 12: {}},
+		},
+		{
+			Name:    "split option (RFC3396)",
+			Bytes:   withOptions(53, 1, 1, 12, 3, 'f', 'o', 'o', 12, 3, 'b', 'a', 'r', 255),
+			Options: dhcp4.OptionMap{53: {1}, 12: []byte("foobar")},
+		},
+		{
+			Name:    "overload file",
+			Bytes:   withOverload([]byte{52, 1, 1, 255}, []byte{53, 1, 3, 255}, nil),
+			Options: dhcp4.OptionMap{52: {1}, 53: {3}},
+		},
+		{
+			Name:    "overload sname",
+			Bytes:   withOverload([]byte{52, 1, 2, 255}, nil, []byte{53, 1, 3, 255}),
+			Options: dhcp4.OptionMap{52: {2}, 53: {3}},
+		},
+		{
+			Name:    "overload file and sname",
+			Bytes:   withOverload([]byte{52, 1, 3, 255}, []byte{53, 1, 3, 255}, []byte{12, 1, 'x', 255}),
+			Options: dhcp4.OptionMap{52: {3}, 53: {3}, 12: []byte("x")},
+		},
+		{
+			Name:    "split option across overloaded fields (RFC3396)",
+			Bytes:   withOverload([]byte{52, 1, 1, 12, 3, 'f', 'o', 'o', 255}, []byte{12, 3, 'b', 'a', 'r', 255}, nil),
+			Options: dhcp4.OptionMap{52: {1}, 12: []byte("foobar")},
+		},
+		{
+			Name:  "short header",
+			Bytes: header()[:239],
+			Err:   dhcp4.ErrShortPacket,
+		},
+		{
+			Name:  "no options",
+			Bytes: header(),
+			Err:   dhcp4.ErrShortPacket,
+		},
+		{
+			Name:  "missing end",
+			Bytes: withOptions(53, 1, 1),
+			Err:   dhcp4.ErrShortPacket,
+		},
+		{
+			Name:  "missing length",
+			Bytes: withOptions(53),
+			Err:   dhcp4.ErrShortPacket,
+		},
+		{
+			Name:  "truncated value",
+			Bytes: withOptions(12, 10, 'f', 'o', 'o'),
+			Err:   dhcp4.ErrShortPacket,
+		},
+		{
+			Name:  "overloaded file without end",
+			Bytes: withOverload([]byte{52, 1, 1, 255}, []byte{12, 126}, nil),
+			Err:   dhcp4.ErrShortPacket,
+		},
+	}
+}
+
+// request returns a request of type t, from a client with address ciaddr,
+// carrying the specified options.
+func request(t dhcp4.MessageType, ciaddr net.IP, opts dhcp4.OptionMap) func() *dhcp4.Packet {
+	return func() *dhcp4.Packet {
+		p := dhcp4.NewPacket(dhcp4.BootRequest)
+		p.HType()[0] = 1
+		p.HLen()[0] = byte(len(chaddr))
+		copy(p.XID(), xid)
+		copy(p.CHAddr(), chaddr)
+		if ciaddr != nil {
+			p.SetCIAddr(ciaddr)
+		}
+
+		p.SetMessageType(t)
+		for k, v := range opts {
+			p.SetOption(k, v)
+		}
+
+		return &p
+	}
+}
+
+// ValidateCases returns the validate cases.
+func ValidateCases() []ValidateCase {
+	discover := request(dhcp4.MessageTypeDiscover, nil, nil)
+	req := request(dhcp4.MessageTypeRequest, nil, nil)
+	inform := request(dhcp4.MessageTypeInform, clientIP, nil)
+
+	offer := func(opts dhcp4.OptionMap) func() dhcp4.Reply {
+		return func() dhcp4.Reply {
+			r := dhcp4.CreateOffer(discover())
+			for k, v := range opts {
+				r.SetOption(k, v)
+			}
+			return &r
+		}
+	}
+
+	ack := func(msg func() *dhcp4.Packet, opts dhcp4.OptionMap) func() dhcp4.Reply {
+		return func() dhcp4.Reply {
+			r := dhcp4.CreateAck(msg())
+			for k, v := range opts {
+				r.SetOption(k, v)
+			}
+			return &r
+		}
+	}
+
+	nak := func(opts dhcp4.OptionMap) func() dhcp4.Reply {
+		return func() dhcp4.Reply {
+			r := dhcp4.CreateNak(req())
+			for k, v := range opts {
+				r.SetOption(k, v)
+			}
+			return &r
+		}
+	}
+
+	sid := []byte(serverIP)
+	lease := []byte{0, 0, 0x0e, 0x10}
+
+	return []ValidateCase{
+		{"offer", offer(dhcp4.OptionMap{51: lease, 54: sid}), true},
+		{"offer without lease time", offer(dhcp4.OptionMap{54: sid}), false},
+		{"offer without server identifier", offer(dhcp4.OptionMap{51: lease}), false},
+		{"offer with client identifier", offer(dhcp4.OptionMap{51: lease, 54: sid, 61: {1}}), false},
+		{"offer with requested address", offer(dhcp4.OptionMap{51: lease, 54: sid, 50: clientIP}), false},
+		{"ack on request", ack(req, dhcp4.OptionMap{51: lease, 54: sid}), true},
+		{"ack on request without lease time", ack(req, dhcp4.OptionMap{54: sid}), false},
+		{"ack on inform", ack(inform, dhcp4.OptionMap{54: sid}), true},
+		{"ack on inform with lease time", ack(inform, dhcp4.OptionMap{51: lease, 54: sid}), false},
+		{"ack with parameter list", ack(req, dhcp4.OptionMap{51: lease, 54: sid, 55: {1}}), false},
+		{"nak", nak(dhcp4.OptionMap{54: sid}), true},
+		{"nak with lease time", nak(dhcp4.OptionMap{51: lease, 54: sid}), false},
+		{"nak without server identifier", nak(nil), false},
+	}
+}
+
+// Transitions returns the client state transitions.
+func Transitions() []Transition {
+	sid := []byte(serverIP)
+	none := []dhcp4.MessageType(nil)
+	ackOrNak := []dhcp4.MessageType{dhcp4.MessageTypeAck, dhcp4.MessageTypeNak}
+
+	return []Transition{
+		{
+			Name:    "INIT -> SELECTING",
+			Request: request(dhcp4.MessageTypeDiscover, nil, nil),
+			Replies: []dhcp4.MessageType{dhcp4.MessageTypeOffer},
+		},
+		{
+			Name:    "SELECTING -> REQUESTING",
+			Request: request(dhcp4.MessageTypeRequest, nil, dhcp4.OptionMap{50: clientIP, 54: sid}),
+			Replies: ackOrNak,
+		},
+		{
+			Name:    "INIT-REBOOT -> REBOOTING",
+			Request: request(dhcp4.MessageTypeRequest, nil, dhcp4.OptionMap{50: clientIP}),
+			Replies: ackOrNak,
+		},
+		{
+			Name:    "BOUND -> RENEWING",
+			Request: request(dhcp4.MessageTypeRequest, clientIP, nil),
+			Replies: ackOrNak,
+		},
+		{
+			Name:    "REQUESTING -> INIT (decline)",
+			Request: request(dhcp4.MessageTypeDecline, nil, dhcp4.OptionMap{50: clientIP, 54: sid}),
+			Replies: none,
+		},
+		{
+			Name:    "BOUND -> INIT (release)",
+			Request: request(dhcp4.MessageTypeRelease, clientIP, dhcp4.OptionMap{54: sid}),
+			Replies: none,
+		},
+		{
+			Name:    "inform",
+			Request: request(dhcp4.MessageTypeInform, clientIP, nil),
+			Replies: []dhcp4.MessageType{dhcp4.MessageTypeAck},
+		},
+	}
+}
+
+// TestParse runs the parse cases against dhcp4.PacketFromBytes.
+func TestParse(t *testing.T) {
+	for _, c := range ParseCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			p, err := dhcp4.PacketFromBytes(c.Bytes)
+			if err != c.Err {
+				t.Fatalf("expected error %v, got %v", c.Err, err)
+			}
+
+			for k, v := range c.Options {
+				actual, ok := p.GetOption(k)
+				if !ok {
+					t.Errorf("expected option %d to be present", k)
+				} else if string(actual) != string(v) {
+					t.Errorf("expected option %d to be %v, got %v", k, v, actual)
+				}
+			}
+		})
+	}
+}
+
+// TestValidate runs the validate cases against the Validate function of the
+// dhcp4 reply types.
+func TestValidate(t *testing.T) {
+	for _, c := range ValidateCases() {
+		t.Run(c.Name, func(t *testing.T) {
+			err := c.Reply().Validate()
+			if c.Valid && err != nil {
+				t.Errorf("expected reply to be valid, got %v", err)
+			} else if !c.Valid && err == nil {
+				t.Errorf("expected reply to be invalid")
+			}
+		})
+	}
+}
+
+// TestHandler serves the request of every transition with handler h, and
+// checks that the replies it writes are allowed, valid, and serializable. Like
+// dhcp4.Serve, the handler receives a nil ReplyWriter for requests that must
+// not be replied to. Not replying at all is always allowed.
+func TestHandler(t *testing.T, h dhcp4.Handler) {
+	for _, c := range Transitions() {
+		t.Run(c.Name, func(t *testing.T) {
+			req := c.Request()
+
+			var rw dhcp4.ReplyWriter
+			w := &replyRecorder{t: t, req: req, allowed: c.Replies}
+			if len(c.Replies) > 0 {
+				rw = w
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("handler panicked: %v", r)
+				}
+			}()
+
+			h.ServeDHCP(rw, req)
+		})
+	}
+}
+
+type replyRecorder struct {
+	t       *testing.T
+	req     *dhcp4.Packet
+	allowed []dhcp4.MessageType
+}
+
+func (w *replyRecorder) WriteReply(r dhcp4.Reply) error {
+	if err := w.check(r); err != nil {
+		w.t.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *replyRecorder) check(r dhcp4.Reply) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := r.ToBytes(); err != nil {
+		return err
+	}
+
+	rep := r.Reply()
+	if string(rep.XID()) != string(w.req.XID()) {
+		return fmt.Errorf("reply has xid %x, expected %x", rep.XID(), w.req.XID())
+	}
+
+	mt := rep.GetMessageType()
+	for _, a := range w.allowed {
+		if mt == a {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("reply %s not allowed for %s", mt, w.req.GetMessageType())
+}
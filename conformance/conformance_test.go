@@ -0,0 +1,49 @@
+package conformance
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/betawaffle/dhcp4-go"
+)
+
+// referenceHandler replies to every request it is allowed to reply to.
+type referenceHandler struct{}
+
+func (referenceHandler) ServeDHCP(w dhcp4.ReplyWriter, p *dhcp4.Packet) {
+	var r dhcp4.Reply
+
+	switch p.GetMessageType() {
+	case dhcp4.MessageTypeDiscover:
+		offer := dhcp4.CreateOffer(p)
+		offer.SetYIAddr(clientIP)
+		offer.SetDuration(dhcp4.OptionAddressTime, time.Hour)
+		r = &offer
+	case dhcp4.MessageTypeRequest:
+		ack := dhcp4.CreateAck(p)
+		ack.SetYIAddr(clientIP)
+		ack.SetDuration(dhcp4.OptionAddressTime, time.Hour)
+		r = &ack
+	case dhcp4.MessageTypeInform:
+		ack := dhcp4.CreateAck(p)
+		r = &ack
+	default:
+		return
+	}
+
+	r.SetIP(dhcp4.OptionDHCPServerID, net.IP(serverIP))
+	w.WriteReply(r)
+}
+
+func TestConformanceParse(t *testing.T) {
+	TestParse(t)
+}
+
+func TestConformanceValidate(t *testing.T) {
+	TestValidate(t)
+}
+
+func TestConformanceHandler(t *testing.T) {
+	TestHandler(t, referenceHandler{})
+}
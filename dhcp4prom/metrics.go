@@ -0,0 +1,126 @@
+// Package dhcp4prom exports the metrics of a dhcp4.Server to Prometheus. It is
+// kept separate from the dhcp4 package so that package doesn't depend on the
+// Prometheus client library.
+//
+//	m, err := dhcp4prom.NewCollector(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		return err
+//	}
+//	s := dhcp4.Server{Handler: h, Metrics: m}
+package dhcp4prom
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/betawaffle/dhcp4-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dhcp4"
+
+// LatencyBuckets are the buckets of the reply latency histogram, in seconds.
+// Clients retransmit after about 4 seconds without reply (RFC2131, section
+// 4.1), so the buckets don't go much beyond that.
+var LatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Collector implements dhcp4.Metrics with Prometheus metrics:
+//
+//	dhcp4_packets_received_total{type,interface}
+//	dhcp4_packets_dropped_total{reason,interface}
+//	dhcp4_handler_panics_total{type}
+//	dhcp4_reply_latency_seconds{type}
+//	dhcp4_notifications_dropped_total
+//
+// The type label is the message type of the request, e.g. "DHCPDISCOVER", and
+// the interface label the name of the network interface the packet arrived
+// on, or its index if the name is unknown.
+type Collector struct {
+	received      *prometheus.CounterVec
+	dropped       *prometheus.CounterVec
+	panics        *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	notifications prometheus.Counter
+}
+
+var _ dhcp4.Metrics = (*Collector)(nil)
+
+// NewCollector returns a Collector with its metrics registered with reg, or
+// prometheus.DefaultRegisterer if reg is nil. It returns the registration
+// error, if any, e.g. if the metrics are already registered.
+func NewCollector(reg prometheus.Registerer) (*Collector, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	c := &Collector{
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_received_total",
+			Help:      "Requests passed to the handler.",
+		}, []string{"type", "interface"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_dropped_total",
+			Help:      "Packets dropped without passing them to the handler.",
+		}, []string{"reason", "interface"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_panics_total",
+			Help:      "Panics of the handler serving a request.",
+		}, []string{"type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reply_latency_seconds",
+			Help:      "Time from reading a request to writing the reply.",
+			Buckets:   LatencyBuckets,
+		}, []string{"type"}),
+		notifications: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notifications_dropped_total",
+			Help:      "Events dropped because the notification channel was full.",
+		}),
+	}
+
+	for _, m := range []prometheus.Collector{c.received, c.dropped, c.panics, c.latency, c.notifications} {
+		if err := reg.Register(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// interfaceLabel returns the value of the interface label for ifindex.
+func interfaceLabel(ifindex int) string {
+	if name, err := dhcp4.InterfaceName(ifindex); err == nil {
+		return name
+	}
+
+	return strconv.Itoa(ifindex)
+}
+
+// PacketReceived implements dhcp4.Metrics.
+func (c *Collector) PacketReceived(t dhcp4.MessageType, ifindex int) {
+	c.received.WithLabelValues(t.String(), interfaceLabel(ifindex)).Inc()
+}
+
+// PacketDropped implements dhcp4.Metrics.
+func (c *Collector) PacketDropped(reason dhcp4.DropReason, ifindex int) {
+	c.dropped.WithLabelValues(string(reason), interfaceLabel(ifindex)).Inc()
+}
+
+// HandlerPanicked implements dhcp4.Metrics.
+func (c *Collector) HandlerPanicked(t dhcp4.MessageType) {
+	c.panics.WithLabelValues(t.String()).Inc()
+}
+
+// ReplyLatency implements dhcp4.Metrics.
+func (c *Collector) ReplyLatency(t dhcp4.MessageType, d time.Duration) {
+	c.latency.WithLabelValues(t.String()).Observe(d.Seconds())
+}
+
+// NotificationDropped implements dhcp4.Metrics.
+func (c *Collector) NotificationDropped() {
+	c.notifications.Inc()
+}
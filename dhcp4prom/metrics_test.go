@@ -0,0 +1,40 @@
+package dhcp4prom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/betawaffle/dhcp4-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c, err := NewCollector(reg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c.PacketReceived(dhcp4.MessageTypeDiscover, -1)
+	c.PacketReceived(dhcp4.MessageTypeDiscover, -1)
+	c.PacketDropped(dhcp4.DropMalformed, -1)
+	c.HandlerPanicked(dhcp4.MessageTypeRequest)
+	c.ReplyLatency(dhcp4.MessageTypeDiscover, 3*time.Millisecond)
+	c.NotificationDropped()
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(c.received.WithLabelValues("DHCPDISCOVER", "-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.dropped.WithLabelValues("malformed", "-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.panics.WithLabelValues("DHCPREQUEST")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.notifications))
+	assert.Equal(t, 1, testutil.CollectAndCount(c.latency, "dhcp4_reply_latency_seconds"))
+
+	n, err := testutil.GatherAndCount(reg)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	// Registering twice fails
+	_, err = NewCollector(reg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package dhcp4
+
+import (
+	"errors"
+	"syscall"
+)
+
+var (
+	ErrDontFragmentUnsupported = errors.New("dhcp4: setting the don't fragment bit is not supported")
+)
+
+// DontFragmentSetter is implemented by a PacketConn that can control the IPv4
+// Don't Fragment (DF) bit of the packets it sends.
+type DontFragmentSetter interface {
+	SetDontFragment(df bool) error
+}
+
+// SetDontFragment sets whether packets are sent with the DF bit set. It is
+// implemented with socket options, and returns ErrDontFragmentUnsupported on
+// platforms other than Linux, or if the underlying connection doesn't expose
+// its socket.
+func (p *packetConn) SetDontFragment(df bool) error {
+	sc, ok := p.PacketConn.(syscall.Conn)
+	if !ok {
+		return ErrDontFragmentUnsupported
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = setDontFragment(fd, df)
+	})
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
@@ -0,0 +1,15 @@
+package dhcp4
+
+import "syscall"
+
+// setDontFragment sets the path MTU discovery mode of the socket. With
+// IP_PMTUDISC_DO the kernel sets DF and never fragments; with IP_PMTUDISC_DONT
+// it never sets DF.
+func setDontFragment(fd uintptr, df bool) error {
+	mode := syscall.IP_PMTUDISC_DONT
+	if df {
+		mode = syscall.IP_PMTUDISC_DO
+	}
+
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, mode)
+}
@@ -0,0 +1,39 @@
+package dhcp4
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketConnSetDontFragment(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+
+	pc, err := NewPacketConn(l)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mode := func() int {
+		rc, _ := l.(*net.UDPConn).SyscallConn()
+
+		var v int
+		rc.Control(func(fd uintptr) {
+			v, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER)
+		})
+		return v
+	}
+
+	df := pc.(DontFragmentSetter)
+	assert.NoError(t, df.SetDontFragment(true))
+	assert.Equal(t, syscall.IP_PMTUDISC_DO, mode())
+
+	assert.NoError(t, df.SetDontFragment(false))
+	assert.Equal(t, syscall.IP_PMTUDISC_DONT, mode())
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package dhcp4
+
+func setDontFragment(fd uintptr, df bool) error {
+	return ErrDontFragmentUnsupported
+}
@@ -0,0 +1,111 @@
+package dhcp4
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidFQDN = errors.New("dhcp4: invalid client FQDN option")
+)
+
+// Flags of the Client FQDN option (RFC4702, section 2.1).
+const (
+	FQDNFlagS = uint8(1 << 0) // Server should perform the A RR update
+	FQDNFlagO = uint8(1 << 1) // Server has overridden the client's S flag
+	FQDNFlagE = uint8(1 << 2) // Domain name is in canonical wire format
+	FQDNFlagN = uint8(1 << 3) // Server should perform no DNS updates
+)
+
+// ClientFQDN is the value of the Client FQDN option (81).
+//
+// From RFC4702 section 2.1, the flags are interpreted as follows. The client
+// sets S to request that the server performs the A RR update (forward
+// mapping). If S is 0, the client performs the A RR update itself. The server
+// always performs the PTR RR update (reverse mapping), unless the client sets
+// N, in which case the server performs no updates at all. S and N can't both
+// be set. The server sets O in its reply if it overrode the client's S flag.
+type ClientFQDN struct {
+	Flags uint8
+	Name  string
+}
+
+// ServerUpdatesA returns whether the server should perform the A RR update.
+func (f *ClientFQDN) ServerUpdatesA() bool {
+	return f.Flags&FQDNFlagS != 0 && f.Flags&FQDNFlagN == 0
+}
+
+// ServerUpdatesPTR returns whether the server should perform the PTR RR update.
+func (f *ClientFQDN) ServerUpdatesPTR() bool {
+	return f.Flags&FQDNFlagN == 0
+}
+
+// GetClientFQDN gets the Client FQDN option. The name is decoded from the
+// canonical wire format if the E flag is set, and used as is otherwise.
+// Names in wire format have no trailing dot.
+func (om OptionMap) GetClientFQDN() (*ClientFQDN, error) {
+	v, ok := om.GetOption(OptionClientFQDN)
+	if !ok {
+		return nil, nil
+	}
+
+	// Flags, followed by two deprecated RCODE octets
+	if len(v) < 3 {
+		return nil, ErrInvalidFQDN
+	}
+
+	f := &ClientFQDN{Flags: v[0]}
+	if f.Flags&FQDNFlagE == 0 {
+		f.Name = string(v[3:])
+		return f, nil
+	}
+
+	var labels []string
+	for b := v[3:]; len(b) > 0; {
+		n := int(b[0])
+		if n == 0 {
+			break
+		}
+		if n > 63 || len(b) < 1+n {
+			return nil, ErrInvalidFQDN
+		}
+
+		labels = append(labels, string(b[1:1+n]))
+		b = b[1+n:]
+	}
+
+	f.Name = strings.Join(labels, ".")
+	return f, nil
+}
+
+// SetClientFQDN sets the Client FQDN option. The name is encoded in the
+// canonical wire format if the E flag is set. A fully qualified name ends with
+// a dot; it is encoded with the terminating zero length label.
+func (om OptionMap) SetClientFQDN(f ClientFQDN) error {
+	v := []byte{f.Flags, 255, 255}
+	if f.Flags&FQDNFlagE == 0 {
+		om.SetOption(OptionClientFQDN, append(v, f.Name...))
+		return nil
+	}
+
+	name := f.Name
+	fqdn := strings.HasSuffix(name, ".")
+	name = strings.TrimSuffix(name, ".")
+
+	if name != "" {
+		for _, l := range strings.Split(name, ".") {
+			if len(l) == 0 || len(l) > 63 {
+				return ErrInvalidFQDN
+			}
+			v = append(v, byte(len(l)))
+			v = append(v, l...)
+		}
+	}
+
+	if fqdn {
+		v = append(v, 0)
+	}
+
+	om.SetOption(OptionClientFQDN, v)
+	return nil
+}
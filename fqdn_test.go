@@ -0,0 +1,67 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientFQDNRoundtrip(t *testing.T) {
+	for _, f := range []ClientFQDN{
+		{Flags: FQDNFlagE | FQDNFlagS, Name: "host.example.com"},
+		{Flags: FQDNFlagE, Name: "host"},
+		{Flags: FQDNFlagS, Name: "host.example.com"},
+	} {
+		om := make(OptionMap)
+		if assert.NoError(t, om.SetClientFQDN(f)) {
+			actual, err := om.GetClientFQDN()
+			if assert.NoError(t, err) {
+				assert.Equal(t, f, *actual)
+			}
+		}
+	}
+}
+
+func TestClientFQDNWireFormat(t *testing.T) {
+	om := make(OptionMap)
+	assert.NoError(t, om.SetClientFQDN(ClientFQDN{Flags: FQDNFlagE, Name: "a.bc."}))
+
+	v, _ := om.GetOption(OptionClientFQDN)
+	assert.Equal(t, []byte{FQDNFlagE, 255, 255, 1, 'a', 2, 'b', 'c', 0}, v)
+
+	f, err := om.GetClientFQDN()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a.bc", f.Name)
+	}
+
+	// Empty labels are invalid
+	assert.Equal(t, ErrInvalidFQDN, om.SetClientFQDN(ClientFQDN{Flags: FQDNFlagE, Name: "a..b"}))
+
+	// Truncated labels are invalid
+	om.SetOption(OptionClientFQDN, []byte{FQDNFlagE, 0, 0, 3, 'a'})
+	_, err = om.GetClientFQDN()
+	assert.Equal(t, ErrInvalidFQDN, err)
+
+	// Missing option
+	f, err = make(OptionMap).GetClientFQDN()
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+}
+
+func TestClientFQDNUpdates(t *testing.T) {
+	testCases := []struct {
+		flags  uint8
+		a, ptr bool
+	}{
+		{0, false, true},
+		{FQDNFlagS, true, true},
+		{FQDNFlagN, false, false},
+		{FQDNFlagS | FQDNFlagN, false, false},
+	}
+
+	for _, tc := range testCases {
+		f := ClientFQDN{Flags: tc.flags}
+		assert.Equal(t, tc.a, f.ServerUpdatesA(), "flags %x", tc.flags)
+		assert.Equal(t, tc.ptr, f.ServerUpdatesPTR(), "flags %x", tc.flags)
+	}
+}
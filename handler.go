@@ -1,11 +1,21 @@
 package dhcp4
 
 import (
+	"errors"
 	"net"
+	"os"
+	"sync"
+	"syscall"
 
 	"golang.org/x/net/ipv4"
 )
 
+// UDP ports of DHCP servers and clients (RFC2131, section 4.1).
+const (
+	ServerPort = 67
+	ClientPort = 68
+)
+
 // PacketReader defines an adaptation of the ReadFrom function (as defined
 // net.PacketConn) that includes the interface index the packet arrived on.
 type PacketReader interface {
@@ -28,8 +38,24 @@ type PacketConn interface {
 	LocalAddr() net.Addr
 }
 
+// ControlMessageWriter is implemented by a PacketConn that can write a packet
+// using an arbitrary IPv4 control message, for example to set the source
+// address of the packet.
+type ControlMessageWriter interface {
+	WriteToCM(b []byte, addr net.Addr, cm *ipv4.ControlMessage) (n int, err error)
+}
+
+// ControlMessageReader is implemented by a PacketConn that can return the
+// full IPv4 control message of a packet it reads, e.g. with the TTL and
+// destination address of the packet. The server then makes the control
+// message of requests available, see Packet.ControlMessage.
+type ControlMessageReader interface {
+	ReadFromCM(b []byte) (n int, src net.Addr, cm *ipv4.ControlMessage, err error)
+}
+
 type replyWriter struct {
-	pw PacketWriter
+	pw  PacketWriter
+	srv *Server
 
 	// The client address, if any
 	addr    net.UDPAddr
@@ -37,14 +63,40 @@ type replyWriter struct {
 }
 
 func (rw *replyWriter) WriteReply(r Reply) error {
+	_, err := rw.writeReply(r, false)
+	return err
+}
+
+// WriteReplyCached writes reply r like WriteReply, and returns it serialized
+// for retransmission, see CachedReply. It returns a nil CachedReply if the
+// server's ReplyInterceptor dropped the reply.
+func (rw *replyWriter) WriteReplyCached(r Reply) (*CachedReply, error) {
+	return rw.writeReply(r, true)
+}
+
+// writeReply implements WriteReply, returning the reply as sent if cache is
+// set.
+func (rw *replyWriter) writeReply(r Reply, cache bool) (*CachedReply, error) {
+	if r = rw.srv.interceptReply(r); r == nil {
+		return nil, nil
+	}
+
+	rw.srv.setServerID(r)
+
 	if err := r.Validate(); err != nil {
-		return err
+		return nil, err
 	}
 
-	bytes, err := r.ToBytes()
+	// Options added after validation, as they are disallowed by RFC2131
+	rw.srv.echoOptions(r)
+	rw.srv.applyReplyPolicy(r)
+	relayBroadcastFlag(r)
+
+	bytes, release, err := marshalReply(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer release()
 
 	var (
 		msg  = r.Message()
@@ -52,20 +104,198 @@ func (rw *replyWriter) WriteReply(r Reply) error {
 		send = &serverSend{req: msg, rep: r.Reply(), ifindex: rw.ifindex}
 	)
 	if ip := msg.GetGIAddr(); ip != nil && !ip.Equal(net.IPv4zero) {
+		// Send replies to relayed requests to the relay agent's server
+		// port, including the DHCPACK to a DHCPINFORM from a client that
+		// has an address (RFC2131, sections 4.1 and 4.3.5), unless the
+		// server is configured for relay agents listening on another port
 		addr.IP = ip
+		addr.Port = rw.srv.relayPort()
+	} else if rep := r.Reply(); rep != nil && rep.GetMessageType() == MessageTypeNak {
+		// Broadcast a DHCPNAK to a client on a local network, even if it has
+		// an address (RFC2131, section 4.1)
+		addr.IP = net.IPv4bcast
+	} else if ip := msg.GetCIAddr(); ip != nil && !ip.Equal(net.IPv4zero) {
+		// Unicast the reply to a client that has an address, e.g. the
+		// DHCPACK to a DHCPINFORM (RFC2131, sections 4.1 and 4.3.5).
+		addr.IP = ip
+		addr.Port = ClientPort
 	} else if addr.IP.Equal(net.IPv4zero) || msg.GetFlags()[0]&0x80 > 0 {
 		// Broadcast the reply if the request packet has no address associated with
 		// it, or if the client explicitly asks for a broadcast reply.
 		addr.IP = net.IPv4bcast
 	}
 
+	if port := rw.srv.replyPort(); port != 0 {
+		addr.Port = port
+	}
+
 	send.ip = addr.IP
+	if at := msg.ReceivedAt(); !at.IsZero() {
+		send.latency = rw.srv.clock().Now().Sub(at)
+	}
 	clog.Debug(send)
 
-	_, err = rw.pw.WriteTo(bytes, &addr, rw.ifindex)
+	// Send from the configured source address for this interface, if any
+	src := rw.srv.interfaceSource(rw.ifindex)
+	err = rw.retry(func() error {
+		return writeTo(rw.pw, bytes, &addr, rw.ifindex, src)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if send.latency > 0 {
+		rw.srv.metrics().ReplyLatency(msg.GetMessageType(), send.latency)
+	}
+
+	rw.leaseGranted(msg, r.Reply())
+
+	if !cache {
+		return nil, nil
+	}
+
+	c := &CachedReply{
+		CorrelationID: msg.CorrelationID(),
+		Bytes:         append([]byte(nil), bytes...),
+		Addr:          addr,
+		IfIndex:       rw.ifindex,
+		Src:           src,
+	}
+
+	return c, nil
+}
+
+// writeTo writes packet b to addr over the network interface with index
+// ifindex, from source address src if it is not nil and pw implements
+// ControlMessageWriter.
+func writeTo(pw PacketWriter, b []byte, addr *net.UDPAddr, ifindex int, src net.IP) error {
+	if src != nil {
+		if cw, ok := pw.(ControlMessageWriter); ok {
+			cm := &ipv4.ControlMessage{
+				IfIndex: ifindex,
+				Src:     src,
+			}
+
+			_, err := cw.WriteToCM(b, addr, cm)
+			return err
+		}
+	}
+
+	_, err := pw.WriteTo(b, addr, ifindex)
 	return err
 }
 
+// relayBroadcastFlag copies the broadcast flag of the request into reply r, if
+// the request was relayed. The relay agent broadcasts the reply to the client
+// if the flag is set (RFC1542, section 4.1.2), which is what the client asked
+// for, regardless of the flags the handler set in the reply. A relayed DHCPNAK
+// always has the flag set, as the client may have no valid address (RFC2131,
+// section 4.3.2).
+func relayBroadcastFlag(r Reply) {
+	rep := r.Reply()
+	if rep == nil {
+		return
+	}
+
+	msg := r.Message()
+	if ip := msg.GetGIAddr(); ip == nil || ip.Equal(net.IPv4zero) {
+		return
+	}
+
+	flags := rep.Flags()
+	if rep.GetMessageType() == MessageTypeNak {
+		flags[0] |= 0x80
+		return
+	}
+
+	flags[0] = flags[0]&^0x80 | msg.GetFlags()[0]&0x80
+}
+
+// replyMarshaler is implemented by replies that can serialize into a
+// caller-provided buffer, such as Offer, Ack and Nak.
+type replyMarshaler interface {
+	MarshalTo(buf []byte) (int, error)
+}
+
+// replyBuffers holds buffers for serializing replies. A buffer fits the
+// largest message size a client can request.
+var replyBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 65536)
+		return &b
+	},
+}
+
+// marshalReply serializes r, into a pooled buffer if r supports it. The
+// returned function releases the buffer; the bytes must not be used after.
+func marshalReply(r Reply) ([]byte, func(), error) {
+	m, ok := r.(replyMarshaler)
+	if !ok {
+		b, err := r.ToBytes()
+		return b, func() {}, err
+	}
+
+	buf := replyBuffers.Get().(*[]byte)
+	release := func() { replyBuffers.Put(buf) }
+
+	n, err := m.MarshalTo(*buf)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return (*buf)[:n], release, nil
+}
+
+// leaseGranted calls the server's OnLeaseGranted hook, and sends a
+// LeaseGrantedEvent, if rep is a DHCPACK in response to the DHCPREQUEST req.
+func (rw *replyWriter) leaseGranted(req, rep *Packet) {
+	if rw.srv == nil || rep == nil {
+		return
+	}
+
+	if req.GetMessageType() != MessageTypeRequest || rep.GetMessageType() != MessageTypeAck {
+		return
+	}
+
+	fqdn, err := req.GetClientFQDN()
+	if err != nil {
+		clog.Warning(err)
+	}
+
+	lease := leaseFromAck(req, rep, rw.srv.clock().Now())
+	if rw.srv.OnLeaseGranted != nil {
+		rw.srv.OnLeaseGranted(lease, fqdn)
+	}
+
+	rw.srv.notify(LeaseGrantedEvent{Lease: lease, FQDN: fqdn})
+}
+
+// retry calls write until it succeeds, returns a permanent error, or the
+// server's retry policy is exhausted.
+func (rw *replyWriter) retry(write func() error) error {
+	policy := rw.srv.writeRetry()
+	for i := 0; ; i++ {
+		err := write()
+		if err == nil || i >= policy.MaxRetries || !isTransient(err) {
+			return err
+		}
+
+		clog.Debugf("retrying write after transient error: %s", err)
+		<-rw.srv.clock().After(policy.BaseDelay << uint(i))
+	}
+}
+
+// isTransient returns whether a write error is caused by a temporary lack of
+// resources, such that retrying the write may succeed.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) ||
+		errors.Is(err, syscall.ENOMEM) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EWOULDBLOCK)
+}
+
 // FIXME(betawaffle)
 // Handler defines the interface an object needs to implement to handle DHCP
 // packets. The handler should do a type switch on the Message object that is
@@ -82,42 +312,18 @@ type Handler interface {
 	ServeDHCP(w ReplyWriter, p *Packet)
 }
 
-// Serve reads packets off the network and calls the specified handler.
-func Serve(pc PacketConn, h Handler) error {
-	buf := make([]byte, 65536)
-	for {
-		n, addr, ifindex, err := pc.ReadFrom(buf)
-		if err != nil {
-			return err
-		}
-
-		p, err := PacketFromBytes(buf[:n])
-		if err != nil {
-			clog.Warning(err)
-			continue
-		}
+// HandlerFunc is an adapter to use an ordinary function as Handler.
+type HandlerFunc func(w ReplyWriter, p *Packet)
 
-		// Filter everything but requests
-		if op := OpCode(p.Op()[0]); op != BootRequest {
-			clog.Warningf("ignoring op=%d mac=%s", op, p.GetCHAddr())
-			continue
-		}
-
-		a := addr.(*net.UDPAddr)
-		clog.Debug(&serverRecv{msg: &p, ip: a.IP, ifindex: ifindex})
-
-		var rw ReplyWriter
-		switch p.GetMessageType() {
-		case MessageTypeDiscover, MessageTypeRequest, MessageTypeInform:
-			rw = &replyWriter{
-				pw: pc,
+// ServeDHCP calls f(w, p).
+func (f HandlerFunc) ServeDHCP(w ReplyWriter, p *Packet) {
+	f(w, p)
+}
 
-				addr:    *a,
-				ifindex: ifindex,
-			}
-		}
-		h.ServeDHCP(rw, &p)
-	}
+// Serve reads packets off the network and calls the specified handler.
+func Serve(pc PacketConn, h Handler) error {
+	s := Server{Handler: h}
+	return s.Serve(pc)
 }
 
 func Listen(addr string) (PacketConn, error) {
@@ -144,26 +350,75 @@ func ListenAndServe(addr string, h Handler) error {
 	return Serve(c, h)
 }
 
+// NewPacketConnFromFile returns a PacketConn for the UDP socket f, like
+// NewPacketConn, for sockets bound by another process, e.g. with systemd
+// socket activation, so that the server doesn't need the privilege to bind
+// port 67. The socket is duplicated: the caller keeps ownership of f and may
+// close it, while closing the PacketConn closes the duplicate. This requires
+// a platform where net.FilePacketConn is supported, which excludes Windows.
+func NewPacketConnFromFile(f *os.File) (PacketConn, error) {
+	l, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewPacketConn(l)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ipv4Conn is the subset of ipv4.PacketConn used by packetConn.
+type ipv4Conn interface {
+	ReadFrom(b []byte) (int, *ipv4.ControlMessage, net.Addr, error)
+	WriteTo(b []byte, cm *ipv4.ControlMessage, dst net.Addr) (int, error)
+	SetControlMessage(cf ipv4.ControlFlags, on bool) error
+}
+
 type packetConn struct {
 	net.PacketConn
-	ipv4pc *ipv4.PacketConn
+	ipv4pc ipv4Conn
+
+	noIfIndex     bool
+	warnedIfIndex int32 // accessed atomically
 }
 
 // NewPacketConn returns a PacketConn based on the specified net.PacketConn.
 // It adds functionality to return the interface index from calls to ReadFrom
-// and include the interface index argument in calls to WriteTo.
+// and include the interface index argument in calls to WriteTo. It implements
+// ControlMessageReader, with the TTL and destination address of packets on
+// platforms that support them. On platforms that can't return the interface
+// index, a warning is logged and ReadFrom returns -1 or 0 as the index; see
+// InterfaceIndexSupporter. To find out, NewPacketConn sends a datagram to the
+// connection itself, and discards the packets that arrive before it.
 func NewPacketConn(pc net.PacketConn) (PacketConn, error) {
-	ipv4pc := ipv4.NewPacketConn(pc)
-	if err := ipv4pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
-		return nil, err
-	}
+	return newPacketConn(pc, ipv4.NewPacketConn(pc)), nil
+}
 
+// newPacketConn implements NewPacketConn, with ipv4pc wrapping pc.
+func newPacketConn(pc net.PacketConn, ipv4pc ipv4Conn) *packetConn {
 	p := packetConn{
 		PacketConn: pc,
 		ipv4pc:     ipv4pc,
 	}
 
-	return &p, nil
+	if err := ipv4pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		p.noInterfaceIndex()
+	}
+
+	// Optional; not all platforms support them
+	ipv4pc.SetControlMessage(ipv4.FlagTTL|ipv4.FlagDst, true)
+
+	if !p.noIfIndex {
+		if supported, ok := p.probeInterfaceIndex(); ok && !supported {
+			p.noInterfaceIndex()
+		}
+	}
+
+	return &p
 }
 
 // ReadFrom reads a packet from the connection copying the payload into b. It
@@ -175,7 +430,24 @@ func (p *packetConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
 		return n, src, -1, err
 	}
 
-	return n, src, cm.IfIndex, err
+	p.checkIfIndex(cm)
+	if cm == nil {
+		return n, src, -1, nil
+	}
+
+	return n, src, cm.IfIndex, nil
+}
+
+// ReadFromCM reads a packet from the connection copying the payload into b. It
+// returns the control message of the packet, which is nil if the platform
+// provides none.
+func (p *packetConn) ReadFromCM(b []byte) (int, net.Addr, *ipv4.ControlMessage, error) {
+	n, cm, src, err := p.ipv4pc.ReadFrom(b)
+	if err == nil {
+		p.checkIfIndex(cm)
+	}
+
+	return n, src, cm, err
 }
 
 // WriteTo writes a packet with payload b to addr. It explicitly sends the
@@ -187,3 +459,9 @@ func (p *packetConn) WriteTo(b []byte, addr net.Addr, ifindex int) (int, error)
 
 	return p.ipv4pc.WriteTo(b, cm, addr)
 }
+
+// WriteToCM writes a packet with payload b to addr, using the specified
+// control message.
+func (p *packetConn) WriteToCM(b []byte, addr net.Addr, cm *ipv4.ControlMessage) (int, error) {
+	return p.ipv4pc.WriteTo(b, cm, addr)
+}
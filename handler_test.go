@@ -4,10 +4,14 @@ import (
 	"errors"
 	"io"
 	"net"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/ipv4"
 )
 
 type testPacketConn struct {
@@ -101,10 +105,11 @@ func (r *testReply) Reply() *Packet {
 	return nil // FIXME
 }
 
-func (r *testReply) SetCIAddr(ip net.IP) {}
-func (r *testReply) SetYIAddr(ip net.IP) {}
-func (r *testReply) SetSIAddr(ip net.IP) {}
-func (r *testReply) SetGIAddr(ip net.IP) {}
+func (r *testReply) SetCHAddr(hw net.HardwareAddr) error { return nil }
+func (r *testReply) SetCIAddr(ip net.IP) error           { return nil }
+func (r *testReply) SetYIAddr(ip net.IP) error           { return nil }
+func (r *testReply) SetSIAddr(ip net.IP) error           { return nil }
+func (r *testReply) SetGIAddr(ip net.IP) error           { return nil }
 
 func TestReplyWriterReturnsValidationError(t *testing.T) {
 	validationError := errors.New("some validation error")
@@ -182,6 +187,376 @@ func TestReplyWriterDestinationAddress(t *testing.T) {
 	}
 }
 
+func TestReplyWriterUnicastsToClientAddress(t *testing.T) {
+	inform := NewPacket(BootRequest)
+	inform.SetMessageType(MessageTypeInform)
+	inform.SetCIAddr(net.IP{10, 0, 0, 5})
+
+	// Neither an unspecified source address nor the broadcast flag cause a
+	// broadcast reply
+	withBcast := NewPacket(BootRequest)
+	withBcast.SetMessageType(MessageTypeInform)
+	withBcast.SetCIAddr(net.IP{10, 0, 0, 5})
+	withBcast.Flags()[0] |= 128
+
+	for _, msg := range []*Packet{&inform, &withBcast} {
+		r := testReply{}
+		r.On("Validate").Return(nil)
+		r.On("ToBytes").Return([]byte("xyz"), nil)
+		r.On("Message").Return(msg)
+
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(3, nil)
+
+		rw := replyWriter{
+			pw:   pw,
+			addr: net.UDPAddr{IP: net.IPv4zero, Port: 68},
+		}
+
+		assert.NoError(t, rw.WriteReply(&r))
+
+		expected := net.UDPAddr{IP: net.IP{10, 0, 0, 5}, Port: ClientPort}
+		actual := *pw.Calls[0].Arguments[1].(*net.UDPAddr)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestReplyWriterBroadcastsNakToClientAddress(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetCIAddr(net.IPv4(10, 0, 0, 5))
+
+	nak := CreateNak(&req)
+	nak.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+
+	rw := replyWriter{
+		pw:   pw,
+		srv:  &Server{},
+		addr: net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: ClientPort},
+	}
+	assert.NoError(t, rw.WriteReply(&nak))
+
+	// Broadcast, not unicast to 'ciaddr' (RFC2131, section 4.1)
+	expected := net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+	actual := *pw.Calls[0].Arguments[1].(*net.UDPAddr)
+	assert.Equal(t, expected, actual)
+}
+
+func TestReplyWriterRelayPreservesBroadcastFlag(t *testing.T) {
+	giaddr := net.IPv4(10, 1, 0, 1)
+
+	for _, bcast := range []bool{false, true} {
+		req := NewPacket(BootRequest)
+		req.SetMessageType(MessageTypeDiscover)
+		req.SetGIAddr(giaddr)
+		if bcast {
+			req.Flags()[0] |= 128
+		}
+
+		offer := CreateOffer(&req)
+		offer.SetDuration(OptionAddressTime, time.Hour)
+		offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+		// The handler's flags don't matter
+		offer.Flags()[0] ^= 128
+
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+		rw := replyWriter{pw: pw, srv: &Server{}, addr: net.UDPAddr{IP: giaddr, Port: ServerPort}}
+
+		if !assert.NoError(t, rw.WriteReply(&offer)) {
+			return
+		}
+
+		addr := pw.Calls[0].Arguments.Get(1).(*net.UDPAddr)
+		assert.True(t, giaddr.Equal(addr.IP))
+
+		rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+		if assert.NoError(t, err) {
+			assert.Equal(t, bcast, rep.GetFlags()[0]&128 > 0, "broadcast flag")
+		}
+	}
+}
+
+func TestReplyWriterRelayedNakBroadcastFlag(t *testing.T) {
+	giaddr := net.IPv4(10, 1, 0, 1)
+
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetGIAddr(giaddr)
+
+	nak := CreateNak(&req)
+	nak.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: &Server{}, addr: net.UDPAddr{IP: giaddr, Port: ServerPort}}
+
+	if !assert.NoError(t, rw.WriteReply(&nak)) {
+		return
+	}
+
+	// Set although the request's flag is clear (RFC2131, section 4.3.2)
+	rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+	if assert.NoError(t, err) {
+		assert.True(t, rep.GetFlags()[0]&128 > 0, "broadcast flag")
+	}
+}
+
+func TestReplyWriterRelayedInform(t *testing.T) {
+	giaddr := net.IPv4(10, 1, 0, 1)
+
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeInform)
+	req.SetGIAddr(giaddr)
+	req.SetCIAddr(net.IPv4(10, 1, 0, 5))
+	req.Flags()[0] |= 128
+
+	ack := CreateAck(&req)
+	ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+
+	// The relay agent may send from another port than the server port
+	rw := replyWriter{pw: pw, srv: &Server{}, addr: net.UDPAddr{IP: giaddr, Port: 1067}}
+	if !assert.NoError(t, rw.WriteReply(&ack)) {
+		return
+	}
+
+	// Sent to the relay agent, not to 'ciaddr'
+	addr := pw.Calls[0].Arguments.Get(1).(*net.UDPAddr)
+	assert.Equal(t, giaddr.To4(), addr.IP.To4())
+	assert.Equal(t, ServerPort, addr.Port)
+
+	rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+	if assert.NoError(t, err) {
+		assert.True(t, rep.GetFlags()[0]&128 > 0, "broadcast flag")
+	}
+}
+
+func TestReplyWriterRejectsLinkLocalYIAddr(t *testing.T) {
+	discover := NewPacket(BootRequest)
+	discover.SetMessageType(MessageTypeDiscover)
+	offer := CreateOffer(&discover)
+	offer.SetDuration(OptionAddressTime, time.Hour)
+
+	request := NewPacket(BootRequest)
+	request.SetMessageType(MessageTypeRequest)
+	ack := CreateAck(&request)
+	ack.SetDuration(OptionAddressTime, time.Hour)
+
+	for _, r := range []Reply{&offer, &ack} {
+		r.SetYIAddr(net.IPv4(169, 254, 0, 5))
+		r.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+		pw := &testPacketConn{}
+		rw := replyWriter{pw: pw, srv: &Server{}, addr: net.UDPAddr{IP: net.IPv4zero, Port: ClientPort}}
+		assert.Equal(t, ErrLinkLocalAddress, rw.WriteReply(r))
+		pw.AssertNotCalled(t, "WriteTo", mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestReplyWriterRelayPort(t *testing.T) {
+	giaddr := net.IPv4(10, 1, 0, 1)
+
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	req.SetGIAddr(giaddr)
+
+	offer := CreateOffer(&req)
+	offer.SetDuration(OptionAddressTime, time.Hour)
+	offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+
+	rw := replyWriter{pw: pw, srv: &Server{RelayPort: 1067}, addr: net.UDPAddr{IP: giaddr, Port: ServerPort}}
+	assert.NoError(t, rw.WriteReply(&offer))
+
+	// Replies to clients are unaffected
+	req = NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	offer = CreateOffer(&req)
+	offer.SetDuration(OptionAddressTime, time.Hour)
+	offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	rw.addr = net.UDPAddr{IP: net.IPv4zero, Port: ClientPort}
+	assert.NoError(t, rw.WriteReply(&offer))
+
+	if assert.Len(t, pw.Calls, 2) {
+		addr := pw.Calls[0].Arguments.Get(1).(*net.UDPAddr)
+		assert.Equal(t, giaddr.To4(), addr.IP.To4())
+		assert.Equal(t, 1067, addr.Port)
+
+		addr = pw.Calls[1].Arguments.Get(1).(*net.UDPAddr)
+		assert.Equal(t, ClientPort, addr.Port)
+	}
+}
+
+func TestReplyWriterRetriesTransientErrors(t *testing.T) {
+	msg := NewPacket(BootRequest)
+	transient := &net.OpError{Op: "write", Err: os.NewSyscallError("sendmsg", syscall.ENOBUFS)}
+	permanent := &net.OpError{Op: "write", Err: os.NewSyscallError("sendmsg", syscall.EHOSTUNREACH)}
+
+	s := &Server{
+		WriteRetry: &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	}
+
+	newReply := func() *testReply {
+		r := &testReply{}
+		r.On("Validate").Return(nil)
+		r.On("ToBytes").Return([]byte("xyz"), nil)
+		r.On("Message").Return(&msg)
+		return r
+	}
+
+	// Transient errors are retried
+	{
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, transient).Twice()
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(3, nil).Once()
+
+		rw := replyWriter{pw: pw, srv: s}
+		assert.NoError(t, rw.WriteReply(newReply()))
+		pw.AssertNumberOfCalls(t, "WriteTo", 3)
+	}
+
+	// Up to the maximum number of retries
+	{
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, transient)
+
+		rw := replyWriter{pw: pw, srv: s}
+		assert.Equal(t, transient, rw.WriteReply(newReply()))
+		pw.AssertNumberOfCalls(t, "WriteTo", 3)
+	}
+
+	// Permanent errors are not retried
+	{
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, permanent)
+
+		rw := replyWriter{pw: pw, srv: s}
+		assert.Equal(t, permanent, rw.WriteReply(newReply()))
+		pw.AssertNumberOfCalls(t, "WriteTo", 1)
+	}
+}
+
+func TestReplyWriterLeaseGranted(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetCHAddr(net.HardwareAddr{1, 2, 3, 4, 5, 6})
+	req.SetMessageType(MessageTypeRequest)
+	req.SetString(OptionHostname, "host")
+	req.SetClientFQDN(ClientFQDN{Flags: FQDNFlagE | FQDNFlagS, Name: "host.example.com"})
+
+	var leases []Lease
+	var fqdns []*ClientFQDN
+	s := &Server{
+		OnLeaseGranted: func(l Lease, f *ClientFQDN) {
+			leases = append(leases, l)
+			fqdns = append(fqdns, f)
+		},
+	}
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: s}
+
+	ack := CreateAck(&req)
+	ack.SetYIAddr(net.IPv4(10, 0, 0, 5))
+	ack.SetDuration(OptionAddressTime, time.Hour)
+	ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	assert.NoError(t, rw.WriteReply(&ack))
+
+	// A DHCPNAK doesn't grant a lease
+	nak := CreateNak(&req)
+	nak.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	assert.NoError(t, rw.WriteReply(&nak))
+
+	if assert.Len(t, leases, 1) {
+		l := leases[0]
+		assert.Equal(t, net.IP{10, 0, 0, 5}, l.IP)
+		assert.Equal(t, net.HardwareAddr{1, 2, 3, 4, 5, 6}, l.HardwareAddr)
+		assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6}, l.ClientID)
+		assert.Equal(t, "host", l.Hostname)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), l.Expiry, time.Minute)
+
+		if assert.NotNil(t, fqdns[0]) {
+			assert.Equal(t, "host.example.com", fqdns[0].Name)
+			assert.True(t, fqdns[0].ServerUpdatesA())
+		}
+	}
+}
+
+type testCMPacketConn struct {
+	testPacketConn
+}
+
+func (pc *testCMPacketConn) WriteToCM(b []byte, addr net.Addr, cm *ipv4.ControlMessage) (n int, err error) {
+	args := pc.Called(b, addr, cm)
+	return args.Int(0), args.Error(1)
+}
+
+func TestReplyWriterSourceAddress(t *testing.T) {
+	msg := NewPacket(BootRequest)
+	src := net.IP{10, 0, 0, 1}
+
+	s := &Server{}
+	s.SetInterfaceSource(2, src)
+
+	// Interface with a source address uses the control message
+	{
+		r := testReply{}
+		r.On("Validate").Return(nil)
+		r.On("ToBytes").Return([]byte("xyz"), nil)
+		r.On("Message").Return(&msg)
+
+		pw := &testCMPacketConn{}
+		pw.On("WriteToCM", mock.Anything, mock.Anything, mock.Anything).Return(3, nil)
+
+		rw := replyWriter{
+			pw:      pw,
+			srv:     s,
+			ifindex: 2,
+		}
+
+		err := rw.WriteReply(&r)
+		assert.NoError(t, err)
+
+		cm := pw.Calls[0].Arguments[2].(*ipv4.ControlMessage)
+		assert.Equal(t, 2, cm.IfIndex)
+		assert.Equal(t, src, cm.Src)
+	}
+
+	// Interface without a source address doesn't
+	{
+		r := testReply{}
+		r.On("Validate").Return(nil)
+		r.On("ToBytes").Return([]byte("xyz"), nil)
+		r.On("Message").Return(&msg)
+
+		pw := &testCMPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, 3).Return(3, nil)
+
+		rw := replyWriter{
+			pw:      pw,
+			srv:     s,
+			ifindex: 3,
+		}
+
+		err := rw.WriteReply(&r)
+		assert.NoError(t, err)
+		pw.AssertExpectations(t)
+	}
+
+	// Removing the source address falls back to the regular write
+	s.SetInterfaceSource(2, nil)
+	assert.Nil(t, s.interfaceSource(2))
+}
+
 type testHandler struct {
 	mock.Mock
 }
@@ -231,3 +606,127 @@ func TestServeFiltersNonMessages(t *testing.T) {
 		h.AssertNotCalled(t, "ServeDHCP", mock.Anything, mock.Anything)
 	}
 }
+
+func TestServeDispatchesOverloadedMessageType(t *testing.T) {
+	// Fabricate request with the message type in the `file` field
+	p := new(testPacket)
+	p.appendToOption(OptionOverload, []byte{0x1})
+	p.appendToOption(OptionEnd, nil)
+	p.appendToFile(OptionDHCPMsgType, []byte{byte(MessageTypeDiscover)})
+	p.appendToFile(OptionEnd, nil)
+	p.buf[0] = byte(BootRequest)
+	copy(p.buf[236:240], []byte{99, 130, 83, 99})
+
+	pc := &testPacketConn{}
+	pc.ReadSuccess(p.buf)
+	pc.ReadError(io.EOF)
+
+	h := &testHandler{}
+	h.On("ServeDHCP", mock.Anything, mock.Anything).Return()
+	Serve(pc, h)
+
+	if h.AssertNumberOfCalls(t, "ServeDHCP", 1) {
+		rw := h.Calls[0].Arguments.Get(0)
+		msg := h.Calls[0].Arguments.Get(1).(*Packet)
+		assert.Equal(t, MessageTypeDiscover, msg.GetMessageType())
+		assert.NotNil(t, rw, "expected a reply writer for a DHCPDISCOVER")
+	}
+}
+
+func TestNewPacketConnFromFile(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	f, err := l.(*net.UDPConn).File()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pc, err := NewPacketConnFromFile(f)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pc.Close()
+
+	// The PacketConn has its own duplicate of the socket
+	addr := l.LocalAddr()
+	l.Close()
+	f.Close()
+	assert.Equal(t, addr.String(), pc.LocalAddr().String())
+
+	sender, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sender.Close()
+
+	sender.WriteTo([]byte("xyz"), addr)
+
+	pc.(ClientConn).SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 16)
+	n, _, _, err := pc.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz", string(buf[:n]))
+}
+
+func TestServerControlMessage(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pc, err := NewPacketConn(l)
+	if !assert.NoError(t, err) {
+		l.Close()
+		return
+	}
+	defer pc.Close()
+
+	sender, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sender.Close()
+
+	cms := make(chan *ipv4.ControlMessage, 1)
+	s := Server{Handler: HandlerFunc(func(w ReplyWriter, p *Packet) {
+		cms <- p.ControlMessage()
+	})}
+	go s.Serve(pc)
+
+	sender.WriteTo(testRequestBytes(t, MessageTypeInform), l.LocalAddr())
+
+	select {
+	case cm := <-cms:
+		if assert.NotNil(t, cm) {
+			assert.NotZero(t, cm.IfIndex)
+			assert.True(t, cm.Dst.Equal(net.IPv4(127, 0, 0, 1)), "destination %s", cm.Dst)
+			assert.NotZero(t, cm.TTL)
+		}
+	case <-time.After(time.Second):
+		t.Error("request not served")
+	}
+}
+
+func TestReplyWriterReplyPort(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	offer := CreateOffer(&req)
+	offer.SetDuration(OptionAddressTime, time.Hour)
+	offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{
+		pw:   pw,
+		srv:  &Server{ReplyPort: 10067},
+		addr: net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: ClientPort},
+	}
+
+	assert.NoError(t, rw.WriteReply(&offer))
+	addr := pw.Calls[0].Arguments.Get(1).(*net.UDPAddr)
+	assert.Equal(t, 10067, addr.Port)
+}
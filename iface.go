@@ -0,0 +1,87 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	ErrNoInterface         = errors.New("dhcp4: no such network interface")
+	ErrInterfaceNotAllowed = errors.New("dhcp4: packet on network interface that is not allowed")
+)
+
+// ifaceNames caches the names of network interfaces by index.
+var ifaceNames = struct {
+	sync.RWMutex
+	m map[int]string
+}{}
+
+// For testing: returns the system's network interfaces.
+var netInterfaces = net.Interfaces
+
+// InterfaceName returns the name of the network interface with index
+// ifindex. Names are cached, so it can be called for every packet. The cache
+// is refreshed when an index is not found, which picks up interfaces that
+// appeared and drops interfaces that disappeared since the last refresh.
+func InterfaceName(ifindex int) (string, error) {
+	// Interface indexes start at 1; packets without index have -1
+	if ifindex <= 0 {
+		return "", ErrNoInterface
+	}
+
+	ifaceNames.RLock()
+	name, ok := ifaceNames.m[ifindex]
+	ifaceNames.RUnlock()
+	if ok {
+		return name, nil
+	}
+
+	ifaces, err := netInterfaces()
+	if err != nil {
+		return "", err
+	}
+
+	m := make(map[int]string, len(ifaces))
+	for _, iface := range ifaces {
+		m[iface.Index] = iface.Name
+	}
+
+	ifaceNames.Lock()
+	ifaceNames.m = m
+	ifaceNames.Unlock()
+
+	if name, ok := m[ifindex]; ok {
+		return name, nil
+	}
+
+	return "", ErrNoInterface
+}
+
+// InterfaceIndexes returns the indexes of the network interfaces with the
+// specified names, e.g. for Server.AllowedInterfaces. It returns
+// ErrNoInterface if an interface doesn't exist.
+func InterfaceIndexes(names ...string) ([]int, error) {
+	ifaces, err := netInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, iface := range ifaces {
+			if iface.Name == name {
+				indexes = append(indexes, iface.Index)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, ErrNoInterface
+		}
+	}
+
+	return indexes, nil
+}
@@ -0,0 +1,61 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceName(t *testing.T) {
+	defer func(f func() ([]net.Interface, error)) { netInterfaces = f }(netInterfaces)
+
+	calls := 0
+	ifaces := []net.Interface{{Index: 1, Name: "lo"}, {Index: 2, Name: "eth0"}}
+	netInterfaces = func() ([]net.Interface, error) {
+		calls++
+		return ifaces, nil
+	}
+
+	name, err := InterfaceName(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "eth0", name)
+
+	// Cached
+	name, err = InterfaceName(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "lo", name)
+	assert.Equal(t, 1, calls)
+
+	// New interfaces are found by refreshing the cache
+	ifaces = []net.Interface{{Index: 1, Name: "lo"}, {Index: 3, Name: "eth1"}}
+	name, err = InterfaceName(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "eth1", name)
+	assert.Equal(t, 2, calls)
+
+	// Interfaces that disappeared are dropped from the cache
+	_, err = InterfaceName(2)
+	assert.Equal(t, ErrNoInterface, err)
+	assert.Equal(t, 3, calls)
+
+	// Without refreshing for invalid indexes
+	_, err = InterfaceName(-1)
+	assert.Equal(t, ErrNoInterface, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestInterfaceIndexes(t *testing.T) {
+	defer func(f func() ([]net.Interface, error)) { netInterfaces = f }(netInterfaces)
+
+	netInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{{Index: 1, Name: "lo"}, {Index: 2, Name: "eth0"}}, nil
+	}
+
+	indexes, err := InterfaceIndexes("eth0", "lo")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, indexes)
+
+	_, err = InterfaceIndexes("eth0", "eth1")
+	assert.Equal(t, ErrNoInterface, err)
+}
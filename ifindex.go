@@ -0,0 +1,124 @@
+package dhcp4
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+var (
+	ErrInterfaceIndexUnsupported = errors.New("dhcp4: the connection doesn't report the network interface of packets")
+)
+
+// InterfaceIndexSupporter is implemented by a PacketConn that can tell
+// whether it returns the index of the network interface packets arrive on.
+// Platforms that don't support it, or that don't fill it in the control
+// message, as observed on some BSD variants, make ReadFrom return an index
+// of 0 or -1 for every packet. NewPacketConn probes for both.
+type InterfaceIndexSupporter interface {
+	SupportsInterfaceIndex() bool
+}
+
+// SupportsInterfaceIndex returns whether the connection returns the index of
+// the network interface packets arrive on, as probed by NewPacketConn.
+func (p *packetConn) SupportsInterfaceIndex() bool {
+	return !p.noIfIndex
+}
+
+// ifIndexProbe is the payload of the datagram probing interface indexes,
+// which is not a valid DHCP packet.
+var ifIndexProbe = []byte("dhcp4: interface index probe")
+
+// ifIndexProbeTimeout is the time to wait for the probe datagram.
+const ifIndexProbeTimeout = 100 * time.Millisecond
+
+// probeInterfaceIndex sends a datagram to the connection itself, over the
+// loopback interface if the connection is bound to all addresses, and returns
+// whether its control message includes the interface index. Setting the
+// control message flag succeeds on some platforms that then leave the index
+// 0. It returns ok false if the datagram doesn't arrive within
+// ifIndexProbeTimeout, or can't be sent. Packets that arrive before it are
+// discarded.
+func (p *packetConn) probeInterfaceIndex() (supported, ok bool) {
+	la, isUDP := p.LocalAddr().(*net.UDPAddr)
+	if !isUDP {
+		return false, false
+	}
+
+	dst := &net.UDPAddr{IP: la.IP, Port: la.Port}
+	if dst.IP == nil || dst.IP.IsUnspecified() {
+		dst.IP = net.IPv4(127, 0, 0, 1)
+	}
+
+	if _, err := p.ipv4pc.WriteTo(ifIndexProbe, nil, dst); err != nil {
+		return false, false
+	}
+
+	if err := p.PacketConn.SetReadDeadline(time.Now().Add(ifIndexProbeTimeout)); err != nil {
+		return false, false
+	}
+	defer p.PacketConn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 1500)
+	for {
+		n, cm, _, err := p.ipv4pc.ReadFrom(buf)
+		if err != nil {
+			return false, false
+		}
+
+		if bytes.Equal(buf[:n], ifIndexProbe) {
+			return cm != nil && cm.IfIndex > 0, true
+		}
+	}
+}
+
+// checkIfIndex logs a warning the first time the control message cm of a
+// packet doesn't include the interface index. Such packets are not allowed by
+// Server.AllowedInterfaces, and replies to them are sent from the address the
+// kernel picks.
+func (p *packetConn) checkIfIndex(cm *ipv4.ControlMessage) {
+	if cm != nil && cm.IfIndex > 0 {
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&p.warnedIfIndex, 0, 1) {
+		clog.Warning("dhcp4: packet without network interface index; replies are sent on the interface the kernel picks")
+	}
+}
+
+// noInterfaceIndex marks the connection as not supporting interface indexes,
+// logging a warning.
+func (p *packetConn) noInterfaceIndex() {
+	p.noIfIndex = true
+	clog.Warning("dhcp4: the platform doesn't report the network interface of packets; replies are sent on the interface the kernel picks")
+}
+
+// needsInterfaceIndex returns whether the server uses features that depend on
+// the network interface of packets: AllowedInterfaces and interface sources.
+func (s *Server) needsInterfaceIndex() bool {
+	if s.AllowedInterfaces != nil {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sources) > 0
+}
+
+// checkInterfaceIndex returns ErrInterfaceIndexUnsupported if the server needs
+// the network interface of packets and ir reports that it is not supported.
+func (s *Server) checkInterfaceIndex(ir InterfaceIndexSupporter) error {
+	if ir == nil || ir.SupportsInterfaceIndex() {
+		return nil
+	}
+
+	if s.needsInterfaceIndex() {
+		return ErrInterfaceIndexUnsupported
+	}
+
+	return nil
+}
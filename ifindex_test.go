@@ -0,0 +1,133 @@
+package dhcp4
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/ipv4"
+)
+
+type testIfIndexPacketConn struct {
+	*testPacketConn
+	supported bool
+}
+
+func (pc *testIfIndexPacketConn) SupportsInterfaceIndex() bool {
+	return pc.supported
+}
+
+func TestPacketConnSupportsInterfaceIndex(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := NewPacketConn(l)
+	if !assert.NoError(t, err) {
+		l.Close()
+		return
+	}
+	defer c.Close()
+
+	pc := c.(*packetConn)
+	assert.True(t, pc.SupportsInterfaceIndex())
+
+	pc.checkIfIndex(&ipv4.ControlMessage{IfIndex: 1})
+	assert.True(t, pc.SupportsInterfaceIndex())
+
+	// A packet without interface index doesn't mark the connection
+	pc.checkIfIndex(&ipv4.ControlMessage{})
+	pc.checkIfIndex(nil)
+	assert.True(t, pc.SupportsInterfaceIndex())
+
+	pc.noInterfaceIndex()
+	assert.False(t, pc.SupportsInterfaceIndex())
+}
+
+// noIfIndexConn behaves like the platforms where setting the control message
+// flag succeeds, but the interface index is left 0.
+type noIfIndexConn struct {
+	*ipv4.PacketConn
+}
+
+func (c noIfIndexConn) ReadFrom(b []byte) (int, *ipv4.ControlMessage, net.Addr, error) {
+	n, cm, src, err := c.PacketConn.ReadFrom(b)
+	if cm != nil {
+		cm.IfIndex = 0
+	}
+	return n, cm, src, err
+}
+
+func TestPacketConnProbesInterfaceIndex(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+
+	// A client packet arriving first is discarded
+	c, err := net.Dial("udp4", l.LocalAddr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+	c.Write([]byte("client"))
+
+	pc := newPacketConn(l, noIfIndexConn{ipv4.NewPacketConn(l)})
+	assert.False(t, pc.SupportsInterfaceIndex())
+
+	s := &Server{AllowedInterfaces: []int{1}}
+	assert.Equal(t, ErrInterfaceIndexUnsupported, s.Serve(pc))
+
+	// The probe datagram is consumed
+	pc = newPacketConn(l, ipv4.NewPacketConn(l))
+	assert.True(t, pc.SupportsInterfaceIndex())
+
+	l.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, _, _, err = pc.ReadFrom(make([]byte, 1500))
+	if ne, ok := err.(net.Error); assert.True(t, ok) {
+		assert.True(t, ne.Timeout())
+	}
+}
+
+func TestServerInterfaceIndexUnsupported(t *testing.T) {
+	// Fails before reading if the server needs interface indexes
+	pc := &testIfIndexPacketConn{testPacketConn: &testPacketConn{}}
+	s := &Server{AllowedInterfaces: []int{1}}
+	assert.Equal(t, ErrInterfaceIndexUnsupported, s.Serve(pc))
+	pc.AssertNotCalled(t, "ReadFrom", mock.Anything)
+
+	s = &Server{}
+	s.SetInterfaceSource(1, net.IPv4(10, 0, 0, 1))
+	assert.Equal(t, ErrInterfaceIndexUnsupported, s.Serve(pc))
+
+	// A packet without interface index is dropped, and the server keeps
+	// serving
+	pc = &testIfIndexPacketConn{testPacketConn: &testPacketConn{}, supported: true}
+	pc.On("ReadFrom", mock.Anything).Return(testRequestBytes(t, MessageTypeDiscover), &net.UDPAddr{}, 0, nil).Once()
+	pc.On("ReadFrom", mock.Anything).Return(testRequestBytes(t, MessageTypeInform), &net.UDPAddr{}, 1, nil).Once()
+	pc.ReadError(io.EOF)
+
+	var handled []MessageType
+	s = &Server{
+		Handler:           HandlerFunc(func(w ReplyWriter, p *Packet) { handled = append(handled, p.GetMessageType()) }),
+		AllowedInterfaces: []int{1},
+		ErrorHandler:      func(*Packet, error) {},
+	}
+	assert.Equal(t, io.EOF, s.Serve(pc))
+	assert.Equal(t, []MessageType{MessageTypeInform}, handled)
+
+	// Servers that don't need them keep serving
+	pc = &testIfIndexPacketConn{testPacketConn: &testPacketConn{}}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeInform))
+	pc.ReadError(io.EOF)
+
+	handled = nil
+	s = &Server{Handler: HandlerFunc(func(w ReplyWriter, p *Packet) { handled = append(handled, p.GetMessageType()) })}
+	assert.Equal(t, io.EOF, s.Serve(pc))
+	assert.Equal(t, []MessageType{MessageTypeInform}, handled)
+}
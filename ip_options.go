@@ -0,0 +1,128 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrInvalidMTU = errors.New("dhcp4: MTU smaller than 68")
+	ErrInvalidTTL = errors.New("dhcp4: TTL must be at least 1")
+)
+
+// Accessors for the IP layer parameters of RFC2132, sections 4 and 5. Their
+// values are checked against the kinds registered for the options.
+
+// GetIPForwarding gets whether the client should forward IP packets (19).
+func (om OptionMap) GetIPForwarding() (bool, bool) {
+	return om.GetBool(OptionForwardOnOff)
+}
+
+// SetIPForwarding sets whether the client should forward IP packets (19).
+func (om OptionMap) SetIPForwarding(v bool) error {
+	return om.SetBool(OptionForwardOnOff, v)
+}
+
+// GetNonLocalSourceRouting gets whether the client should forward datagrams
+// with non-local source routes (20).
+func (om OptionMap) GetNonLocalSourceRouting() (bool, bool) {
+	return om.GetBool(OptionSrcRteOnOff)
+}
+
+// SetNonLocalSourceRouting sets whether the client should forward datagrams
+// with non-local source routes (20).
+func (om OptionMap) SetNonLocalSourceRouting(v bool) error {
+	return om.SetBool(OptionSrcRteOnOff, v)
+}
+
+// GetMaxDatagramReassembly gets the maximum size of datagram the client
+// should reassemble (22).
+func (om OptionMap) GetMaxDatagramReassembly() (uint16, bool) {
+	return om.GetUint16(OptionMaxDGAssembly)
+}
+
+// SetMaxDatagramReassembly sets the maximum size of datagram the client
+// should reassemble (22). The minimum is 576.
+func (om OptionMap) SetMaxDatagramReassembly(v uint16) error {
+	if v < 576 {
+		return &OptionValueError{Option: OptionMaxDGAssembly, Kind: KindUint16}
+	}
+
+	return om.SetUint16(OptionMaxDGAssembly, v)
+}
+
+// GetDefaultIPTTL gets the default TTL of outgoing datagrams (23).
+func (om OptionMap) GetDefaultIPTTL() (uint8, bool) {
+	return om.GetUint8(OptionDefaultIPTTL)
+}
+
+// SetDefaultIPTTL sets the default TTL of outgoing datagrams (23). It returns
+// ErrInvalidTTL for 0.
+func (om OptionMap) SetDefaultIPTTL(v uint8) error {
+	if v == 0 {
+		return ErrInvalidTTL
+	}
+
+	return om.SetUint8(OptionDefaultIPTTL, v)
+}
+
+// GetInterfaceMTU gets the MTU of the client's interface (26).
+func (om OptionMap) GetInterfaceMTU() (uint16, bool) {
+	return om.GetUint16(OptionMTUInterface)
+}
+
+// SetInterfaceMTU sets the MTU of the client's interface (26). It returns
+// ErrInvalidMTU if v is smaller than 68, the minimum MTU.
+func (om OptionMap) SetInterfaceMTU(v uint16) error {
+	if v < 68 {
+		return ErrInvalidMTU
+	}
+
+	return om.SetUint16(OptionMTUInterface, v)
+}
+
+// GetAllSubnetsLocal gets whether all subnets of the client's network use the
+// same MTU (27).
+func (om OptionMap) GetAllSubnetsLocal() (bool, bool) {
+	return om.GetBool(OptionMTUSubnet)
+}
+
+// SetAllSubnetsLocal sets whether all subnets of the client's network use the
+// same MTU (27).
+func (om OptionMap) SetAllSubnetsLocal(v bool) error {
+	return om.SetBool(OptionMTUSubnet, v)
+}
+
+// GetBroadcastAddress gets the broadcast address of the client's subnet (28).
+func (om OptionMap) GetBroadcastAddress() (net.IP, bool) {
+	return om.GetIP(OptionBroadcastAddress)
+}
+
+// SetBroadcastAddress sets the broadcast address of the client's subnet (28).
+func (om OptionMap) SetBroadcastAddress(v net.IP) error {
+	return om.SetIP(OptionBroadcastAddress, v)
+}
+
+// GetPerformMaskDiscovery gets whether the client should perform subnet mask
+// discovery using ICMP (29).
+func (om OptionMap) GetPerformMaskDiscovery() (bool, bool) {
+	return om.GetBool(OptionMaskDiscovery)
+}
+
+// SetPerformMaskDiscovery sets whether the client should perform subnet mask
+// discovery using ICMP (29).
+func (om OptionMap) SetPerformMaskDiscovery(v bool) error {
+	return om.SetBool(OptionMaskDiscovery, v)
+}
+
+// GetRouterDiscovery gets whether the client should solicit routers using
+// router discovery (31).
+func (om OptionMap) GetRouterDiscovery() (bool, bool) {
+	return om.GetBool(OptionRouterDiscovery)
+}
+
+// SetRouterDiscovery sets whether the client should solicit routers using
+// router discovery (31).
+func (om OptionMap) SetRouterDiscovery(v bool) error {
+	return om.SetBool(OptionRouterDiscovery, v)
+}
@@ -0,0 +1,66 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPLayerOptions(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.NoError(t, om.SetIPForwarding(true))
+	assert.NoError(t, om.SetNonLocalSourceRouting(false))
+	assert.NoError(t, om.SetMaxDatagramReassembly(1500))
+	assert.NoError(t, om.SetDefaultIPTTL(64))
+	assert.NoError(t, om.SetInterfaceMTU(9000))
+	assert.NoError(t, om.SetAllSubnetsLocal(true))
+	assert.NoError(t, om.SetBroadcastAddress(net.IPv4(10, 0, 0, 255)))
+	assert.NoError(t, om.SetPerformMaskDiscovery(false))
+	assert.NoError(t, om.SetRouterDiscovery(true))
+
+	v, _ := om.GetOption(OptionForwardOnOff)
+	assert.Equal(t, []byte{1}, v)
+	v, _ = om.GetOption(OptionMTUInterface)
+	assert.Equal(t, []byte{0x23, 0x28}, v)
+
+	b, ok := om.GetIPForwarding()
+	assert.True(t, ok)
+	assert.True(t, b)
+	b, ok = om.GetNonLocalSourceRouting()
+	assert.True(t, ok)
+	assert.False(t, b)
+	n16, _ := om.GetMaxDatagramReassembly()
+	assert.Equal(t, uint16(1500), n16)
+	ttl, _ := om.GetDefaultIPTTL()
+	assert.Equal(t, uint8(64), ttl)
+	mtu, _ := om.GetInterfaceMTU()
+	assert.Equal(t, uint16(9000), mtu)
+	b, _ = om.GetAllSubnetsLocal()
+	assert.True(t, b)
+	ip, _ := om.GetBroadcastAddress()
+	assert.Equal(t, net.IPv4(10, 0, 0, 255), ip)
+	b, ok = om.GetPerformMaskDiscovery()
+	assert.True(t, ok)
+	assert.False(t, b)
+	b, _ = om.GetRouterDiscovery()
+	assert.True(t, b)
+}
+
+func TestIPLayerOptionsInvalid(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.Equal(t, ErrInvalidMTU, om.SetInterfaceMTU(67))
+	assert.Equal(t, ErrInvalidTTL, om.SetDefaultIPTTL(0))
+	assert.Error(t, om.SetMaxDatagramReassembly(575))
+
+	// Values not matching the registered kind
+	om.SetOption(OptionForwardOnOff, []byte{2})
+	_, ok := om.GetIPForwarding()
+	assert.False(t, ok)
+
+	om.SetOption(OptionMTUInterface, []byte{1})
+	_, ok = om.GetInterfaceMTU()
+	assert.False(t, ok)
+}
@@ -0,0 +1,131 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrInvalidRange = errors.New("dhcp4: invalid address range")
+)
+
+// IPRange is an inclusive range of IPv4 addresses.
+type IPRange struct {
+	Start, End net.IP
+}
+
+// bounds returns the range as integers, and whether it is a valid range.
+func (r IPRange) bounds() (start, end uint32, ok bool) {
+	start, ok1 := ipToUint32(r.Start)
+	end, ok2 := ipToUint32(r.End)
+	return start, end, ok1 && ok2 && start <= end
+}
+
+// Contains returns whether ip is in the range.
+func (r IPRange) Contains(ip net.IP) bool {
+	start, end, ok := r.bounds()
+	v, ok2 := ipToUint32(ip)
+	return ok && ok2 && v >= start && v <= end
+}
+
+// Next returns the address following ip in the range, or false if ip is the
+// end of the range or not in the range.
+func (r IPRange) Next(ip net.IP) (net.IP, bool) {
+	if !r.Contains(ip) {
+		return nil, false
+	}
+
+	v, _ := ipToUint32(ip)
+	_, end, _ := r.bounds()
+	if v == end {
+		return nil, false
+	}
+
+	return uint32ToIP(v + 1), true
+}
+
+// Size returns the number of addresses in the range, or 0 if it is invalid.
+func (r IPRange) Size() uint64 {
+	start, end, ok := r.bounds()
+	if !ok {
+		return 0
+	}
+
+	return uint64(end-start) + 1
+}
+
+// addrRange is an IPRange of a LeasePool.
+type addrRange struct {
+	start, end uint32
+}
+
+// NewLeasePoolRanges returns a pool with the addresses in ranges, such as the
+// ranges of a subnet around reserved blocks. Addresses are allocated from the
+// ranges in order. The addresses in exclude are not leased to clients. It
+// returns ErrInvalidRange if a range is not a valid range of IPv4 addresses,
+// or if ranges overlap.
+func NewLeasePoolRanges(ranges []IPRange, exclude ...net.IP) (*LeasePool, error) {
+	if len(ranges) == 0 {
+		return nil, ErrInvalidRange
+	}
+
+	p := newLeasePool(exclude)
+	for _, r := range ranges {
+		start, end, ok := r.bounds()
+		if !ok {
+			return nil, ErrInvalidRange
+		}
+
+		for _, o := range p.ranges {
+			if start <= o.end && o.start <= end {
+				return nil, ErrInvalidRange
+			}
+		}
+
+		p.ranges = append(p.ranges, addrRange{start: start, end: end})
+	}
+
+	p.next = p.ranges[0].start
+	return p, nil
+}
+
+// inRanges returns whether address v is in one of the pool's ranges.
+func (p *LeasePool) inRanges(v uint32) bool {
+	for _, r := range p.ranges {
+		if v >= r.start && v <= r.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// size returns the number of addresses in the pool's ranges.
+func (p *LeasePool) size() uint64 {
+	var n uint64
+	for _, r := range p.ranges {
+		n += uint64(r.end-r.start) + 1
+	}
+
+	return n
+}
+
+// advance moves p.next to the address following it, continuing with the next
+// range at the end of a range, and the first range at the end of the last.
+// The caller must hold the lock.
+func (p *LeasePool) advance() {
+	for i, r := range p.ranges {
+		if p.next < r.start || p.next > r.end {
+			continue
+		}
+
+		if p.next < r.end {
+			p.next++
+		} else {
+			p.next = p.ranges[(i+1)%len(p.ranges)].start
+		}
+		return
+	}
+
+	p.next = p.ranges[0].start
+}
@@ -0,0 +1,71 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRange(t *testing.T) {
+	r := IPRange{Start: net.IPv4(10, 0, 0, 10), End: net.IPv4(10, 0, 0, 12)}
+
+	assert.True(t, r.Contains(net.IPv4(10, 0, 0, 10)))
+	assert.True(t, r.Contains(net.IPv4(10, 0, 0, 12)))
+	assert.False(t, r.Contains(net.IPv4(10, 0, 0, 13)))
+	assert.False(t, r.Contains(net.ParseIP("::1")))
+	assert.Equal(t, uint64(3), r.Size())
+
+	ip, ok := r.Next(net.IPv4(10, 0, 0, 11))
+	assert.True(t, ok)
+	assert.Equal(t, net.IP{10, 0, 0, 12}, ip)
+	_, ok = r.Next(net.IPv4(10, 0, 0, 12))
+	assert.False(t, ok)
+	_, ok = r.Next(net.IPv4(10, 0, 0, 9))
+	assert.False(t, ok)
+
+	assert.Equal(t, uint64(0), IPRange{Start: r.End, End: r.Start}.Size())
+}
+
+func TestNewLeasePoolRanges(t *testing.T) {
+	_, err := NewLeasePoolRanges(nil)
+	assert.Equal(t, ErrInvalidRange, err)
+	_, err = NewLeasePoolRanges([]IPRange{{Start: net.IPv4(10, 0, 0, 2), End: net.IPv4(10, 0, 0, 1)}})
+	assert.Equal(t, ErrInvalidRange, err)
+	_, err = NewLeasePoolRanges([]IPRange{
+		{Start: net.IPv4(10, 0, 0, 1), End: net.IPv4(10, 0, 0, 10)},
+		{Start: net.IPv4(10, 0, 0, 10), End: net.IPv4(10, 0, 0, 20)},
+	})
+	assert.Equal(t, ErrInvalidRange, err)
+}
+
+func TestLeasePoolRangesAllocate(t *testing.T) {
+	p, err := NewLeasePoolRanges([]IPRange{
+		{Start: net.IPv4(10, 0, 0, 100), End: net.IPv4(10, 0, 0, 101)},
+		{Start: net.IPv4(10, 0, 0, 10), End: net.IPv4(10, 0, 0, 11)},
+	}, net.IPv4(10, 0, 0, 101))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, p.Contains(net.IPv4(10, 0, 0, 11)))
+	assert.False(t, p.Contains(net.IPv4(10, 0, 0, 50)))
+	assert.False(t, p.Contains(net.IPv4(10, 0, 0, 101)))
+
+	// In order of the ranges, skipping excluded addresses
+	var ips []net.IP
+	for _, id := range []string{"a", "b", "c"} {
+		l, err := p.Allocate([]byte(id), nil, time.Hour)
+		assert.NoError(t, err)
+		ips = append(ips, l.IP)
+	}
+	assert.Equal(t, []net.IP{{10, 0, 0, 100}, {10, 0, 0, 10}, {10, 0, 0, 11}}, ips)
+
+	_, err = p.Allocate([]byte("d"), nil, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// Addresses between the ranges are not leased
+	_, err = p.AllocateIP([]byte("d"), net.IPv4(10, 0, 0, 50), time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+}
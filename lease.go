@@ -0,0 +1,35 @@
+package dhcp4
+
+import (
+	"net"
+	"time"
+)
+
+// Lease is a network address bound to a client.
+type Lease struct {
+	IP           net.IP
+	ClientID     []byte
+	HardwareAddr net.HardwareAddr
+	Hostname     string
+	Expiry       time.Time
+}
+
+// leaseFromAck returns the lease granted at time now by the DHCPACK rep in
+// response to the DHCPREQUEST req.
+func leaseFromAck(req, rep *Packet, now time.Time) Lease {
+	l := Lease{
+		IP:           append(net.IP(nil), rep.YIAddr()...),
+		ClientID:     req.ClientID(),
+		HardwareAddr: req.GetCHAddr(),
+	}
+
+	if v, ok := req.GetString(OptionHostname); ok {
+		l.Hostname = v
+	}
+
+	if d, ok := rep.GetDuration(OptionAddressTime); ok {
+		l.Expiry = now.Add(d)
+	}
+
+	return l
+}
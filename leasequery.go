@@ -0,0 +1,47 @@
+package dhcp4
+
+import (
+	"errors"
+	"math"
+	"net"
+	"time"
+)
+
+var (
+	ErrInvalidTransactionTime = errors.New("dhcp4: transaction time out of range")
+)
+
+// GetLastTransactionTime gets the Client Last Transaction Time option (91) of
+// a leasequery reply: the time elapsed since the server last received a
+// message from the client, in whole seconds (RFC4388, section 6.1). The time
+// is relative to when the reply was sent; it is not an absolute timestamp.
+func (om OptionMap) GetLastTransactionTime() (time.Duration, bool) {
+	return om.GetDuration(OptionClientLastTransactionTimeOption)
+}
+
+// SetLastTransactionTime sets the Client Last Transaction Time option (91) to
+// the time elapsed since the last transaction with the client, truncated to
+// whole seconds. It returns ErrInvalidTransactionTime if d is negative or
+// doesn't fit in 32 bits.
+func (om OptionMap) SetLastTransactionTime(d time.Duration) error {
+	if d < 0 || d/time.Second > math.MaxUint32 {
+		return ErrInvalidTransactionTime
+	}
+
+	return om.SetUint32(OptionClientLastTransactionTimeOption, uint32(d/time.Second))
+}
+
+// GetAssociatedIPs gets the Associated IP option (92) of a leasequery reply:
+// the addresses leased to the client, other than the one in 'ciaddr' (RFC4388,
+// section 6.1). It fails if the option is empty, or its length is not a
+// multiple of 4 octets.
+func (om OptionMap) GetAssociatedIPs() ([]net.IP, bool) {
+	return om.getCheckedIPs(OptionAssociatedIPOption)
+}
+
+// SetAssociatedIPs sets the Associated IP option (92). It returns an
+// *OptionValueError if there are no addresses, or if some of them are not IPv4
+// addresses.
+func (om OptionMap) SetAssociatedIPs(ips ...net.IP) error {
+	return om.SetIPs(OptionAssociatedIPOption, ips)
+}
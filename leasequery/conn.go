@@ -0,0 +1,129 @@
+// Package leasequery implements the TCP transport for bulk leasequery. RFC5460
+// defines bulk leasequery for DHCPv6; RFC6926 defines its DHCPv4 counterpart,
+// which this package implements. Every DHCP message is sent over the TCP
+// connection prefixed with its length as a two octet integer in network byte
+// order (RFC6926, section 6.1). The messages themselves are encoded with the
+// same codec as dhcp4.PacketFromBytes and dhcp4.PacketToBytesMax.
+package leasequery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/betawaffle/dhcp4-go"
+)
+
+var (
+	ErrMessageTooLong = errors.New("leasequery: message too long")
+)
+
+// Port is the TCP port of bulk leasequery servers.
+const Port = 67
+
+// TCPConn is a connection carrying framed DHCP messages.
+type TCPConn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// Dial connects to the bulk leasequery server at addr. If addr has no port,
+// Port is used.
+func Dial(addr string) (*TCPConn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(Port))
+	}
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTCPConn(c), nil
+}
+
+// NewTCPConn returns a TCPConn for the connection c.
+func NewTCPConn(c net.Conn) *TCPConn {
+	return &TCPConn{c: c, r: bufio.NewReader(c)}
+}
+
+// ReadPacket reads the next message from the connection. A message may arrive
+// in any number of reads; ReadPacket returns once it has read all of it, and
+// keeps the octets beyond the message for the next call. It returns io.EOF if
+// the connection is closed on a message boundary.
+func (c *TCPConn) ReadPacket() (dhcp4.Packet, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(c.r, l[:]); err != nil {
+		return dhcp4.Packet{}, err
+	}
+
+	b := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return dhcp4.Packet{}, err
+	}
+
+	return dhcp4.PacketFromBytes(b)
+}
+
+// WritePacket writes the message p to the connection. Messages are not
+// limited by the MTU, but by their two octet length; it returns
+// ErrMessageTooLong if the options of p don't fit in 65535 octets.
+func (c *TCPConn) WritePacket(p dhcp4.Packet) error {
+	b, err := dhcp4.PacketToBytesMax(p, 0xffff)
+	if err == dhcp4.ErrPacketTooLong {
+		return ErrMessageTooLong
+	}
+	if err != nil {
+		return err
+	}
+
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(b)))
+	_, err = c.c.Write(append(l[:], b...))
+	return err
+}
+
+// BulkQuery sends the DHCPBULKLEASEQUERY request req and calls fn for every
+// reply with the same transaction ID, until the server sends
+// DHCPLEASEQUERYDONE. It returns the DHCPLEASEQUERYDONE message, that may
+// carry a status code. If fn returns an error, BulkQuery returns it without
+// reading further replies.
+func (c *TCPConn) BulkQuery(req *dhcp4.Packet, fn func(rep *dhcp4.Packet) error) (*dhcp4.Packet, error) {
+	if err := c.WritePacket(*req); err != nil {
+		return nil, err
+	}
+
+	for {
+		rep, err := c.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if !bytes.Equal(rep.XID(), req.XID()) {
+			continue
+		}
+
+		if rep.GetMessageType() == dhcp4.MessageTypeLeaseQueryDone {
+			return &rep, nil
+		}
+
+		if err := fn(&rep); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *TCPConn) Close() error {
+	return c.c.Close()
+}
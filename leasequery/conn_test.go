@@ -0,0 +1,129 @@
+package leasequery
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/betawaffle/dhcp4-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMessage(t *testing.T, op dhcp4.OpCode, xid byte, mt dhcp4.MessageType) dhcp4.Packet {
+	p := dhcp4.NewPacket(op)
+	p.XID()[3] = xid
+	p.SetMessageType(mt)
+	return p
+}
+
+func frame(t *testing.T, p dhcp4.Packet) []byte {
+	b, err := dhcp4.PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(b)))
+	return append(l, b...)
+}
+
+func TestBulkQuery(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		sc := NewTCPConn(server)
+		req, err := sc.ReadPacket()
+		if err != nil || req.GetMessageType() != dhcp4.MessageTypeBulkLeaseQuery {
+			return
+		}
+
+		var b []byte
+		b = append(b, frame(t, testMessage(t, dhcp4.BootReply, 1, dhcp4.MessageTypeLeaseActive))...)
+		b = append(b, frame(t, testMessage(t, dhcp4.BootReply, 2, dhcp4.MessageTypeLeaseActive))...)
+		b = append(b, frame(t, testMessage(t, dhcp4.BootReply, 1, dhcp4.MessageTypeLeaseActive))...)
+		b = append(b, frame(t, testMessage(t, dhcp4.BootReply, 1, dhcp4.MessageTypeLeaseQueryDone))...)
+
+		// Write in chunks that don't line up with the message boundaries
+		for len(b) > 0 {
+			n := 7
+			if n > len(b) {
+				n = len(b)
+			}
+			if _, err := server.Write(b[:n]); err != nil {
+				return
+			}
+			b = b[n:]
+		}
+	}()
+
+	c := NewTCPConn(client)
+	req := testMessage(t, dhcp4.BootRequest, 1, dhcp4.MessageTypeBulkLeaseQuery)
+
+	var replies []*dhcp4.Packet
+	done, err := c.BulkQuery(&req, func(rep *dhcp4.Packet) error {
+		replies = append(replies, rep)
+		return nil
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, dhcp4.MessageTypeLeaseQueryDone, done.GetMessageType())
+		if assert.Len(t, replies, 2) {
+			for _, rep := range replies {
+				assert.Equal(t, req.XID(), rep.XID())
+				assert.Equal(t, dhcp4.MessageTypeLeaseActive, rep.GetMessageType())
+			}
+		}
+	}
+}
+
+func TestReadPacketTruncated(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		b := frame(t, testMessage(t, dhcp4.BootReply, 1, dhcp4.MessageTypeLeaseActive))
+		server.Write(b[:len(b)-1])
+		server.Close()
+	}()
+
+	c := NewTCPConn(client)
+	_, err := c.ReadPacket()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// Connection closed on a message boundary
+	_, err = c.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWritePacketLarge(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// Options well beyond the 1500 octets of a UDP message
+	p := testMessage(t, dhcp4.BootReply, 1, dhcp4.MessageTypeLeaseActive)
+	p.SetOption(dhcp4.OptionRelayAgentInformation, make([]byte, 255))
+	p.SetOption(dhcp4.OptionClientID, make([]byte, 2000))
+	p.SetOption(dhcp4.OptionVendorSpecific, make([]byte, 1000))
+
+	errs := make(chan error, 1)
+	go func() { errs <- NewTCPConn(server).WritePacket(p) }()
+
+	rep, err := NewTCPConn(client).ReadPacket()
+	if assert.NoError(t, err) && assert.NoError(t, <-errs) {
+		for _, o := range []dhcp4.Option{dhcp4.OptionRelayAgentInformation, dhcp4.OptionClientID, dhcp4.OptionVendorSpecific} {
+			v, _ := p.GetOption(o)
+			w, ok := rep.GetOption(o)
+			assert.True(t, ok, "option %d", o)
+			assert.Equal(t, v, w, "option %d", o)
+		}
+	}
+
+	// Options that don't fit in a message are an error, not dropped
+	p.SetOption(dhcp4.OptionVendorSpecific, make([]byte, 0x10000))
+	assert.Equal(t, ErrMessageTooLong, NewTCPConn(server).WritePacket(p))
+}
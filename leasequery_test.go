@@ -0,0 +1,50 @@
+package dhcp4
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastTransactionTime(t *testing.T) {
+	om := make(OptionMap)
+
+	_, ok := om.GetLastTransactionTime()
+	assert.False(t, ok)
+
+	assert.NoError(t, om.SetLastTransactionTime(90*time.Second+500*time.Millisecond))
+	assert.Equal(t, []byte{0, 0, 0, 90}, om[OptionClientLastTransactionTimeOption])
+
+	d, ok := om.GetLastTransactionTime()
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, d)
+
+	assert.Equal(t, ErrInvalidTransactionTime, om.SetLastTransactionTime(-time.Second))
+	assert.Equal(t, ErrInvalidTransactionTime, om.SetLastTransactionTime((math.MaxUint32+1)*time.Second))
+	assert.NoError(t, om.SetLastTransactionTime(math.MaxUint32*time.Second))
+
+	om.SetOption(OptionClientLastTransactionTimeOption, []byte{0, 90})
+	_, ok = om.GetLastTransactionTime()
+	assert.False(t, ok)
+}
+
+func TestAssociatedIPs(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.NoError(t, om.SetAssociatedIPs(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)))
+	ips, ok := om.GetAssociatedIPs()
+	assert.True(t, ok)
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)}, ips)
+
+	assert.Error(t, om.SetAssociatedIPs())
+	assert.Error(t, om.SetAssociatedIPs(net.ParseIP("fe80::1")))
+
+	for _, v := range [][]byte{{}, {10, 0, 0}, {10, 0, 0, 1, 10}} {
+		om.SetOption(OptionAssociatedIPOption, v)
+		_, ok = om.GetAssociatedIPs()
+		assert.False(t, ok, "value %v", v)
+	}
+}
@@ -0,0 +1,37 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrLinkLocalAddress = errors.New("dhcp4: link-local address")
+)
+
+var linkLocalNet = net.IPNet{
+	IP:   net.IPv4(169, 254, 0, 0).To4(),
+	Mask: net.CIDRMask(16, 32),
+}
+
+// IsLinkLocal returns whether ip is an IPv4 link-local address, in
+// 169.254.0.0/16 (RFC3927). Clients configure these themselves when they get
+// no lease; a server must not lease them, and a relay agent must not use one
+// as 'giaddr'.
+func IsLinkLocal(ip net.IP) bool {
+	return linkLocalNet.Contains(ip)
+}
+
+// checkLinkLocal returns ErrLinkLocalAddress if the request p asks for a
+// link-local address, or was relayed from one.
+func checkLinkLocal(p *Packet) error {
+	if ip, ok := p.GetIP(OptionAddressRequest); ok && IsLinkLocal(ip) {
+		return ErrLinkLocalAddress
+	}
+
+	if IsLinkLocal(p.GetGIAddr()) {
+		return ErrLinkLocalAddress
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLinkLocal(t *testing.T) {
+	assert.True(t, IsLinkLocal(net.IPv4(169, 254, 0, 1)))
+	assert.True(t, IsLinkLocal(net.IP{169, 254, 255, 254}))
+	assert.False(t, IsLinkLocal(net.IPv4(169, 253, 0, 1)))
+	assert.False(t, IsLinkLocal(net.IPv4(10, 0, 0, 1)))
+	assert.False(t, IsLinkLocal(net.ParseIP("fe80::1")))
+	assert.False(t, IsLinkLocal(nil))
+}
+
+func TestCheckLinkLocal(t *testing.T) {
+	p := BuildPacket(PacketSpec{})
+	assert.NoError(t, checkLinkLocal(p))
+
+	p.SetIP(OptionAddressRequest, net.IPv4(169, 254, 1, 1))
+	assert.Equal(t, ErrLinkLocalAddress, checkLinkLocal(p))
+
+	p = BuildPacket(PacketSpec{GIAddr: net.IPv4(169, 254, 1, 1)})
+	assert.Equal(t, ErrLinkLocalAddress, checkLinkLocal(p))
+}
@@ -101,6 +101,9 @@ func (sr *serverRecv) String() string {
 	buf.WriteString(sr.msg.GetCHAddr().String())
 	buf.WriteString(`"`)
 
+	buf.WriteString(" correlation_id=")
+	buf.WriteString(sr.msg.CorrelationID())
+
 	if sr.msg.GetGIAddr().Equal(sr.ip) {
 		buf.WriteString(" via=")
 	} else {
@@ -108,9 +111,9 @@ func (sr *serverRecv) String() string {
 	}
 	buf.WriteString(sr.ip.String())
 
-	if iface, err := net.InterfaceByIndex(sr.ifindex); err == nil {
+	if name, err := InterfaceName(sr.ifindex); err == nil {
 		buf.WriteString(" iface=")
-		buf.WriteString(iface.Name)
+		buf.WriteString(name)
 	}
 
 	writePacketInfo(buf, sr.msg)
@@ -123,6 +126,7 @@ type serverSend struct {
 	rep     *Packet
 	ip      net.IP
 	ifindex int
+	latency time.Duration
 }
 
 func (ss *serverSend) String() string {
@@ -134,6 +138,9 @@ func (ss *serverSend) String() string {
 	buf.WriteString(ss.req.GetCHAddr().String())
 	buf.WriteString(`"`)
 
+	buf.WriteString(" correlation_id=")
+	buf.WriteString(ss.req.CorrelationID())
+
 	if ss.req.GetGIAddr().Equal(ss.ip) {
 		buf.WriteString(" via=")
 	} else {
@@ -141,9 +148,14 @@ func (ss *serverSend) String() string {
 	}
 	buf.WriteString(ss.ip.String())
 
-	if iface, err := net.InterfaceByIndex(ss.ifindex); err == nil {
+	if name, err := InterfaceName(ss.ifindex); err == nil {
 		buf.WriteString(" iface=")
-		buf.WriteString(iface.Name)
+		buf.WriteString(name)
+	}
+
+	if ss.latency > 0 {
+		buf.WriteString(" latency=")
+		buf.WriteString(ss.latency.String())
 	}
 
 	writePacketInfo(buf, ss.rep)
@@ -163,8 +175,8 @@ func writePacketInfo(buf *bytes.Buffer, p *Packet) {
 		buf.WriteString(addr.String())
 	}
 
-	if secs := binary.BigEndian.Uint16(p.Secs()); secs > 0 {
-		fmt.Fprintf(buf, " secs=%d", secs)
+	if secs := p.Elapsed(); secs > 0 {
+		fmt.Fprintf(buf, " secs=%d", secs/time.Second)
 	}
 
 	if addr := p.GetSIAddr(); !net.IPv4zero.Equal(addr) {
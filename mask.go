@@ -0,0 +1,73 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrInvalidMask = errors.New("dhcp4: invalid subnet mask")
+)
+
+// MaskToOption returns the 4 octet form of mask, as stored in the Subnet Mask
+// option (1). IPv4 masks in 16 octet form, with 12 leading octets of ones,
+// are converted. It returns nil if mask is not a contiguous IPv4 mask.
+func MaskToOption(mask net.IPMask) []byte {
+	if len(mask) == net.IPv6len && allOnes(mask[:12]) {
+		mask = mask[12:]
+	}
+
+	if len(mask) != net.IPv4len || !isContiguousMask(mask) {
+		return nil
+	}
+
+	return append([]byte(nil), mask...)
+}
+
+// OptionToMask returns the mask stored in the value v of a Subnet Mask option
+// (1). It returns ErrInvalidMask if v is not a contiguous mask of 4 octets.
+func OptionToMask(v []byte) (net.IPMask, error) {
+	if !KindSubnetMask.Check(v) {
+		return nil, ErrInvalidMask
+	}
+
+	return net.IPMask(append([]byte(nil), v...)), nil
+}
+
+// PrefixLenToMask returns the 4 octet IPv4 mask with n leading ones, e.g.
+// 255.255.255.0 for 24. It returns nil if n is not between 0 and 32.
+func PrefixLenToMask(n int) net.IPMask {
+	return net.CIDRMask(n, 8*net.IPv4len)
+}
+
+// GetSubnetMask gets the Subnet Mask option (1).
+func (om OptionMap) GetSubnetMask() (net.IPMask, bool) {
+	v, ok := om.GetOption(OptionSubnetMask)
+	if !ok {
+		return nil, false
+	}
+
+	mask, err := OptionToMask(v)
+	return mask, err == nil
+}
+
+// SetSubnetMask sets the Subnet Mask option (1), see MaskToOption. It returns
+// ErrInvalidMask if mask is not a contiguous IPv4 mask.
+func (om OptionMap) SetSubnetMask(mask net.IPMask) error {
+	v := MaskToOption(mask)
+	if v == nil {
+		return ErrInvalidMask
+	}
+
+	om.SetOption(OptionSubnetMask, v)
+	return nil
+}
+
+func allOnes(b []byte) bool {
+	for _, c := range b {
+		if c != 0xff {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskToOption(t *testing.T) {
+	assert.Equal(t, []byte{255, 255, 255, 0}, MaskToOption(net.CIDRMask(24, 32)))
+	assert.Equal(t, []byte{0, 0, 0, 0}, MaskToOption(net.CIDRMask(0, 32)))
+
+	// IPv4 masks in 16 octet form
+	assert.Equal(t, []byte{255, 255, 255, 0}, MaskToOption(net.CIDRMask(120, 128)))
+
+	assert.Nil(t, MaskToOption(net.IPv4Mask(255, 0, 255, 0)))
+	assert.Nil(t, MaskToOption(net.CIDRMask(64, 128)))
+	assert.Nil(t, MaskToOption(nil))
+}
+
+func TestOptionToMask(t *testing.T) {
+	mask, err := OptionToMask([]byte{255, 255, 240, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, net.CIDRMask(20, 32), mask)
+
+	for _, v := range [][]byte{nil, {255, 255, 255}, {255, 0, 255, 0}, {255, 255, 255, 0, 0}} {
+		_, err := OptionToMask(v)
+		assert.Equal(t, ErrInvalidMask, err, "value %v", v)
+	}
+}
+
+func TestPrefixLenToMask(t *testing.T) {
+	assert.Equal(t, net.IPv4Mask(255, 255, 255, 128), PrefixLenToMask(25))
+	assert.Equal(t, net.IPv4Mask(255, 255, 255, 255), PrefixLenToMask(32))
+	assert.Nil(t, PrefixLenToMask(33))
+	assert.Nil(t, PrefixLenToMask(-1))
+}
+
+func TestOptionMapSubnetMask(t *testing.T) {
+	om := make(OptionMap)
+
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, om.SetSubnetMask(network.Mask))
+	mask, ok := om.GetSubnetMask()
+	assert.True(t, ok)
+	assert.Equal(t, net.CIDRMask(8, 32), mask)
+
+	assert.Equal(t, ErrInvalidMask, om.SetSubnetMask(net.IPv4Mask(0, 255, 0, 0)))
+	assert.Equal(t, []byte{255, 0, 0, 0}, om[OptionSubnetMask])
+
+	om.SetOption(OptionSubnetMask, []byte{255, 0, 255, 0})
+	_, ok = om.GetSubnetMask()
+	assert.False(t, ok)
+}
@@ -0,0 +1,47 @@
+package dhcp4
+
+import "time"
+
+// DropReason describes why a server dropped a packet without handling it.
+type DropReason string
+
+const (
+	DropMalformed  = DropReason("malformed")   // Packet couldn't be parsed
+	DropNotRequest = DropReason("not_request") // Packet is not a BOOTREQUEST
+	DropNotForUs   = DropReason("not_for_us")  // DHCPRELEASE for another server
+	DropInterface  = DropReason("interface")   // Packet on an interface that is not allowed
+	DropNotReady   = DropReason("not_ready")   // Packet received while the server is not ready
+	DropRelayInfo  = DropReason("relay_info")  // Request with relay agent information that was not relayed
+)
+
+// Metrics receives events from a Server, so they can be exported to a
+// metrics system. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// PacketReceived is called for every request passed to the handler.
+	PacketReceived(t MessageType, ifindex int)
+
+	// PacketDropped is called for every packet the server drops without
+	// passing it to the handler.
+	PacketDropped(reason DropReason, ifindex int)
+
+	// HandlerPanicked is called when the handler panics serving a request.
+	HandlerPanicked(t MessageType)
+
+	// ReplyLatency is called for every reply written to a request read by
+	// the server, with the type of the request and the time from reading the
+	// request to writing the reply. It includes the time requests wait for
+	// the handler.
+	ReplyLatency(t MessageType, d time.Duration)
+
+	// NotificationDropped is called for every event dropped because the
+	// channel returned by Server.Notifications is full.
+	NotificationDropped()
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) PacketReceived(t MessageType, ifindex int)    {}
+func (nopMetrics) PacketDropped(reason DropReason, ifindex int) {}
+func (nopMetrics) HandlerPanicked(t MessageType)                {}
+func (nopMetrics) ReplyLatency(t MessageType, d time.Duration)  {}
+func (nopMetrics) NotificationDropped()                         {}
@@ -0,0 +1,164 @@
+package dhcp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrInvalidWPAD = errors.New("dhcp4: invalid WPAD URL")
+)
+
+// OptionWPAD is the site-specific option used by Windows clients for the Web
+// Proxy Auto-Discovery (WPAD) URL of the proxy auto-config file.
+const OptionWPAD = Option(252)
+
+// GetWPAD gets the WPAD URL. A terminating NUL octet, added by some servers
+// for older clients, is removed.
+func (om OptionMap) GetWPAD() (string, bool) {
+	v, ok := om.GetString(OptionWPAD)
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimSuffix(v, "\x00"), true
+}
+
+// SetWPAD sets the WPAD URL. It returns ErrInvalidWPAD if u is not an absolute
+// http or https URL.
+func (om OptionMap) SetWPAD(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrInvalidWPAD
+	}
+
+	om.SetOption(OptionWPAD, []byte(u))
+	return nil
+}
+
+// Microsoft vendor options, carried in the Vendor Specific Information option
+// (43) of clients with a vendor class starting with "MSFT". From [MS-DHCPE],
+// section 2.2.
+const (
+	MSDisableNetBIOS          = Option(1)
+	MSReleaseOnShutdown       = Option(2)
+	MSDefaultRouterMetricBase = Option(3)
+)
+
+// Values of the Microsoft disable NetBIOS option.
+const (
+	MSNetBIOSEnabled  = uint32(1)
+	MSNetBIOSDisabled = uint32(2)
+)
+
+// MSVendorOptions holds the Microsoft sub-options of option 43.
+type MSVendorOptions struct {
+	OptionMap
+}
+
+// NewMSVendorOptions returns an empty set of Microsoft vendor options.
+func NewMSVendorOptions() MSVendorOptions {
+	return MSVendorOptions{make(OptionMap)}
+}
+
+// IsMSClient returns whether the vendor class identifier of a client is one of
+// a Microsoft DHCP client, e.g. "MSFT 5.0".
+func (om OptionMap) IsMSClient() bool {
+	v, ok := om.GetString(OptionClassID)
+	return ok && strings.HasPrefix(v, "MSFT")
+}
+
+// GetMSVendorOptions parses the Vendor Specific Information option as
+// Microsoft vendor options. It returns an empty set if the option is not set.
+func (om OptionMap) GetMSVendorOptions() (MSVendorOptions, error) {
+	m := NewMSVendorOptions()
+	if v, ok := om.GetOption(OptionVendorSpecific); ok {
+		opts := OptionMapDeserializeOptions{IgnoreMissingEndTag: true}
+		if err := m.Deserialize(v, &opts); err != nil {
+			return MSVendorOptions{}, err
+		}
+	}
+
+	return m, nil
+}
+
+// SetMSVendorOptions sets the Vendor Specific Information option to the
+// encoded Microsoft vendor options.
+func (om OptionMap) SetMSVendorOptions(m MSVendorOptions) {
+	om.SetOption(OptionVendorSpecific, m.Bytes())
+}
+
+// Bytes returns the encoded Microsoft vendor options, in numeric order,
+// followed by the end tag.
+func (m MSVendorOptions) Bytes() []byte {
+	b := bytes.Buffer{}
+	for _, k := range m.GetSortedOptions() {
+		for _, c := range splitOption(m.OptionMap[k]) {
+			b.WriteByte(byte(k))
+			b.WriteByte(byte(len(c)))
+			b.Write(c)
+		}
+	}
+
+	b.WriteByte(byte(OptionEnd))
+	return b.Bytes()
+}
+
+func (m MSVendorOptions) getUint32(o Option) (uint32, bool) {
+	if v, ok := m.GetOption(o); ok && len(v) == 4 {
+		return binary.BigEndian.Uint32(v), true
+	}
+
+	return 0, false
+}
+
+func (m MSVendorOptions) setUint32(o Option, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	m.SetOption(o, b[:])
+}
+
+// GetNetBIOS gets the value of the disable NetBIOS option, MSNetBIOSEnabled
+// or MSNetBIOSDisabled.
+func (m MSVendorOptions) GetNetBIOS() (uint32, bool) {
+	return m.getUint32(MSDisableNetBIOS)
+}
+
+// SetNetBIOS sets the value of the disable NetBIOS option, MSNetBIOSEnabled
+// or MSNetBIOSDisabled.
+func (m MSVendorOptions) SetNetBIOS(v uint32) {
+	m.setUint32(MSDisableNetBIOS, v)
+}
+
+// GetReleaseOnShutdown gets whether the client should release its lease when
+// it shuts down.
+func (m MSVendorOptions) GetReleaseOnShutdown() (bool, bool) {
+	v, ok := m.getUint32(MSReleaseOnShutdown)
+	return v&1 != 0, ok
+}
+
+// SetReleaseOnShutdown sets whether the client should release its lease when
+// it shuts down.
+func (m MSVendorOptions) SetReleaseOnShutdown(release bool) {
+	var v uint32
+	if release {
+		v = 1
+	}
+
+	m.setUint32(MSReleaseOnShutdown, v)
+}
+
+// GetDefaultRouterMetricBase gets the metric base of the client's default
+// routes.
+func (m MSVendorOptions) GetDefaultRouterMetricBase() (uint32, bool) {
+	return m.getUint32(MSDefaultRouterMetricBase)
+}
+
+// SetDefaultRouterMetricBase sets the metric base of the client's default
+// routes.
+func (m MSVendorOptions) SetDefaultRouterMetricBase(v uint32) {
+	m.setUint32(MSDefaultRouterMetricBase, v)
+}
@@ -0,0 +1,69 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWPAD(t *testing.T) {
+	om := make(OptionMap)
+
+	_, ok := om.GetWPAD()
+	assert.False(t, ok)
+
+	assert.NoError(t, om.SetWPAD("http://proxy.example.com/wpad.dat"))
+	u, ok := om.GetWPAD()
+	assert.True(t, ok)
+	assert.Equal(t, "http://proxy.example.com/wpad.dat", u)
+
+	for _, u := range []string{"", "wpad.dat", "/wpad.dat", "ftp://proxy.example.com/wpad.dat", "http://%zz"} {
+		assert.Equal(t, ErrInvalidWPAD, om.SetWPAD(u), u)
+	}
+
+	// Terminating NUL
+	om.SetOption(OptionWPAD, []byte("http://proxy/wpad.dat\x00"))
+	u, _ = om.GetWPAD()
+	assert.Equal(t, "http://proxy/wpad.dat", u)
+}
+
+func TestMSVendorOptionsRoundtrip(t *testing.T) {
+	m := NewMSVendorOptions()
+	m.SetNetBIOS(MSNetBIOSDisabled)
+	m.SetReleaseOnShutdown(true)
+	m.SetDefaultRouterMetricBase(10)
+
+	om := make(OptionMap)
+	om.SetMSVendorOptions(m)
+
+	v, _ := om.GetOption(OptionVendorSpecific)
+	assert.Equal(t, []byte{1, 4, 0, 0, 0, 2, 2, 4, 0, 0, 0, 1, 3, 4, 0, 0, 0, 10, 255}, v)
+
+	n, err := om.GetMSVendorOptions()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	netbios, ok := n.GetNetBIOS()
+	assert.True(t, ok)
+	assert.Equal(t, MSNetBIOSDisabled, netbios)
+
+	release, ok := n.GetReleaseOnShutdown()
+	assert.True(t, ok)
+	assert.True(t, release)
+
+	metric, ok := n.GetDefaultRouterMetricBase()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(10), metric)
+}
+
+func TestIsMSClient(t *testing.T) {
+	om := make(OptionMap)
+	assert.False(t, om.IsMSClient())
+
+	om.SetString(OptionClassID, "MSFT 5.0")
+	assert.True(t, om.IsMSClient())
+
+	om.SetString(OptionClassID, "PXEClient")
+	assert.False(t, om.IsMSClient())
+}
@@ -0,0 +1,92 @@
+package dhcp4
+
+import "sync"
+
+// Mux is a Handler that dispatches requests to the handler registered for
+// their message type, like http.ServeMux does by path. Requests of a type
+// without handler are passed to the Default handler, or ignored if there is
+// none. The zero value is ready to use, and handlers may be registered while
+// the mux is serving.
+type Mux struct {
+	// Default handles requests of message types that have no handler.
+	Default Handler
+
+	mu       sync.RWMutex
+	handlers map[MessageType]Handler
+}
+
+// NewMux returns an empty Mux.
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Handle registers the handler for requests of message type t, replacing the
+// previous one, if any. A nil handler unregisters it.
+func (m *Mux) Handle(t MessageType, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if h == nil {
+		delete(m.handlers, t)
+		return
+	}
+
+	if m.handlers == nil {
+		m.handlers = make(map[MessageType]Handler)
+	}
+
+	m.handlers[t] = h
+}
+
+// HandleFunc registers the handler function for requests of message type t.
+func (m *Mux) HandleFunc(t MessageType, f func(w ReplyWriter, p *Packet)) {
+	m.Handle(t, HandlerFunc(f))
+}
+
+// HandleDiscover registers the handler function for DHCPDISCOVER messages.
+func (m *Mux) HandleDiscover(f func(w ReplyWriter, p *Packet)) {
+	m.HandleFunc(MessageTypeDiscover, f)
+}
+
+// HandleRequest registers the handler function for DHCPREQUEST messages.
+func (m *Mux) HandleRequest(f func(w ReplyWriter, p *Packet)) {
+	m.HandleFunc(MessageTypeRequest, f)
+}
+
+// HandleDecline registers the handler function for DHCPDECLINE messages. The
+// reply writer is nil, as the server doesn't reply to them.
+func (m *Mux) HandleDecline(f func(w ReplyWriter, p *Packet)) {
+	m.HandleFunc(MessageTypeDecline, f)
+}
+
+// HandleRelease registers the handler function for DHCPRELEASE messages. The
+// reply writer is nil, as the server doesn't reply to them.
+func (m *Mux) HandleRelease(f func(w ReplyWriter, p *Packet)) {
+	m.HandleFunc(MessageTypeRelease, f)
+}
+
+// HandleInform registers the handler function for DHCPINFORM messages.
+func (m *Mux) HandleInform(f func(w ReplyWriter, p *Packet)) {
+	m.HandleFunc(MessageTypeInform, f)
+}
+
+// Handler returns the handler for requests of message type t: the registered
+// handler, or the Default handler. It returns nil if there is neither.
+func (m *Mux) Handler(t MessageType) Handler {
+	m.mu.RLock()
+	h, ok := m.handlers[t]
+	m.mu.RUnlock()
+
+	if !ok {
+		return m.Default
+	}
+
+	return h
+}
+
+// ServeDHCP dispatches the request p to the handler for its message type.
+func (m *Mux) ServeDHCP(w ReplyWriter, p *Packet) {
+	if h := m.Handler(p.GetMessageType()); h != nil {
+		h.ServeDHCP(w, p)
+	}
+}
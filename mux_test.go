@@ -0,0 +1,51 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMux(t *testing.T) {
+	var handled []string
+	handler := func(name string) func(ReplyWriter, *Packet) {
+		return func(ReplyWriter, *Packet) { handled = append(handled, name) }
+	}
+
+	m := NewMux()
+	m.HandleDiscover(handler("discover"))
+	m.HandleRequest(handler("request"))
+
+	for _, mt := range []MessageType{MessageTypeDiscover, MessageTypeRequest, MessageTypeRelease} {
+		p := NewPacket(BootRequest)
+		p.SetMessageType(mt)
+		m.ServeDHCP(nil, &p)
+	}
+	assert.Equal(t, []string{"discover", "request"}, handled)
+
+	// Unregistered types go to the default handler
+	handled = nil
+	m.Default = HandlerFunc(handler("default"))
+	m.Handle(MessageTypeRequest, nil)
+
+	for _, mt := range []MessageType{MessageTypeDiscover, MessageTypeRequest} {
+		p := NewPacket(BootRequest)
+		p.SetMessageType(mt)
+		m.ServeDHCP(nil, &p)
+	}
+	assert.Equal(t, []string{"discover", "default"}, handled)
+}
+
+func TestMuxZeroValue(t *testing.T) {
+	var m Mux
+	assert.Nil(t, m.Handler(MessageTypeInform))
+
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeInform)
+	assert.NotPanics(t, func() { m.ServeDHCP(nil, &p) })
+
+	called := false
+	m.HandleInform(func(ReplyWriter, *Packet) { called = true })
+	m.ServeDHCP(nil, &p)
+	assert.True(t, called)
+}
@@ -1,6 +1,9 @@
 package dhcp4
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"net"
+)
 
 // Nak is a server to client packet indicating client's notion of network
 // address is incorrect (e.g., client has moved to new subnet) or client's
@@ -21,6 +24,59 @@ func CreateNak(msg *Packet) Nak {
 	return rep
 }
 
+// CreateGratuitousNak returns a DHCPNAK that is not a reply to a request, to
+// tell the client with hardware address chaddr that its binding, negotiated in
+// transaction xid, is no longer valid, e.g. after the pool was reconfigured.
+// The reply is built for a request from the client relayed by giaddr, which
+// may be nil if the client is on a local network, with the broadcast flag set
+// as clients may no longer have a valid address. It returns ErrInvalidAddress
+// if chaddr, serverID or giaddr is invalid.
+func CreateGratuitousNak(chaddr net.HardwareAddr, xid uint32, serverID, giaddr net.IP) (Nak, error) {
+	if len(chaddr) > 16 || serverID.To4() == nil || (giaddr != nil && giaddr.To4() == nil) {
+		return Nak{}, ErrInvalidAddress
+	}
+
+	msg := BuildPacket(PacketSpec{
+		XID:    xid,
+		Flags:  0x8000,
+		CHAddr: chaddr,
+		GIAddr: giaddr,
+	})
+
+	rep := CreateNak(msg)
+	rep.SetIP(OptionDHCPServerID, serverID)
+	return rep, nil
+}
+
+// SendNak sends a gratuitous DHCPNAK (see CreateGratuitousNak) with pw. Like
+// a DHCPNAK in reply to a request, it is sent to the relay agent giaddr if set,
+// and broadcast otherwise (RFC2131, section 4.1). The broadcast is sent on the
+// interface the kernel picks; for a specific interface, write the reply of
+// CreateGratuitousNak with the interface index.
+func SendNak(chaddr net.HardwareAddr, xid uint32, serverID, giaddr net.IP, pw PacketWriter) error {
+	rep, err := CreateGratuitousNak(chaddr, xid, serverID, giaddr)
+	if err != nil {
+		return err
+	}
+
+	if err := rep.Validate(); err != nil {
+		return err
+	}
+
+	b, err := rep.ToBytes()
+	if err != nil {
+		return err
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+	if giaddr != nil && !giaddr.Equal(net.IPv4zero) {
+		addr = &net.UDPAddr{IP: giaddr, Port: ServerPort}
+	}
+
+	_, err = pw.WriteTo(b, addr, 0)
+	return err
+}
+
 // From RFC2131, table 3:
 //   Option                    DHCPNAK
 //   ------                    -------
@@ -53,7 +109,7 @@ func (d *Nak) Validate() error {
 	return Validate(d.Packet, dhcpNakValidation)
 }
 
-func (d *Nak) ToBytes() ([]byte, error) {
+func (d *Nak) toBytesOptions() *packetToBytesOptions {
 	opts := packetToBytesOptions{
 		skipFile:  true,
 		skipSName: true,
@@ -64,7 +120,17 @@ func (d *Nak) ToBytes() ([]byte, error) {
 		opts.maxLen = binary.BigEndian.Uint16(v)
 	}
 
-	return PacketToBytes(d.Packet, &opts)
+	return &opts
+}
+
+func (d *Nak) ToBytes() ([]byte, error) {
+	return PacketToBytes(d.Packet, d.toBytesOptions())
+}
+
+// MarshalTo serializes the reply into buf, like ToBytes, and returns the
+// number of bytes written. It returns io.ErrShortBuffer if buf is too small.
+func (d *Nak) MarshalTo(buf []byte) (int, error) {
+	return packetMarshalTo(buf, d.Packet, d.toBytesOptions())
 }
 
 func (d *Nak) Message() *Packet {
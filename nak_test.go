@@ -1,6 +1,12 @@
 package dhcp4
 
-import "testing"
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
 
 func TestNakValidation(t *testing.T) {
 	testCase := replyValidationTestCase{
@@ -27,3 +33,46 @@ func TestNakValidation(t *testing.T) {
 
 	testCase.Test(t)
 }
+
+func TestCreateGratuitousNak(t *testing.T) {
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	rep, err := CreateGratuitousNak(mac, 0x01020304, net.IPv4(10, 0, 0, 1), net.IPv4(10, 1, 0, 1))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, rep.Validate())
+	assert.Equal(t, MessageTypeNak, rep.GetMessageType())
+	assert.Equal(t, mac, rep.GetCHAddr())
+	assert.Equal(t, []byte{1, 2, 3, 4}, rep.XID())
+	assert.Equal(t, net.IPv4(10, 1, 0, 1).To4(), rep.GetGIAddr().To4())
+	assert.Equal(t, byte(0x80), rep.GetFlags()[0])
+
+	_, err = CreateGratuitousNak(mac, 0, nil, nil)
+	assert.Equal(t, ErrInvalidAddress, err)
+	_, err = CreateGratuitousNak(mac, 0, net.IPv4(10, 0, 0, 1), net.ParseIP("::1"))
+	assert.Equal(t, ErrInvalidAddress, err)
+}
+
+func TestSendNak(t *testing.T) {
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	sid := net.IPv4(10, 0, 0, 1)
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, 0).Return(0, nil)
+
+	// Via the relay agent
+	assert.NoError(t, SendNak(mac, 1, sid, net.IPv4(10, 1, 0, 1), pw))
+	assert.Equal(t, &net.UDPAddr{IP: net.IPv4(10, 1, 0, 1), Port: ServerPort}, pw.Calls[0].Arguments.Get(1))
+
+	// Broadcast on the local network
+	assert.NoError(t, SendNak(mac, 1, sid, nil, pw))
+	assert.Equal(t, &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}, pw.Calls[1].Arguments.Get(1))
+
+	rep, err := PacketFromBytes(pw.Calls[1].Arguments.Get(0).([]byte))
+	if assert.NoError(t, err) {
+		assert.Equal(t, MessageTypeNak, rep.GetMessageType())
+		id, _ := rep.ServerID()
+		assert.Equal(t, sid, id)
+	}
+}
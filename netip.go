@@ -0,0 +1,139 @@
+package dhcp4
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+)
+
+// The netip.Addr accessors below are alternatives to the net.IP ones that
+// don't allocate, and return comparable values that can be used as map keys.
+
+// addrFrom returns the IPv4 address in the 4-byte value f, or the zero Addr if
+// f has another length.
+func addrFrom(f []byte) netip.Addr {
+	if len(f) != 4 {
+		return netip.Addr{}
+	}
+
+	return netip.AddrFrom4([4]byte{f[0], f[1], f[2], f[3]})
+}
+
+// setAddrFrom sets the 4-byte field f to the IPv4 address a, which may be an
+// IPv4-mapped IPv6 address. It returns ErrInvalidAddress for other addresses.
+func setAddrFrom(f []byte, a netip.Addr) error {
+	a = a.Unmap()
+	if !a.Is4() {
+		return ErrInvalidAddress
+	}
+
+	b := a.As4()
+	copy(f, b[:])
+	return nil
+}
+
+// GetCIAddrAddr gets the current IP address of the client, like GetCIAddr.
+func (p RawPacket) GetCIAddrAddr() netip.Addr {
+	return addrFrom(p.CIAddr())
+}
+
+// SetCIAddrAddr sets the current IP address of the client, like SetCIAddr.
+func (p RawPacket) SetCIAddrAddr(a netip.Addr) error {
+	return setAddrFrom(p.CIAddr(), a)
+}
+
+// GetYIAddrAddr gets the IP address offered or assigned to the client, like
+// GetYIAddr.
+func (p RawPacket) GetYIAddrAddr() netip.Addr {
+	return addrFrom(p.YIAddr())
+}
+
+// SetYIAddrAddr sets the IP address offered or assigned to the client, like
+// SetYIAddr.
+func (p RawPacket) SetYIAddrAddr(a netip.Addr) error {
+	return setAddrFrom(p.YIAddr(), a)
+}
+
+// GetSIAddrAddr gets the IP address of the next server to use in bootstrap,
+// like GetSIAddr.
+func (p RawPacket) GetSIAddrAddr() netip.Addr {
+	return addrFrom(p.SIAddr())
+}
+
+// SetSIAddrAddr sets the IP address of the next server to use in bootstrap,
+// like SetSIAddr.
+func (p RawPacket) SetSIAddrAddr(a netip.Addr) error {
+	return setAddrFrom(p.SIAddr(), a)
+}
+
+// GetGIAddrAddr gets the IP address of the relay agent, like GetGIAddr.
+func (p RawPacket) GetGIAddrAddr() netip.Addr {
+	return addrFrom(p.GIAddr())
+}
+
+// SetGIAddrAddr sets the IP address of the relay agent, like SetGIAddr.
+func (p RawPacket) SetGIAddrAddr(a netip.Addr) error {
+	return setAddrFrom(p.GIAddr(), a)
+}
+
+// GetAddr gets the IP value of an option, like GetIP.
+func (om OptionMap) GetAddr(o Option) (netip.Addr, bool) {
+	if v, ok := om.GetOption(o); ok && len(v) == 4 {
+		return addrFrom(v), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// SetAddr sets the IP value of an option, like SetIP. It returns an
+// *OptionValueError if a is not an IPv4 address.
+func (om OptionMap) SetAddr(o Option, a netip.Addr) error {
+	a = a.Unmap()
+	if !a.Is4() {
+		return &OptionValueError{Option: o, Kind: KindIP, Invalid: []net.IP{net.IP(a.AsSlice())}}
+	}
+
+	b := a.As4()
+	return om.setChecked(o, b[:])
+}
+
+// Addr returns the address of the lease as a netip.Addr.
+func (l Lease) Addr() netip.Addr {
+	a, _ := netip.AddrFromSlice(l.IP.To4())
+	return a
+}
+
+// addrToUint32 is the netip.Addr counterpart of ipToUint32.
+func addrToUint32(a netip.Addr) (uint32, bool) {
+	a = a.Unmap()
+	if !a.Is4() {
+		return 0, false
+	}
+
+	b := a.As4()
+	return binary.BigEndian.Uint32(b[:]), true
+}
+
+// NewLeasePoolPrefix returns a pool with the host addresses of prefix, which
+// must be an IPv4 prefix, like NewLeasePool.
+func NewLeasePoolPrefix(prefix netip.Prefix, exclude ...netip.Addr) *LeasePool {
+	prefix = prefix.Masked()
+	network := &net.IPNet{
+		IP:   net.IP(prefix.Addr().AsSlice()),
+		Mask: net.CIDRMask(prefix.Bits(), 32),
+	}
+
+	ips := make([]net.IP, len(exclude))
+	for i, a := range exclude {
+		ips[i] = net.IP(a.Unmap().AsSlice())
+	}
+
+	return NewLeasePool(network, ips...)
+}
+
+// ContainsAddr returns whether a is an address in the pool, like Contains. It
+// doesn't allocate.
+func (p *LeasePool) ContainsAddr(a netip.Addr) bool {
+	v, ok := addrToUint32(a)
+	return ok && p.inRanges(v) && !p.exclude[v]
+}
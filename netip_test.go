@@ -0,0 +1,69 @@
+package dhcp4
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketAddrAccessors(t *testing.T) {
+	p := NewPacket(BootReply)
+	a := netip.MustParseAddr("10.0.0.5")
+
+	assert.NoError(t, p.SetYIAddrAddr(a))
+	assert.Equal(t, a, p.GetYIAddrAddr())
+	assert.True(t, p.GetYIAddr().Equal(net.IPv4(10, 0, 0, 5)))
+
+	// IPv4-mapped addresses are unmapped
+	assert.NoError(t, p.SetCIAddrAddr(netip.MustParseAddr("::ffff:10.0.0.6")))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.6"), p.GetCIAddrAddr())
+
+	assert.NoError(t, p.SetGIAddr(net.IPv4(10, 0, 0, 1)))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), p.GetGIAddrAddr())
+	assert.Equal(t, netip.IPv4Unspecified(), p.GetSIAddrAddr())
+
+	assert.Equal(t, ErrInvalidAddress, p.SetSIAddrAddr(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, ErrInvalidAddress, p.SetSIAddrAddr(netip.Addr{}))
+
+	// Like GetYIAddr, truncated packets have the unspecified address
+	assert.Equal(t, netip.IPv4Unspecified(), RawPacket(make([]byte, 20)).GetYIAddrAddr())
+
+	allocs := testing.AllocsPerRun(100, func() { p.GetYIAddrAddr() })
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestOptionMapAddr(t *testing.T) {
+	om := make(OptionMap)
+	a := netip.MustParseAddr("10.0.0.1")
+
+	assert.NoError(t, om.SetAddr(OptionDHCPServerID, a))
+	v, ok := om.GetAddr(OptionDHCPServerID)
+	assert.True(t, ok)
+	assert.Equal(t, a, v)
+
+	ip, _ := om.GetIP(OptionDHCPServerID)
+	assert.True(t, ip.Equal(net.IPv4(10, 0, 0, 1)))
+
+	_, ok = om.GetAddr(OptionRouter)
+	assert.False(t, ok)
+
+	err := om.SetAddr(OptionRouter, netip.MustParseAddr("2001:db8::1"))
+	assert.IsType(t, &OptionValueError{}, err)
+}
+
+func TestLeasePoolPrefix(t *testing.T) {
+	p := NewLeasePoolPrefix(netip.MustParsePrefix("10.0.0.7/30"), netip.MustParseAddr("10.0.0.5"))
+
+	assert.False(t, p.ContainsAddr(netip.MustParseAddr("10.0.0.4")))
+	assert.False(t, p.ContainsAddr(netip.MustParseAddr("10.0.0.5")))
+	assert.True(t, p.ContainsAddr(netip.MustParseAddr("10.0.0.6")))
+	assert.True(t, p.ContainsAddr(netip.MustParseAddr("::ffff:10.0.0.6")))
+	assert.False(t, p.ContainsAddr(netip.MustParseAddr("10.0.0.7")))
+	assert.False(t, p.ContainsAddr(netip.MustParseAddr("2001:db8::6")))
+
+	l, err := p.Allocate([]byte("a"), nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("10.0.0.6"), l.Addr())
+}
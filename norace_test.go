@@ -0,0 +1,6 @@
+//go:build !race
+// +build !race
+
+package dhcp4
+
+const raceEnabled = false
@@ -0,0 +1,88 @@
+package dhcp4
+
+import "net"
+
+// notificationQueueSize is the buffer size of the channel returned by
+// Server.Notifications.
+const notificationQueueSize = 64
+
+// Event is a notification sent by a Server, see Server.Notifications, or by
+// the sweeper of a LeasePool. It is one of DeclineEvent, ReleaseEvent,
+// LeaseGrantedEvent, ExpireEvent or ClientIDConflictEvent.
+type Event interface {
+	event()
+}
+
+// DeclineEvent is sent by a SimpleServer when a client declines address IP
+// with a DHCPDECLINE, because it found the address to be in use, once the
+// pool has marked the address as declined.
+type DeclineEvent struct {
+	IP       net.IP
+	ClientID []byte
+}
+
+// ReleaseEvent is sent by a SimpleServer when a client releases address IP
+// with a DHCPRELEASE, once the pool has freed the client's lease. Releases of
+// addresses the client doesn't hold are not reported.
+type ReleaseEvent struct {
+	IP       net.IP
+	ClientID []byte
+}
+
+// LeaseGrantedEvent is sent after a DHCPACK in response to a DHCPREQUEST has
+// been sent, like the server's OnLeaseGranted hook is called.
+type LeaseGrantedEvent struct {
+	Lease Lease
+	FQDN  *ClientFQDN
+}
+
+// ExpireEvent is sent by the sweeper of a LeasePool when it frees an expired
+// lease (see LeasePool.StartSweeper).
+type ExpireEvent struct {
+	Lease Lease
+}
+
+func (DeclineEvent) event()      {}
+func (ReleaseEvent) event()      {}
+func (LeaseGrantedEvent) event() {}
+func (ExpireEvent) event()       {}
+
+// Notifications returns a channel that receives an Event for every address
+// declined, released, or leased by the server. A Server only reports the
+// leases granted by its handler; declines and releases are reported by
+// SimpleServer, once its pool has accepted them. Events are only sent after
+// the first call; every call returns the same channel. The channel is
+// buffered. If it is full, because the receiver doesn't keep up, events are
+// dropped and reported to the server's Metrics, so that sending never blocks
+// the server.
+func (s *Server) Notifications() <-chan Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.events == nil {
+		s.events = make(chan Event, notificationQueueSize)
+	}
+
+	return s.events
+}
+
+// notify sends e to the notification channel, if any, without blocking.
+func (s *Server) notify(e Event) {
+	if s == nil {
+		return
+	}
+
+	s.mu.RLock()
+	events := s.events
+	s.mu.RUnlock()
+
+	if events == nil {
+		return
+	}
+
+	select {
+	case events <- e:
+	default:
+		s.metrics().NotificationDropped()
+	}
+}
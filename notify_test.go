@@ -0,0 +1,56 @@
+package dhcp4
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerNotifications(t *testing.T) {
+	decline := NewPacket(BootRequest)
+	decline.SetMessageType(MessageTypeDecline)
+	decline.SetCHAddr(net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	decline.SetIP(OptionAddressRequest, net.IPv4(10, 0, 0, 5))
+	db, _ := PacketToBytes(decline, nil)
+
+	release := NewPacket(BootRequest)
+	release.SetMessageType(MessageTypeRelease)
+	release.SetCHAddr(net.HardwareAddr{2, 0, 0, 0, 0, 2})
+	release.SetCIAddr(net.IPv4(10, 0, 0, 6))
+	rb, _ := PacketToBytes(release, nil)
+
+	pc := &testPacketConn{}
+	pc.ReadSuccess(db)
+	pc.ReadSuccess(rb)
+	pc.ReadError(io.EOF)
+
+	s := Server{Handler: HandlerFunc(func(ReplyWriter, *Packet) {})}
+	events := s.Notifications()
+	assert.Equal(t, events, s.Notifications())
+
+	s.Serve(pc)
+
+	// Requests are not reported before the handler has validated them
+	assert.Len(t, events, 0)
+}
+
+func TestServerNotificationsDropped(t *testing.T) {
+	m := &testMetrics{}
+	m.On("NotificationDropped").Return()
+
+	s := Server{Metrics: m}
+
+	// Without receiver, nothing is sent or dropped
+	s.notify(ReleaseEvent{})
+	m.AssertNotCalled(t, "NotificationDropped")
+
+	events := s.Notifications()
+	for i := 0; i < notificationQueueSize+2; i++ {
+		s.notify(ReleaseEvent{})
+	}
+
+	assert.Len(t, events, notificationQueueSize)
+	m.AssertNumberOfCalls(t, "NotificationDropped", 2)
+}
@@ -44,11 +44,17 @@ var dhcpOfferValidation = []Validation{
 	ValidateMustNot(OptionDHCPMaxMsgSize),
 }
 
+// Validate checks the options of the reply (see RFC2131, table 3), and that
+// 'yiaddr' is not a link-local address, which a server must not lease.
 func (d *Offer) Validate() error {
+	if IsLinkLocal(d.GetYIAddr()) {
+		return ErrLinkLocalAddress
+	}
+
 	return Validate(d.Packet, dhcpOfferValidation)
 }
 
-func (d *Offer) ToBytes() ([]byte, error) {
+func (d *Offer) toBytesOptions() *packetToBytesOptions {
 	opts := packetToBytesOptions{}
 
 	// Copy MaxMsgSize if set in the request
@@ -56,7 +62,22 @@ func (d *Offer) ToBytes() ([]byte, error) {
 		opts.maxLen = binary.BigEndian.Uint16(v)
 	}
 
-	return PacketToBytes(d.Packet, &opts)
+	// Write options in the order requested by the client
+	if l, ok := d.Message().GetOption(OptionParameterList); ok {
+		opts.parameterList = l
+	}
+
+	return &opts
+}
+
+func (d *Offer) ToBytes() ([]byte, error) {
+	return PacketToBytes(d.Packet, d.toBytesOptions())
+}
+
+// MarshalTo serializes the reply into buf, like ToBytes, and returns the
+// number of bytes written. It returns io.ErrShortBuffer if buf is too small.
+func (d *Offer) MarshalTo(buf []byte) (int, error) {
+	return packetMarshalTo(buf, d.Packet, d.toBytesOptions())
 }
 
 func (d *Offer) Message() *Packet {
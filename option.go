@@ -23,6 +23,23 @@ const (
 	MessageTypeNak      = MessageType(6)
 	MessageTypeRelease  = MessageType(7)
 	MessageTypeInform   = MessageType(8)
+
+	// MessageTypeForceRenew is the server to client message forcing the client
+	// to renew its lease (RFC3203). It MUST be authenticated, for example using
+	// the reconfigure key protocol (see SetReconfigureKey), as an
+	// unauthenticated DHCPFORCERENEW can be used to mount denial of service
+	// attacks.
+	MessageTypeForceRenew = MessageType(9)
+
+	// Leasequery messages (RFC4388) and bulk leasequery messages (RFC6926).
+	MessageTypeLeaseQuery       = MessageType(10)
+	MessageTypeLeaseUnassigned  = MessageType(11)
+	MessageTypeLeaseUnknown     = MessageType(12)
+	MessageTypeLeaseActive      = MessageType(13)
+	MessageTypeBulkLeaseQuery   = MessageType(14)
+	MessageTypeLeaseQueryDone   = MessageType(15)
+	MessageTypeActiveLeaseQuery = MessageType(16)
+	MessageTypeLeaseQueryStatus = MessageType(17)
 )
 
 var messageTypeStrings = map[MessageType]string{
@@ -34,6 +51,17 @@ var messageTypeStrings = map[MessageType]string{
 	MessageTypeNak:      "DHCPNAK",
 	MessageTypeRelease:  "DHCPRELEASE",
 	MessageTypeInform:   "DHCPINFORM",
+
+	MessageTypeForceRenew: "DHCPFORCERENEW",
+
+	MessageTypeLeaseQuery:       "DHCPLEASEQUERY",
+	MessageTypeLeaseUnassigned:  "DHCPLEASEUNASSIGNED",
+	MessageTypeLeaseUnknown:     "DHCPLEASEUNKNOWN",
+	MessageTypeLeaseActive:      "DHCPLEASEACTIVE",
+	MessageTypeBulkLeaseQuery:   "DHCPBULKLEASEQUERY",
+	MessageTypeLeaseQueryDone:   "DHCPLEASEQUERYDONE",
+	MessageTypeActiveLeaseQuery: "DHCPACTIVELEASEQUERY",
+	MessageTypeLeaseQueryStatus: "DHCPLEASEQUERYSTATUS",
 }
 
 func (t MessageType) String() string {
@@ -53,19 +81,26 @@ type OptionGetter interface {
 	GetUint32(Option) (uint32, bool)
 	GetString(Option) (string, bool)
 	GetIP(Option) (net.IP, bool)
+	GetIPs(Option) ([]net.IP, bool)
 	GetDuration(Option) (time.Duration, bool)
 }
 
 // OptionSetter defines a bag of functions that can be used to set options.
+// Except for SetOption, these check the value against the kind registered for
+// the option (see RegisterOptionKind).
 type OptionSetter interface {
 	SetOption(Option, []byte)
 	SetMessageType(MessageType)
-	SetUint8(Option, uint8)
-	SetUint16(Option, uint16)
-	SetUint32(Option, uint32)
-	SetString(Option, string)
-	SetIP(Option, net.IP)
-	SetDuration(Option, time.Duration)
+	SetUint8(Option, uint8) error
+	SetUint16(Option, uint16) error
+	SetUint32(Option, uint32) error
+	SetString(Option, string) error
+	SetIP(Option, net.IP) error
+	SetIPs(Option, []net.IP) error
+	AppendIPs(Option, ...net.IP) error
+	AddDNS(...net.IP) error
+	AddRouter(...net.IP) error
+	SetDuration(Option, time.Duration) error
 }
 
 // Option is the type for DHCP option tags.
@@ -107,12 +142,26 @@ func (om OptionMap) GetOption(o Option) ([]byte, bool) {
 	return v, ok
 }
 
-// SetOption sets the []byte value of an option.
+// SetOption sets the []byte value of an option. The value is not checked.
 func (om OptionMap) SetOption(o Option, v []byte) {
 	om[o] = v
 }
 
+// setChecked sets the []byte value of an option if it matches the kind
+// registered for the option.
+func (om OptionMap) setChecked(o Option, v []byte) error {
+	if err := checkOption(o, v); err != nil {
+		return err
+	}
+
+	om.SetOption(o, v)
+	return nil
+}
+
 // GetMessageType gets the message type from the DHCPMsgType option field.
+// The option map of a packet includes the options stored in the `file` and
+// `sname` fields if the packet uses option overloading, so the message type
+// is found there as well.
 func (om OptionMap) GetMessageType() MessageType {
 	v, ok := om.GetOption(OptionDHCPMsgType)
 	if !ok || len(v) != 1 {
@@ -127,6 +176,22 @@ func (om OptionMap) SetMessageType(m MessageType) {
 	om.SetOption(OptionDHCPMsgType, []byte{byte(m)})
 }
 
+// GetParameterList gets the list of options from the Parameter Request List
+// option field.
+func (om OptionMap) GetParameterList() ([]Option, bool) {
+	v, ok := om.GetOption(OptionParameterList)
+	if !ok {
+		return nil, false
+	}
+
+	params := make([]Option, len(v))
+	for i, o := range v {
+		params[i] = Option(o)
+	}
+
+	return params, true
+}
+
 // GetUint8 gets the 8 bit unsigned integer value of an option.
 func (om OptionMap) GetUint8(o Option) (uint8, bool) {
 	if v, ok := om.GetOption(o); ok && len(v) == 1 {
@@ -137,10 +202,10 @@ func (om OptionMap) GetUint8(o Option) (uint8, bool) {
 }
 
 // SetUint8 sets the 8 bit unsigned integer value of an option.
-func (om OptionMap) SetUint8(o Option, v uint8) {
+func (om OptionMap) SetUint8(o Option, v uint8) error {
 	b := make([]byte, 1)
 	b[0] = uint8(v)
-	om.SetOption(o, b)
+	return om.setChecked(o, b)
 }
 
 // GetUint16 gets the 16 bit unsigned integer value of an option.
@@ -153,10 +218,10 @@ func (om OptionMap) GetUint16(o Option) (uint16, bool) {
 }
 
 // SetUint16 sets the 16 bit unsigned integer value of an option.
-func (om OptionMap) SetUint16(o Option, v uint16) {
+func (om OptionMap) SetUint16(o Option, v uint16) error {
 	b := make([]byte, 2)
 	binary.BigEndian.PutUint16(b, v)
-	om.SetOption(o, b)
+	return om.setChecked(o, b)
 }
 
 // GetUint32 gets the 32 bit unsigned integer value of an option.
@@ -169,10 +234,41 @@ func (om OptionMap) GetUint32(o Option) (uint32, bool) {
 }
 
 // SetUint32 sets the 32 bit unsigned integer value of an option.
-func (om OptionMap) SetUint32(o Option, v uint32) {
+func (om OptionMap) SetUint32(o Option, v uint32) error {
 	b := make([]byte, 4)
 	binary.BigEndian.PutUint32(b, v)
-	om.SetOption(o, b)
+	return om.setChecked(o, b)
+}
+
+// GetInt32 gets the 32 bit signed integer value of an option.
+func (om OptionMap) GetInt32(o Option) (int32, bool) {
+	v, ok := om.GetUint32(o)
+	return int32(v), ok
+}
+
+// SetInt32 sets the 32 bit signed integer value of an option.
+func (om OptionMap) SetInt32(o Option, v int32) error {
+	return om.SetUint32(o, uint32(v))
+}
+
+// GetBool gets the boolean value of an option. Values other than 0 and 1 are
+// invalid.
+func (om OptionMap) GetBool(o Option) (bool, bool) {
+	if v, ok := om.GetOption(o); ok && len(v) == 1 && v[0] <= 1 {
+		return v[0] == 1, true
+	}
+
+	return false, false
+}
+
+// SetBool sets the boolean value of an option.
+func (om OptionMap) SetBool(o Option, v bool) error {
+	b := []byte{0}
+	if v {
+		b[0] = 1
+	}
+
+	return om.setChecked(o, b)
 }
 
 // GetString gets the string value of an option.
@@ -185,8 +281,8 @@ func (om OptionMap) GetString(o Option) (string, bool) {
 }
 
 // SetString sets the string value of an option.
-func (om OptionMap) SetString(o Option, v string) {
-	om.SetOption(o, []byte(v))
+func (om OptionMap) SetString(o Option, v string) error {
+	return om.setChecked(o, []byte(v))
 }
 
 // GetIP gets the IP value of an option.
@@ -198,9 +294,91 @@ func (om OptionMap) GetIP(o Option) (net.IP, bool) {
 	return nil, false
 }
 
-// GetIP sets the IP value of an option.
-func (om OptionMap) SetIP(o Option, v net.IP) {
-	om.SetOption(o, []byte(v.To4()))
+// SetIP sets the IP value of an option. IPv4 addresses in 16-byte form are
+// encoded in 4 bytes. It returns an *OptionValueError if the IP is not an IPv4
+// address.
+func (om OptionMap) SetIP(o Option, v net.IP) error {
+	b := v.To4()
+	if b == nil {
+		return &OptionValueError{Option: o, Kind: KindIP, Invalid: []net.IP{v}}
+	}
+
+	return om.setChecked(o, []byte(b))
+}
+
+// GetIPs gets the list of IPs value of an option.
+func (om OptionMap) GetIPs(o Option) ([]net.IP, bool) {
+	v, ok := om.GetOption(o)
+	if !ok || len(v)%4 != 0 {
+		return nil, false
+	}
+
+	ips := make([]net.IP, 0, len(v)/4)
+	for ; len(v) > 0; v = v[4:] {
+		ips = append(ips, net.IPv4(v[0], v[1], v[2], v[3]))
+	}
+
+	return ips, true
+}
+
+// SetIPs sets the list of IPs value of an option. IPv4 addresses in 16-byte
+// form are encoded in 4 bytes. It returns an *OptionValueError listing the IPs
+// that are not IPv4 addresses, if any, and leaves the option unchanged.
+func (om OptionMap) SetIPs(o Option, v []net.IP) error {
+	var invalid []net.IP
+
+	b := make([]byte, 0, 4*len(v))
+	for _, ip := range v {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			invalid = append(invalid, ip)
+			continue
+		}
+
+		b = append(b, ip4...)
+	}
+
+	if invalid != nil {
+		return &OptionValueError{Option: o, Kind: KindIPs, Invalid: invalid}
+	}
+
+	return om.setChecked(o, b)
+}
+
+// AppendIPs appends IPs to the list of IPs value of an option. IPs that are
+// already in the list are skipped, so the order of the list is preserved.
+// It returns an error if one of the IPs is not an IPv4 address.
+func (om OptionMap) AppendIPs(o Option, v ...net.IP) error {
+	ips, _ := om.GetIPs(o)
+	for _, ip := range v {
+		if containsIP(ips, ip) {
+			continue
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return om.SetIPs(o, ips)
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddDNS appends IPs to the Domain Name Server option (6), see AppendIPs.
+func (om OptionMap) AddDNS(v ...net.IP) error {
+	return om.AppendIPs(OptionDomainServer, v...)
+}
+
+// AddRouter appends IPs to the Router option (3), see AppendIPs.
+func (om OptionMap) AddRouter(v ...net.IP) error {
+	return om.AppendIPs(OptionRouter, v...)
 }
 
 // GetDuration gets the duration value of an option, stored as a 32 bit unsigned integer.
@@ -213,12 +391,30 @@ func (om OptionMap) GetDuration(o Option) (time.Duration, bool) {
 }
 
 // SetDuration sets the duration value of an option, stored as a 32 bit unsigned integer.
-func (om OptionMap) SetDuration(o Option, v time.Duration) {
-	om.SetUint32(o, uint32(v.Seconds()))
+func (om OptionMap) SetDuration(o Option, v time.Duration) error {
+	return om.SetUint32(o, uint32(v.Seconds()))
 }
 
+// OptionMapDeserializeOptions relaxes the checks of Deserialize. By default,
+// options that are truncated or not terminated by the end tag fail with
+// ErrShortPacket. Deserialize never reads past the end of its input.
 type OptionMapDeserializeOptions struct {
+	// IgnoreMissingEndTag accepts options that end without end tag.
 	IgnoreMissingEndTag bool
+
+	// IgnoreTruncatedOption stops at an option whose length octet is missing,
+	// or declares more octets than remain, as if the options ended before it.
+	// The truncated option is dropped, and the options before it are kept.
+	IgnoreTruncatedOption bool
+}
+
+// truncated returns the error for a truncated option.
+func (opts *OptionMapDeserializeOptions) truncated() error {
+	if opts != nil && opts.IgnoreTruncatedOption {
+		return nil
+	}
+
+	return ErrShortPacket
 }
 
 // Deserialize reads options from the []byte into the option map.
@@ -245,48 +441,84 @@ func (om OptionMap) Deserialize(x []byte, opts *OptionMapDeserializeOptions) err
 
 		// Read length octet
 		if len(x) == 0 {
-			return ErrShortPacket
+			return opts.truncated()
 		}
 
 		length := int(x[0])
 		x = x[1:]
 		if len(x) < length {
-			return ErrShortPacket
+			return opts.truncated()
 		}
 
-		_, ok := om[tag]
-		if ok {
-			// We've got a bad client here; duplicate options are not allowed.
-			// Let it slide instead of throwing a fit, for the sake of robustness.
+		// Capture option and move to the next one. Options that appear more
+		// than once are concatenated (RFC3396, section 7). The value is copied
+		// so that appending to it doesn't write into the buffer being read.
+		if v, ok := om[tag]; ok {
+			om[tag] = append(append([]byte(nil), v...), x[0:length]...)
+		} else {
+			om[tag] = x[0:length]
 		}
-
-		// Capture option and move to the next one
-		om[tag] = x[0:length]
 		x = x[length:]
 	}
 
 	return nil
 }
 
-// Serialize writes the contents of the option map to a byte slice.
-func (om OptionMap) Serialize() []byte {
-	b := bytes.Buffer{}
+// walkOptions calls fn for every option in the wire-level representation b.
+// The value passed to fn aliases b. It stops at the end tag, or at the first
+// option that doesn't fit in b.
+func walkOptions(b []byte, fn func(Option, []byte)) {
+	for len(b) > 0 {
+		tag := Option(b[0])
+		b = b[1:]
+		if tag == OptionEnd {
+			return
+		}
 
-	for k, v := range om {
-		if len(v) > 255 {
+		// Padding tag
+		if tag == OptionPad {
 			continue
 		}
 
-		if err := b.WriteByte(byte(k)); err != nil {
-			panic(err)
+		if len(b) == 0 || len(b) < 1+int(b[0]) {
+			return
 		}
 
-		if err := b.WriteByte(byte(len(v))); err != nil {
-			panic(err)
-		}
+		length := int(b[0])
+		fn(tag, b[1:1+length])
+		b = b[1+length:]
+	}
+}
 
-		if _, err := b.Write(v); err != nil {
-			panic(err)
+// splitOption splits the value v of an option into chunks of at most 255
+// octets, that are each encoded as a separate option (RFC3396). A value of
+// zero octets results in a single empty chunk.
+func splitOption(v []byte) [][]byte {
+	chunks := make([][]byte, 0, 1+len(v)/255)
+	for len(v) > 255 {
+		chunks = append(chunks, v[:255])
+		v = v[255:]
+	}
+	return append(chunks, v)
+}
+
+// Serialize writes the contents of the option map to a byte slice.
+func (om OptionMap) Serialize() []byte {
+	b := bytes.Buffer{}
+
+	for k, v := range om {
+		for _, c := range splitOption(v) {
+			if err := b.WriteByte(byte(k)); err != nil {
+				panic(err)
+			}
+
+			if err := b.WriteByte(byte(len(c))); err != nil {
+				panic(err)
+			}
+
+			if _, err := b.Write(c); err != nil {
+				panic(err)
+			}
 		}
 	}
 
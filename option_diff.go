@@ -0,0 +1,116 @@
+package dhcp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OptionChange is a difference between two option maps, see OptionMap.Diff.
+// Old is nil if the option was added, New is nil if it was removed.
+type OptionChange struct {
+	Option
+	Old, New []byte
+}
+
+// Added returns whether the option was added.
+func (c OptionChange) Added() bool {
+	return c.Old == nil
+}
+
+// Removed returns whether the option was removed.
+func (c OptionChange) Removed() bool {
+	return c.New == nil
+}
+
+// String formats the change, with the values formatted according to the kind
+// registered for the option (see RegisterOptionKind).
+func (c OptionChange) String() string {
+	switch {
+	case c.Added():
+		return fmt.Sprintf("option %d added: %s", c.Option, FormatOptionValue(c.Option, c.New))
+	case c.Removed():
+		return fmt.Sprintf("option %d removed: %s", c.Option, FormatOptionValue(c.Option, c.Old))
+	}
+
+	return fmt.Sprintf("option %d changed: %s -> %s", c.Option,
+		FormatOptionValue(c.Option, c.Old), FormatOptionValue(c.Option, c.New))
+}
+
+// Diff returns the options that differ between om and other, in numeric
+// order: options only in other are added, options only in om are removed, and
+// options with different values are changed.
+func (om OptionMap) Diff(other OptionMap) []OptionChange {
+	var changes []OptionChange
+
+	keys := make(OptionMap, len(om)+len(other))
+	for k := range om {
+		keys[k] = nil
+	}
+	for k := range other {
+		keys[k] = nil
+	}
+
+	for _, k := range keys.GetSortedOptions() {
+		a, inOld := om[k]
+		b, inNew := other[k]
+
+		switch {
+		case !inOld:
+			changes = append(changes, OptionChange{Option: k, New: nonNil(b)})
+		case !inNew:
+			changes = append(changes, OptionChange{Option: k, Old: nonNil(a)})
+		case !bytes.Equal(a, b):
+			changes = append(changes, OptionChange{Option: k, Old: a, New: b})
+		}
+	}
+
+	return changes
+}
+
+// nonNil returns v, or an empty slice if v is nil, so that empty values of
+// added and removed options can be told apart from absent ones.
+func nonNil(v []byte) []byte {
+	if v == nil {
+		return []byte{}
+	}
+
+	return v
+}
+
+// FormatOptionValue formats the value of option o according to the kind
+// registered for it. Values of unregistered options, and values that don't
+// match their kind, are formatted as hex.
+func FormatOptionValue(o Option, v []byte) string {
+	k, ok := LookupOptionKind(o)
+	if !ok || !k.Check(v) {
+		return fmt.Sprintf("%x", v)
+	}
+
+	switch k {
+	case KindUint8:
+		return fmt.Sprintf("%d", v[0])
+	case KindUint16:
+		return fmt.Sprintf("%d", binary.BigEndian.Uint16(v))
+	case KindUint32:
+		return fmt.Sprintf("%d", binary.BigEndian.Uint32(v))
+	case KindInt32:
+		return fmt.Sprintf("%d", int32(binary.BigEndian.Uint32(v)))
+	case KindBool:
+		return fmt.Sprintf("%t", v[0] == 1)
+	case KindIP, KindSubnetMask:
+		return net.IP(v).String()
+	case KindIPs:
+		ips := make([]string, 0, len(v)/4)
+		for i := 0; i < len(v); i += 4 {
+			ips = append(ips, net.IP(v[i:i+4]).String())
+		}
+		return strings.Join(ips, ",")
+	case KindString:
+		return fmt.Sprintf("%q", v)
+	}
+
+	return fmt.Sprintf("%x", v)
+}
@@ -0,0 +1,44 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionMapDiff(t *testing.T) {
+	a := make(OptionMap)
+	a.SetOption(OptionRouter, []byte{10, 0, 0, 1})
+	a.SetOption(OptionHostname, []byte("foo"))
+	a.SetOption(OptionDomainServer, []byte{10, 0, 0, 2})
+
+	b := make(OptionMap)
+	b.SetOption(OptionRouter, []byte{10, 0, 0, 1})
+	b.SetOption(OptionDomainServer, []byte{10, 0, 0, 2, 10, 0, 0, 3})
+	b.SetOption(OptionRelayAgentInformation, []byte{1, 1, 'a'})
+
+	assert.Empty(t, a.Diff(a))
+
+	changes := a.Diff(b)
+	assert.Equal(t, []OptionChange{
+		{Option: OptionDomainServer, Old: []byte{10, 0, 0, 2}, New: []byte{10, 0, 0, 2, 10, 0, 0, 3}},
+		{Option: OptionHostname, Old: []byte("foo")},
+		{Option: OptionRelayAgentInformation, New: []byte{1, 1, 'a'}},
+	}, changes)
+
+	assert.Equal(t, "option 6 changed: 10.0.0.2 -> 10.0.0.2,10.0.0.3", changes[0].String())
+	assert.Equal(t, "option 12 removed: \"foo\"", changes[1].String())
+	assert.Equal(t, "option 82 added: 010161", changes[2].String())
+	assert.True(t, changes[1].Removed())
+	assert.True(t, changes[2].Added())
+}
+
+func TestFormatOptionValue(t *testing.T) {
+	assert.Equal(t, "3600", FormatOptionValue(OptionAddressTime, []byte{0, 0, 14, 16}))
+	assert.Equal(t, "-1", FormatOptionValue(OptionTimeOffset, []byte{255, 255, 255, 255}))
+	assert.Equal(t, "true", FormatOptionValue(OptionForwardOnOff, []byte{1}))
+	assert.Equal(t, "255.255.255.0", FormatOptionValue(OptionSubnetMask, []byte{255, 255, 255, 0}))
+
+	// Value not matching the kind
+	assert.Equal(t, "0a00", FormatOptionValue(OptionRouter, []byte{10, 0}))
+}
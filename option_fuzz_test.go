@@ -0,0 +1,94 @@
+package dhcp4
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fuzzOptionMap builds an option map from b, a sequence of code, length and
+// value triples. Values of repeated codes are concatenated, like they are
+// when options are parsed (RFC3396). Pad and End, which have no value, are
+// skipped.
+func fuzzOptionMap(b []byte) OptionMap {
+	om := make(OptionMap)
+	for len(b) >= 2 {
+		o, n := Option(b[0]), int(b[1])
+		b = b[2:]
+		if n > len(b) {
+			n = len(b)
+		}
+
+		if o != OptionPad && o != OptionEnd {
+			om[o] = append(append([]byte{}, om[o]...), b[:n]...)
+		}
+		b = b[n:]
+	}
+
+	return om
+}
+
+func equalOptionMaps(a, b OptionMap) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for o, v := range a {
+		w, ok := b[o]
+		if !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func FuzzOptionRoundTrip(f *testing.F) {
+	f.Add([]byte{53, 1, 1, 61, 7, 1, 2, 0, 0, 0, 0, 1})
+	f.Add([]byte{12, 0})
+	f.Add([]byte{121, 255})
+	f.Add(append([]byte{15, 255}, make([]byte, 255)...))
+	f.Add([]byte{3, 4, 10, 0, 0, 1, 3, 4, 10, 0, 0, 2})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		om := fuzzOptionMap(b)
+
+		// Options field only
+		got := make(OptionMap)
+		if err := got.Deserialize(om.Serialize(), nil); err != nil {
+			t.Fatalf("deserialize: %s", err)
+		}
+		if !equalOptionMaps(om, got) {
+			t.Fatalf("options round trip: got %v, want %v", got, om)
+		}
+
+		// Packet, with options overloaded into 'file' and 'sname' if needed.
+		// The Option Overload option is set by the encoder.
+		delete(om, OptionOverload)
+		p := NewPacket(BootRequest)
+		for o, v := range om {
+			p.SetOption(o, v)
+		}
+
+		raw, err := PacketToBytes(p, nil)
+		if err != nil {
+			return
+		}
+
+		q, err := PacketFromBytes(raw)
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		delete(q.OptionMap, OptionOverload)
+
+		// Options that don't fit in the packet are skipped by the encoder;
+		// the others must be intact.
+		for o, v := range q.OptionMap {
+			if w, ok := om[o]; !ok || !bytes.Equal(v, w) {
+				t.Fatalf("packet round trip: option %d is %v, want %v", o, v, w)
+			}
+		}
+		if len(om.Serialize()) <= 1500-240-3 && !equalOptionMaps(om, q.OptionMap) {
+			t.Fatalf("packet round trip: got %v, want %v", q.OptionMap, om)
+		}
+	})
+}
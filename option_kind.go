@@ -0,0 +1,229 @@
+package dhcp4
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OptionKind describes the shape of an option's value.
+type OptionKind int
+
+const (
+	// KindOpaque allows values of any length.
+	KindOpaque = OptionKind(iota)
+	// KindUint8 is a 1 octet unsigned integer.
+	KindUint8
+	// KindUint16 is a 2 octet unsigned integer.
+	KindUint16
+	// KindUint32 is a 4 octet unsigned integer.
+	KindUint32
+	// KindInt32 is a 4 octet signed integer.
+	KindInt32
+	// KindBool is a 1 octet boolean, either 0 or 1.
+	KindBool
+	// KindIP is a single IPv4 address.
+	KindIP
+	// KindIPs is a list of one or more IPv4 addresses.
+	KindIPs
+	// KindSubnetMask is an IPv4 address with contiguous leading ones.
+	KindSubnetMask
+	// KindString is a string of at least 1 octet.
+	KindString
+)
+
+var optionKindStrings = map[OptionKind]string{
+	KindOpaque:     "opaque",
+	KindUint8:      "uint8",
+	KindUint16:     "uint16",
+	KindUint32:     "uint32",
+	KindInt32:      "int32",
+	KindBool:       "bool",
+	KindIP:         "ip",
+	KindIPs:        "ips",
+	KindSubnetMask: "subnet_mask",
+	KindString:     "string",
+}
+
+func (k OptionKind) String() string {
+	if s, ok := optionKindStrings[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("OptionKind(%d)", k)
+}
+
+// Check returns whether v is a valid value for this kind.
+func (k OptionKind) Check(v []byte) bool {
+	switch k {
+	case KindUint8:
+		return len(v) == 1
+	case KindUint16:
+		return len(v) == 2
+	case KindUint32, KindInt32:
+		return len(v) == 4
+	case KindBool:
+		return len(v) == 1 && v[0] <= 1
+	case KindIP:
+		return len(v) == 4
+	case KindIPs:
+		return len(v) >= 4 && len(v)%4 == 0
+	case KindSubnetMask:
+		return len(v) == 4 && isContiguousMask(v)
+	case KindString:
+		return len(v) >= 1
+	}
+
+	return true
+}
+
+// isContiguousMask returns whether the mask m consists of a run of ones,
+// followed by a run of zeroes.
+func isContiguousMask(m []byte) bool {
+	zero := false
+	for _, b := range m {
+		for i := 7; i >= 0; i-- {
+			bit := b&(1<<uint(i)) != 0
+			if bit && zero {
+				return false
+			}
+			if !bit {
+				zero = true
+			}
+		}
+	}
+	return true
+}
+
+// optionKinds maps option tags to the kind of their value, as defined in the
+// RFC introducing the option.
+var optionKinds = map[Option]OptionKind{
+	// RFC2132 Section 3: RFC 1497 Vendor Extensions
+	OptionSubnetMask:    KindSubnetMask,
+	OptionTimeOffset:    KindInt32,
+	OptionRouter:        KindIPs,
+	OptionTimeServer:    KindIPs,
+	OptionNameServer:    KindIPs,
+	OptionDomainServer:  KindIPs,
+	OptionLogServer:     KindIPs,
+	OptionQuotesServer:  KindIPs,
+	OptionLPRServer:     KindIPs,
+	OptionImpressServer: KindIPs,
+	OptionRLPServer:     KindIPs,
+	OptionHostname:      KindString,
+	OptionBootFileSize:  KindUint16,
+	OptionMeritDumpFile: KindString,
+	OptionDomainName:    KindString,
+	OptionSwapServer:    KindIP,
+	OptionRootPath:      KindString,
+	OptionExtensionFile: KindString,
+
+	// RFC2132 Section 4: IP Layer Parameters per Host
+	OptionForwardOnOff:  KindBool,
+	OptionSrcRteOnOff:   KindBool,
+	OptionMaxDGAssembly: KindUint16,
+	OptionDefaultIPTTL:  KindUint8,
+	OptionMTUTimeout:    KindUint32,
+
+	// RFC2132 Section 5: IP Layer Parameters per Interface
+	OptionMTUInterface:     KindUint16,
+	OptionMTUSubnet:        KindBool,
+	OptionBroadcastAddress: KindIP,
+	OptionMaskDiscovery:    KindBool,
+	OptionMaskSupplier:     KindBool,
+	OptionRouterDiscovery:  KindBool,
+	OptionRouterRequest:    KindIP,
+
+	// RFC2132 Section 6: Link Layer Parameters per Interface
+	OptionTrailers:   KindBool,
+	OptionARPTimeout: KindUint32,
+	OptionEthernet:   KindBool,
+
+	// RFC2132 Section 7: TCP Parameters
+	OptionDefaultTCPTTL: KindUint8,
+	OptionKeepaliveTime: KindUint32,
+	OptionKeepaliveData: KindBool,
+
+	// RFC2132 Section 8: Application and Service Parameters
+	OptionNISDomain:        KindString,
+	OptionNISServers:       KindIPs,
+	OptionNTPServers:       KindIPs,
+	OptionNETBIOSNameSrv:   KindIPs,
+	OptionNETBIOSDistSrv:   KindIPs,
+	OptionNETBIOSNodeType:  KindUint8,
+	OptionNETBIOSScope:     KindString,
+	OptionXWindowFont:      KindIPs,
+	OptionXWindowManager:   KindIPs,
+	OptionNISDomainName:    KindString,
+	OptionNISServerAddr:    KindIPs,
+	OptionSMTPServer:       KindIPs,
+	OptionPOP3Server:       KindIPs,
+	OptionNNTPServer:       KindIPs,
+	OptionWWWServer:        KindIPs,
+	OptionFingerServer:     KindIPs,
+	OptionIRCServer:        KindIPs,
+	OptionStreetTalkServer: KindIPs,
+	OptionSTDAServer:       KindIPs,
+
+	// RFC2132 Section 9: DHCP Extensions
+	OptionAddressRequest: KindIP,
+	OptionAddressTime:    KindUint32,
+	OptionOverload:       KindUint8,
+	OptionServerName:     KindString,
+	OptionBootfileName:   KindString,
+	OptionDHCPMsgType:    KindUint8,
+	OptionDHCPServerID:   KindIP,
+	OptionDHCPMessage:    KindString,
+	OptionDHCPMaxMsgSize: KindUint16,
+	OptionRenewalTime:    KindUint32,
+	OptionRebindingTime:  KindUint32,
+	OptionClassID:        KindString,
+
+	// RFC4388: DHCP Leasequery
+	OptionClientLastTransactionTimeOption: KindUint32,
+	OptionAssociatedIPOption:              KindIPs,
+}
+
+// RegisterOptionKind registers the kind of the value of option o, overriding
+// any kind already registered for it. Options without a registered kind,
+// such as site-specific (224-254) options, are not checked by the typed
+// setters on OptionMap. Registering a kind for such an option opts it in.
+// Like SetOptionFormatter, this should be called before packets are handled.
+func RegisterOptionKind(o Option, k OptionKind) {
+	optionKinds[o] = k
+}
+
+// LookupOptionKind returns the kind registered for option o, if any.
+func LookupOptionKind(o Option) (OptionKind, bool) {
+	k, ok := optionKinds[o]
+	return k, ok
+}
+
+// OptionValueError is returned by the typed setters on OptionMap when a value
+// doesn't match the kind registered for the option. For addresses that are not
+// IPv4 addresses, Invalid lists the offending addresses.
+type OptionValueError struct {
+	Option
+	Kind    OptionKind
+	Invalid []net.IP
+}
+
+func (e *OptionValueError) Error() string {
+	if len(e.Invalid) == 0 {
+		return fmt.Sprintf("dhcp4: invalid value for option %d (expected %s)", e.Option, e.Kind)
+	}
+
+	ips := make([]string, len(e.Invalid))
+	for i, ip := range e.Invalid {
+		ips[i] = ip.String()
+	}
+
+	return fmt.Sprintf("dhcp4: invalid value for option %d (expected %s): %s", e.Option, e.Kind, strings.Join(ips, ", "))
+}
+
+// checkOption checks v against the kind registered for option o.
+func checkOption(o Option, v []byte) error {
+	if k, ok := LookupOptionKind(o); ok && !k.Check(v) {
+		return &OptionValueError{Option: o, Kind: k}
+	}
+	return nil
+}
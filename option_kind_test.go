@@ -0,0 +1,93 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionKindCheck(t *testing.T) {
+	testCases := []struct {
+		kind  OptionKind
+		value []byte
+		valid bool
+	}{
+		{KindOpaque, nil, true},
+		{KindOpaque, []byte{1, 2, 3}, true},
+		{KindUint8, []byte{1}, true},
+		{KindUint8, []byte{1, 2}, false},
+		{KindUint16, []byte{1, 2}, true},
+		{KindUint16, []byte{1}, false},
+		{KindUint32, []byte{1, 2, 3, 4}, true},
+		{KindUint32, []byte{1, 2, 3}, false},
+		{KindBool, []byte{0}, true},
+		{KindBool, []byte{1}, true},
+		{KindBool, []byte{2}, false},
+		{KindIP, []byte{1, 2, 3, 4}, true},
+		{KindIP, []byte{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{KindIPs, []byte{1, 2, 3, 4, 5, 6, 7, 8}, true},
+		{KindIPs, []byte{1, 2, 3, 4, 5}, false},
+		{KindIPs, nil, false},
+		{KindSubnetMask, []byte{255, 255, 255, 0}, true},
+		{KindSubnetMask, []byte{255, 255, 240, 0}, true},
+		{KindSubnetMask, []byte{0, 0, 0, 0}, true},
+		{KindSubnetMask, []byte{255, 0, 255, 0}, false},
+		{KindSubnetMask, []byte{255, 255, 255}, false},
+		{KindString, []byte("foo"), true},
+		{KindString, []byte{}, false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.valid, testCase.kind.Check(testCase.value), "kind=%s value=%v", testCase.kind, testCase.value)
+	}
+}
+
+func TestOptionMapTypedSettersCheckKind(t *testing.T) {
+	var err error
+
+	om := make(OptionMap)
+
+	// A 2 octet lease time is rejected
+	err = om.SetUint16(OptionAddressTime, 3600)
+	assert.Equal(t, &OptionValueError{Option: OptionAddressTime, Kind: KindUint32}, err)
+	_, ok := om.GetOption(OptionAddressTime)
+	assert.False(t, ok)
+
+	err = om.SetDuration(OptionAddressTime, time.Hour)
+	assert.NoError(t, err)
+
+	// A non-contiguous subnet mask is rejected
+	err = om.SetIP(OptionSubnetMask, net.IPv4(255, 0, 255, 0))
+	assert.Error(t, err)
+	err = om.SetIP(OptionSubnetMask, net.IPv4(255, 255, 255, 0))
+	assert.NoError(t, err)
+
+	// An IPv6 address is never a valid IP value
+	err = om.SetIP(Option(224), net.ParseIP("2001:db8::1"))
+	assert.Error(t, err)
+
+	// An empty host name is rejected
+	err = om.SetString(OptionHostname, "")
+	assert.Error(t, err)
+
+	// The raw setter is not checked
+	om.SetOption(OptionAddressTime, []byte{1, 2, 3})
+	v, _ := om.GetOption(OptionAddressTime)
+	assert.Equal(t, []byte{1, 2, 3}, v)
+}
+
+func TestRegisterOptionKind(t *testing.T) {
+	o := Option(250)
+	om := make(OptionMap)
+
+	// Unregistered options are not checked
+	assert.NoError(t, om.SetUint16(o, 1))
+
+	RegisterOptionKind(o, KindUint8)
+	defer delete(optionKinds, o)
+
+	assert.Error(t, om.SetUint16(o, 1))
+	assert.NoError(t, om.SetUint8(o, 1))
+}
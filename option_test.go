@@ -44,7 +44,7 @@ func TestOptionMapMessageType(t *testing.T) {
 }
 
 func TestOptionMapUint8(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b uint8
 
@@ -62,7 +62,7 @@ func TestOptionMapUint8(t *testing.T) {
 }
 
 func TestOptionMapUint16(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b uint16
 
@@ -80,7 +80,7 @@ func TestOptionMapUint16(t *testing.T) {
 }
 
 func TestOptionMapUint32(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b uint32
 
@@ -98,7 +98,7 @@ func TestOptionMapUint32(t *testing.T) {
 }
 
 func TestOptionMapString(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b string
 
@@ -116,7 +116,7 @@ func TestOptionMapString(t *testing.T) {
 }
 
 func TestOptionMapIP(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b net.IP
 
@@ -133,8 +133,68 @@ func TestOptionMapIP(t *testing.T) {
 	assert.Equal(t, a, b)
 }
 
+func TestOptionMapIPs(t *testing.T) {
+	var o = Option(224)
+	var ok bool
+	var a, b []net.IP
+
+	om := make(OptionMap)
+
+	_, ok = om.GetIPs(o)
+	assert.False(t, ok)
+
+	a = []net.IP{net.IPv4(1, 2, 3, 4), net.IPv4(5, 6, 7, 8)}
+	assert.NoError(t, om.SetIPs(o, a))
+
+	b, ok = om.GetIPs(o)
+	assert.True(t, ok)
+	assert.Equal(t, a, b)
+
+	// Only IPv4 addresses
+	assert.Error(t, om.SetIPs(o, []net.IP{net.ParseIP("::1")}))
+}
+
+func TestOptionMapSetIPsInvalid(t *testing.T) {
+	om := make(OptionMap)
+
+	// Encoded in 4 bytes
+	assert.NoError(t, om.SetIPs(OptionRouter, []net.IP{net.IPv4(10, 0, 0, 1), net.IP{10, 0, 0, 2}}))
+	assert.Equal(t, []byte{10, 0, 0, 1, 10, 0, 0, 2}, om[OptionRouter])
+
+	err := om.SetIPs(OptionRouter, []net.IP{net.ParseIP("::1"), net.IPv4(10, 0, 0, 3), nil})
+	assert.Equal(t, &OptionValueError{
+		Option:  OptionRouter,
+		Kind:    KindIPs,
+		Invalid: []net.IP{net.ParseIP("::1"), nil},
+	}, err)
+	assert.EqualError(t, err, "dhcp4: invalid value for option 3 (expected ips): ::1, <nil>")
+	assert.Equal(t, []byte{10, 0, 0, 1, 10, 0, 0, 2}, om[OptionRouter])
+}
+
+func TestOptionMapAppendIPs(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.NoError(t, om.AddDNS(net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 1)))
+	assert.NoError(t, om.AddDNS(net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 3), net.IPv4(10, 0, 0, 3)))
+
+	// Duplicates are skipped, order is preserved
+	ips, ok := om.GetIPs(OptionDomainServer)
+	assert.True(t, ok)
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 3)}, ips)
+
+	assert.NoError(t, om.AddRouter(net.IPv4(10, 0, 0, 254)))
+	ips, ok = om.GetIPs(OptionRouter)
+	assert.True(t, ok)
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 254)}, ips)
+
+	// The list is unchanged if an IP is invalid
+	assert.Error(t, om.AddRouter(net.IPv4(10, 0, 0, 253), net.ParseIP("::1")))
+	ips, _ = om.GetIPs(OptionRouter)
+	assert.Len(t, ips, 1)
+}
+
 func TestOptionMapDuration(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b time.Duration
 
@@ -152,7 +212,7 @@ func TestOptionMapDuration(t *testing.T) {
 }
 
 func TestOptionMapDurationTruncateSubSecond(t *testing.T) {
-	var o = Option(1)
+	var o = Option(224)
 	var ok bool
 	var a, b time.Duration
 
@@ -193,7 +253,7 @@ func TestOptionMapDecodeEncodeWithoutPtr(t *testing.T) {
 	om.SetOption(Option(20), encodeInteger(int8(-32)))
 	om.SetOption(Option(21), encodeInteger(int16(-32000)))
 	om.SetOption(Option(22), encodeInteger(int32(-32000000)))
-	om.SetString(Option(30), "thirtytwo")
+	om.SetOption(Option(30), []byte("thirtytwo"))
 
 	om.Decode(&s)
 
@@ -229,7 +289,7 @@ func TestOptionMapDecodeEncodeWithPtr(t *testing.T) {
 	om.SetOption(Option(20), encodeInteger(int8(-32)))
 	om.SetOption(Option(21), encodeInteger(int16(-32000)))
 	om.SetOption(Option(22), encodeInteger(int32(-32000000)))
-	om.SetString(Option(30), "thirtytwo")
+	om.SetOption(Option(30), []byte("thirtytwo"))
 
 	om.Decode(&s)
 
@@ -259,3 +319,32 @@ func TestOptionMapDecodeEncodeWithPtr(t *testing.T) {
 	omX.Encode(&s)
 	assert.Equal(t, om, omX)
 }
+
+func TestOptionMapDeserializeTruncatedOption(t *testing.T) {
+	testCases := [][]byte{
+		// Declared length of 255 with 3 octets remaining
+		{byte(OptionHostname), 1, 'a', byte(OptionDomainName), 255, 'f', 'o', 'o'},
+		// Missing length octet
+		{byte(OptionHostname), 1, 'a', byte(OptionDomainName)},
+	}
+
+	for _, b := range testCases {
+		// Strict
+		om := make(OptionMap)
+		assert.Equal(t, ErrShortPacket, om.Deserialize(b, nil), "options %v", b)
+		assert.Equal(t, ErrShortPacket, om.Deserialize(b, &OptionMapDeserializeOptions{IgnoreMissingEndTag: true}))
+
+		// Lenient
+		om = make(OptionMap)
+		assert.NoError(t, om.Deserialize(b, &OptionMapDeserializeOptions{IgnoreTruncatedOption: true}))
+		assert.Equal(t, OptionMap{OptionHostname: []byte("a")}, om)
+
+		// Packets with truncated options are rejected
+		p := NewPacket(BootRequest)
+		raw := append(append(p.RawPacket[:240:240], byte(OptionDHCPMsgType), 1, byte(MessageTypeDiscover)), b...)
+		_, err := PacketFromBytes(raw)
+		assert.Equal(t, ErrShortPacket, err)
+		_, err = RawPacket(raw).messageType()
+		assert.Equal(t, ErrShortPacket, err)
+	}
+}
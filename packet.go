@@ -1,13 +1,23 @@
 package dhcp4
 
 import (
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"io"
 	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
 )
 
 var (
-	ErrShortPacket   = errors.New("dhcp4: short packet")
-	ErrInvalidPacket = errors.New("dhcp4: invalid packet")
+	ErrShortPacket         = errors.New("dhcp4: short packet")
+	ErrInvalidPacket       = errors.New("dhcp4: invalid packet")
+	ErrInvalidAddress      = errors.New("dhcp4: address is not an IPv4 address")
+	ErrInvalidHardwareAddr = errors.New("dhcp4: hardware address longer than 16 octets")
+	ErrPacketTooLong       = errors.New("dhcp4: options don't fit in the maximum packet length")
 )
 
 type OpCode byte
@@ -31,34 +41,50 @@ type PacketGetter interface {
 	GetGIAddr() net.IP
 }
 
+// PacketSetter defines a bag of functions that can be used to set fields of the
+// fixed part of a packet. The address setters return ErrInvalidAddress if the
+// address is not an IPv4 address.
 type PacketSetter interface {
-	SetCIAddr(ip net.IP)
-	SetYIAddr(ip net.IP)
-	SetSIAddr(ip net.IP)
-	SetGIAddr(ip net.IP)
+	SetCHAddr(hw net.HardwareAddr) error
+	SetCIAddr(ip net.IP) error
+	SetYIAddr(ip net.IP) error
+	SetSIAddr(ip net.IP) error
+	SetGIAddr(ip net.IP) error
 }
 
 type RawPacket []byte
 
-func (p RawPacket) Op() []byte     { return p[0:1] }
-func (p RawPacket) HType() []byte  { return p[1:2] }
-func (p RawPacket) HLen() []byte   { return p[2:3] }
-func (p RawPacket) Hops() []byte   { return p[3:4] }
-func (p RawPacket) XID() []byte    { return p[4:8] }
-func (p RawPacket) Secs() []byte   { return p[8:10] }
-func (p RawPacket) Flags() []byte  { return p[10:12] }
-func (p RawPacket) CIAddr() []byte { return p[12:16] }
-func (p RawPacket) YIAddr() []byte { return p[16:20] }
-func (p RawPacket) SIAddr() []byte { return p[20:24] }
-func (p RawPacket) GIAddr() []byte { return p[24:28] }
-func (p RawPacket) CHAddr() []byte { return p[28:44] }
+// field returns the bytes lo to hi of the packet. If the packet is too short
+// to hold the field, it returns a zeroed slice of the field's length that
+// isn't part of the packet, so that accessors of short packets return zero
+// values instead of panicking, and setters have no effect.
+func (p RawPacket) field(lo, hi int) []byte {
+	if len(p) < hi {
+		return make([]byte, hi-lo)
+	}
+
+	return p[lo:hi]
+}
+
+func (p RawPacket) Op() []byte     { return p.field(0, 1) }
+func (p RawPacket) HType() []byte  { return p.field(1, 2) }
+func (p RawPacket) HLen() []byte   { return p.field(2, 3) }
+func (p RawPacket) Hops() []byte   { return p.field(3, 4) }
+func (p RawPacket) XID() []byte    { return p.field(4, 8) }
+func (p RawPacket) Secs() []byte   { return p.field(8, 10) }
+func (p RawPacket) Flags() []byte  { return p.field(10, 12) }
+func (p RawPacket) CIAddr() []byte { return p.field(12, 16) }
+func (p RawPacket) YIAddr() []byte { return p.field(16, 20) }
+func (p RawPacket) SIAddr() []byte { return p.field(20, 24) }
+func (p RawPacket) GIAddr() []byte { return p.field(24, 28) }
+func (p RawPacket) CHAddr() []byte { return p.field(28, 44) }
 
 // SName returns the `sname` portion of the packet.
 // This field can be used as extra space to extend the DHCP options, if
 // necessary. To enable this, the "Option Overload" option needs to be set in
 // the regular options. Also see RFC2132, section 9.3.
 func (p RawPacket) SName() []byte {
-	return p[44:108]
+	return p.field(44, 108)
 }
 
 // File returns the `file` portion of the packet.
@@ -66,17 +92,22 @@ func (p RawPacket) SName() []byte {
 // necessary. To enable this, the "Option Overload" option needs to be set in
 // the regular options. Also see RFC2132, section 9.3.
 func (p RawPacket) File() []byte {
-	return p[108:236]
+	return p.field(108, 236)
 }
 
 // Cookie returns the fixed-value prefix to the `options` portion of the packet.
 // According to the RFC, this should equal the 4-octet { 99, 130, 83, 99 }.
 func (p RawPacket) Cookie() []byte {
-	return p[236:240]
+	return p.field(236, 240)
 }
 
-// Options returns the variable-sized `options` portion of the packet.
+// Options returns the variable-sized `options` portion of the packet. It is
+// empty if the packet ends after the cookie, or is shorter.
 func (p RawPacket) Options() []byte {
+	if len(p) < 240 {
+		return nil
+	}
+
 	return p[240:]
 }
 
@@ -104,7 +135,14 @@ func (p RawPacket) GetFlags() []byte {
 	return out[:]
 }
 
-// GetCHAddr gets the client's hardware address.
+// Elapsed gets the time elapsed since the client began address acquisition or
+// renewal, from the 'secs' field.
+func (p RawPacket) Elapsed() time.Duration {
+	return time.Duration(binary.BigEndian.Uint16(p.Secs())) * time.Second
+}
+
+// GetCHAddr gets the client's hardware address. It is empty if the packet has
+// a hardware address length of 0.
 func (p RawPacket) GetCHAddr() net.HardwareAddr {
 	var out [16]byte
 
@@ -117,6 +155,48 @@ func (p RawPacket) GetCHAddr() net.HardwareAddr {
 	return net.HardwareAddr(out[0:hlen])
 }
 
+// CorrelationID returns a key identifying the exchange the packet is part of,
+// formatted as the 'xid' in 8 hexadecimal digits, a dash, and the client's
+// hardware address, e.g. "3903f326-00:05:3c:04:8d:59". Replies have the key
+// of the request they reply to, and a client uses the same 'xid' for the
+// DHCPDISCOVER, and the DHCPREQUEST for the address it is offered (RFC2131,
+// section 4.4.1), so the key correlates a complete exchange, such as in the
+// server's log entries (correlation_id=) or as a metrics label. The format is
+// stable.
+func (p RawPacket) CorrelationID() string {
+	return hex.EncodeToString(p.XID()) + "-" + p.GetCHAddr().String()
+}
+
+// SetCHAddr sets the client's hardware address, and the hardware address
+// length. It returns ErrInvalidHardwareAddr if the address is longer than the
+// 16 octets of the `chaddr` field.
+func (p RawPacket) SetCHAddr(hw net.HardwareAddr) error {
+	if len(hw) > 16 {
+		return ErrInvalidHardwareAddr
+	}
+
+	f := p.CHAddr()
+	copy(f, hw)
+	for i := len(hw); i < len(f); i++ {
+		f[i] = 0
+	}
+
+	p.HLen()[0] = byte(len(hw))
+	return nil
+}
+
+// setAddr copies the IPv4 address ip into the 4 octet field f. The 16 octet
+// representation of an IPv4 address (as returned by net.IPv4) is converted.
+func setAddr(f []byte, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return ErrInvalidAddress
+	}
+
+	copy(f, ip4)
+	return nil
+}
+
 // GetCIAddr gets the current IP address of the client.
 func (p RawPacket) GetCIAddr() net.IP {
 	return net.IP(p.CIAddr())
@@ -127,8 +207,8 @@ func (p RawPacket) GetCIAddr() net.IP {
 // From RFC2131 section 3.5:
 // The client fills in the 'ciaddr' field only when correctly configured with
 // an IP address in BOUND, RENEWING or REBINDING state.
-func (p RawPacket) SetCIAddr(ip net.IP) {
-	copy(p.CIAddr(), ip)
+func (p RawPacket) SetCIAddr(ip net.IP) error {
+	return setAddr(p.CIAddr(), ip)
 }
 
 // GetYIAddr gets the IP address offered or assigned to the client.
@@ -141,8 +221,8 @@ func (p RawPacket) GetYIAddr() net.IP {
 // From RFC2131 section 3.1:
 // Each server may respond with a DHCPOFFER message that includes an available
 // network address in the 'yiaddr' field.
-func (p RawPacket) SetYIAddr(ip net.IP) {
-	copy(p.YIAddr(), ip)
+func (p RawPacket) SetYIAddr(ip net.IP) error {
+	return setAddr(p.YIAddr(), ip)
 }
 
 // GetSIAddr gets the IP address of the next server to use in bootstrap.
@@ -155,8 +235,8 @@ func (p RawPacket) GetSIAddr() net.IP {
 // From RFC2131 section 2: DHCP clarifies the interpretation of the 'siaddr'
 // field as the address of the server to use in the next step of the client's
 // bootstrap process. Returned in DHCPOFFER, DHCPACK by server.
-func (p RawPacket) SetSIAddr(ip net.IP) {
-	copy(p.SIAddr(), ip)
+func (p RawPacket) SetSIAddr(ip net.IP) error {
+	return setAddr(p.SIAddr(), ip)
 }
 
 // GetGIAddr gets the IP address of the relay agent.
@@ -168,41 +248,193 @@ func (p RawPacket) GetGIAddr() net.IP {
 //
 // From RFC2131 section 2: Relay agent IP address, used in booting via a relay
 // agent.
-func (p RawPacket) SetGIAddr(ip net.IP) {
-	copy(p.GIAddr(), ip)
+func (p RawPacket) SetGIAddr(ip net.IP) error {
+	return setAddr(p.GIAddr(), ip)
 }
 
 func (p RawPacket) ParseOptions() (OptionMap, error) {
-	var err error
-
 	// Facilitate up to 255 option tags
 	opts := make(OptionMap, 255)
 
+	if err := p.parseOptions(opts); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// parseOptions parses the packet's options into opts. Option values refer to
+// the packet's buffer.
+func (p RawPacket) parseOptions(opts OptionMap) error {
+	var err error
+
 	// Parse initial set of options
 	if err = opts.Deserialize(p.Options(), nil); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Parse options from `file` field if necessary
 	if x := opts[OptionOverload]; len(x) > 0 && x[0]&0x1 != 0 {
 		if err = opts.Deserialize(p.File(), nil); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
 	// Parse options from `sname` field if necessary
 	if x := opts[OptionOverload]; len(x) > 0 && x[0]&0x2 != 0 {
 		if err = opts.Deserialize(p.SName(), nil); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	return opts, nil
+	return nil
+}
+
+// ForEachOption calls fn for every option of the packet, in the order they
+// appear, including the options stored in the `file` and `sname` fields if the
+// packet uses option overloading. Unlike ParseOptions, it doesn't allocate: the
+// value passed to fn refers to the packet's buffer, and an option that appears
+// more than once (RFC3396) is passed once per instance. It stops early if fn
+// returns false. It returns the same errors as ParseOptions for malformed
+// options, after calling fn for the options before the malformed one.
+func (p RawPacket) ForEachOption(fn func(o Option, v []byte) bool) error {
+	var (
+		overload   byte
+		overloaded bool
+	)
+
+	more, err := forEachOption(p.Options(), func(o Option, v []byte) bool {
+		// Repeated options are concatenated when parsing, so the
+		// first octet of the first instance is the one that counts
+		if o == OptionOverload && len(v) > 0 && !overloaded {
+			overload, overloaded = v[0], true
+		}
+		return fn(o, v)
+	})
+	if err != nil || !more {
+		return err
+	}
+
+	if overload&0x1 != 0 {
+		if more, err = forEachOption(p.File(), fn); err != nil || !more {
+			return err
+		}
+	}
+
+	if overload&0x2 != 0 {
+		_, err = forEachOption(p.SName(), fn)
+	}
+
+	return err
+}
+
+// forEachOption calls fn for every option in the wire-level representation b,
+// with the same validation as OptionMap.Deserialize. It returns false if fn
+// stopped the iteration.
+func forEachOption(b []byte, fn func(Option, []byte) bool) (bool, error) {
+	for {
+		if len(b) == 0 {
+			return true, ErrShortPacket
+		}
+
+		tag := Option(b[0])
+		b = b[1:]
+		if tag == OptionEnd {
+			return true, nil
+		}
+
+		// Padding tag
+		if tag == OptionPad {
+			continue
+		}
+
+		if len(b) == 0 || len(b) < 1+int(b[0]) {
+			return true, ErrShortPacket
+		}
+
+		length := int(b[0])
+		if !fn(tag, b[1:1+length]) {
+			return false, nil
+		}
+		b = b[1+length:]
+	}
 }
 
+// messageType returns the message type of the packet, like GetMessageType
+// does for a parsed packet, without parsing the options. It returns an error
+// if PacketFromBytes would fail to parse the packet.
+func (p RawPacket) messageType() (MessageType, error) {
+	if len(p) < 240 {
+		return MessageType(0), ErrShortPacket
+	}
+
+	// The values of repeated options are concatenated when parsing, so the
+	// message type is only valid if it appears once, with a single octet.
+	var (
+		t MessageType
+		n int
+	)
+	err := p.ForEachOption(func(o Option, v []byte) bool {
+		if o == OptionDHCPMsgType {
+			n += len(v)
+			if len(v) > 0 {
+				t = MessageType(v[0])
+			}
+		}
+		return true
+	})
+	if err != nil || n != 1 {
+		return MessageType(0), err
+	}
+
+	return t, nil
+}
+
+// Packet is a DHCP message: its fixed part, and its options. The options are
+// parsed once, when the packet is created by PacketFromBytes or Reset, into an
+// index from option code to value. Typed getters such as GetMessageType are
+// map lookups that don't walk the option bytes again. Setters write the index
+// directly, so it is always current; the raw option bytes are only produced by
+// PacketToBytes.
 type Packet struct {
 	RawPacket
 	OptionMap
+
+	receivedAt time.Time
+	cm         *ipv4.ControlMessage
+}
+
+// ControlMessage returns the IPv4 control message of the packet, if the server
+// read it from a PacketConn that implements ControlMessageReader. It tells,
+// for example, the destination address of the packet, to tell broadcast from
+// unicast requests, and its TTL, which is lower for relayed requests that
+// crossed routers. Fields the platform doesn't provide are zero.
+func (p *Packet) ControlMessage() *ipv4.ControlMessage {
+	return p.cm
+}
+
+// ReceivedAt returns the time the server read the packet off the network, or
+// the zero time if the packet was not read by a Server.
+func (p *Packet) ReceivedAt() time.Time {
+	return p.receivedAt
+}
+
+// ClientID returns the identifier of the client sending the packet. This is
+// the value of the Client Identifier option if set. Otherwise, it is the
+// hardware type followed by the hardware address, in the format of the same
+// option (RFC2132, section 9.14). It returns nil if the packet has neither,
+// e.g. for clients with hlen 0 and no Client Identifier option.
+func (p *Packet) ClientID() []byte {
+	if v, ok := p.GetOption(OptionClientID); ok && len(v) > 0 {
+		return v
+	}
+
+	hw := p.GetCHAddr()
+	if len(hw) == 0 {
+		return nil
+	}
+
+	return append([]byte{p.GetHType()}, hw...)
 }
 
 // NewPacket creates and returns a new packet with the specified OpCode.
@@ -222,9 +454,11 @@ func NewPacket(o OpCode) Packet {
 func NewReply(msg PacketGetter) Packet {
 	rep := NewPacket(BootReply)
 
-	// Hardware type and address length
-	rep.HType()[0] = 1 // Ethernet
-	rep.HLen()[0] = 6  // MAC-48 is 6 octets
+	// Hardware type and address length. These are copied rather than assumed
+	// to be Ethernet, to support clients with other types of links, or with
+	// no hardware address at all (hlen 0).
+	rep.HType()[0] = msg.GetHType()
+	rep.HLen()[0] = msg.GetHLen()
 
 	// Copy transaction identifier
 	copy(rep.XID(), msg.GetXID()[:])
@@ -265,18 +499,240 @@ func PacketFromBytes(b []byte) (Packet, error) {
 	return p, nil
 }
 
+// Reset makes p refer to the packet in b, parsing its options into the
+// existing option map. Unlike PacketFromBytes, the buffer is not copied: the
+// packet and its option values refer to b, and are invalid once b is reused,
+// e.g. by the next read into it. Use Clone for a packet that outlives the
+// buffer.
+func (p *Packet) Reset(b []byte) error {
+	if p.OptionMap == nil {
+		p.OptionMap = make(OptionMap, 255)
+	}
+	for k := range p.OptionMap {
+		delete(p.OptionMap, k)
+	}
+
+	p.RawPacket = nil
+	p.receivedAt = time.Time{}
+	p.cm = nil
+	if len(b) < 240 {
+		return ErrShortPacket
+	}
+
+	if err := RawPacket(b).parseOptions(p.OptionMap); err != nil {
+		return err
+	}
+
+	p.RawPacket = RawPacket(b)
+	return nil
+}
+
+// Clone returns a deep copy of p, that doesn't share its buffer or options.
+func (p *Packet) Clone() Packet {
+	q := Packet{
+		RawPacket: append(RawPacket(nil), p.RawPacket...),
+		OptionMap: make(OptionMap, len(p.OptionMap)),
+
+		receivedAt: p.receivedAt,
+		cm:         p.cm,
+	}
+
+	for k, v := range p.OptionMap {
+		q.OptionMap[k] = append([]byte(nil), v...)
+	}
+
+	return q
+}
+
+// RawBytes returns a copy of the bytes p was parsed from by PacketFromBytes
+// or Reset, e.g. to forward the packet verbatim, or to verify a signature over
+// its wire form; serializing the packet again is not necessarily
+// byte-identical. Each call copies the whole packet, as the bytes refer to a
+// buffer that is reused after Reset (see Clone, which copies them once).
+// Setters of fixed fields, such as SetGIAddr, modify the bytes, while setting
+// options doesn't. For a packet created by NewPacket, it returns the fixed
+// part.
+func (p *Packet) RawBytes() []byte {
+	return append([]byte(nil), p.RawPacket...)
+}
+
 type packetToBytesOptions struct {
 	maxLen    uint16
 	skipFile  bool
 	skipSName bool
+
+	// Fail with ErrPacketTooLong instead of dropping options that don't fit
+	strict bool
+
+	// Options requested by the client, in the order of its Parameter Request
+	// List, if any
+	parameterList []byte
+}
+
+// controlOptions are the options written after the requested options of a
+// reply, in this order.
+var controlOptions = []Option{
+	OptionDHCPMsgType,
+	OptionDHCPServerID,
+	OptionAddressTime,
+}
+
+// orderOptions appends the options of om to ks in the order they are written
+// to a reply: first the options requested in parameterList, in the client's
+// order, then the control options, then the remaining options in numeric
+// order. Some clients depend on getting the options in the order they
+// requested them.
+func orderOptions(ks []Option, om OptionMap, parameterList []byte) []Option {
+	var seen [256]bool
+
+	add := func(k Option) {
+		if _, ok := om[k]; ok && !seen[k] {
+			ks = append(ks, k)
+			seen[k] = true
+		}
+	}
+
+	var control [256]bool
+	for _, k := range controlOptions {
+		control[k] = true
+	}
+
+	for _, k := range parameterList {
+		if !control[k] {
+			add(Option(k))
+		}
+	}
+
+	for _, k := range controlOptions {
+		add(k)
+	}
+
+	rest := len(ks)
+	for k := range om {
+		if !seen[k] {
+			ks = append(ks, k)
+		}
+	}
+
+	sortOptions(ks[rest:])
+	return ks
+}
+
+// sortOptions sorts ks in numeric order, without allocating like sort.Sort.
+func sortOptions(ks []Option) {
+	for i := 1; i < len(ks); i++ {
+		for j := i; j > 0 && ks[j] < ks[j-1]; j-- {
+			ks[j], ks[j-1] = ks[j-1], ks[j]
+		}
+	}
+}
+
+// marshalScratch holds the buffers options are laid out in when serializing
+// a packet. They are pooled, so that serializing into a caller-provided buffer
+// doesn't allocate.
+type marshalScratch struct {
+	fields [3][]byte
+	keys   []Option
+	dst    []int
+}
+
+var marshalScratches = sync.Pool{
+	New: func() interface{} {
+		return &marshalScratch{
+			fields: [3][]byte{
+				make([]byte, 0, 65535-240),
+				make([]byte, 0, 236-108),
+				make([]byte, 0, 108-44),
+			},
+			keys: make([]Option, 0, 256),
+		}
+	},
 }
 
 // PacketToBytes serializes the DHCP packet pointed to by p into its wire-level
 // representation. The function may return an error if it cannot successfully
 // serialize the packet. Otherwise, it returns a newly created byte slice.
 func PacketToBytes(p Packet, opts *packetToBytesOptions) ([]byte, error) {
+	s := marshalScratches.Get().(*marshalScratch)
+	defer marshalScratches.Put(s)
+
+	b, err := packetOptionFields(p, opts, s)
+	if err != nil {
+		return nil, err
+	}
+
+	o := make([]byte, packetLen(b))
+	writePacket(o, p, b)
+	return o, nil
+}
+
+// PacketToBytesMax serializes p like PacketToBytes, into at most maxLen bytes
+// (at least 576), e.g. 65535 for transports that are not limited by the MTU,
+// like bulk leasequery over TCP. Unlike PacketToBytes, which drops the options
+// that don't fit, it returns ErrPacketTooLong.
+func PacketToBytesMax(p Packet, maxLen uint16) ([]byte, error) {
+	return PacketToBytes(p, &packetToBytesOptions{maxLen: maxLen, strict: true})
+}
+
+// MarshalTo serializes the packet into buf, like PacketToBytes, and returns
+// the number of bytes written. It returns io.ErrShortBuffer if buf is too
+// small for the packet; a buffer of 1500 bytes, the default maximum message
+// size, is always large enough.
+func (p *Packet) MarshalTo(buf []byte) (int, error) {
+	return packetMarshalTo(buf, *p, nil)
+}
+
+func packetMarshalTo(buf []byte, p Packet, opts *packetToBytesOptions) (int, error) {
+	s := marshalScratches.Get().(*marshalScratch)
+	defer marshalScratches.Put(s)
+
+	b, err := packetOptionFields(p, opts, s)
+	if err != nil {
+		return 0, err
+	}
+
+	n := packetLen(b)
+	if len(buf) < n {
+		return 0, io.ErrShortBuffer
+	}
+
+	writePacket(buf[:n], p, b)
+	return n, nil
+}
+
+// optionChunks returns the number of chunks of at most 255 bytes an option
+// value of n bytes is split in (see splitOption).
+func optionChunks(n int) int {
+	if n == 0 {
+		return 1
+	}
+
+	return (n + 254) / 255
+}
+
+// optionChunk returns the j-th chunk of option value v (see splitOption).
+func optionChunk(v []byte, j int) []byte {
+	end := (j + 1) * 255
+	if end > len(v) {
+		end = len(v)
+	}
+
+	return v[j*255 : end]
+}
+
+// packetOptionFields lays out the options of p in the options, file and
+// sname fields, each terminated by OptionEnd if used. The fields are buffers
+// of s, valid until s is reused.
+func packetOptionFields(p Packet, opts *packetToBytesOptions, s *marshalScratch) ([3][]byte, error) {
+	var b [3][]byte
+
 	if len(p.RawPacket) < 240 {
-		return nil, ErrInvalidPacket
+		return b, ErrInvalidPacket
+	}
+
+	// The hardware address length can't extend past the `chaddr` field
+	if p.GetHLen() > 16 {
+		return b, ErrInvalidHardwareAddr
 	}
 
 	// Maximum byte length of serialized packet (default is Ethernet MTU).
@@ -288,57 +744,92 @@ func PacketToBytes(p Packet, opts *packetToBytesOptions) ([]byte, error) {
 	}
 
 	// Buffers we can stash options in
-	var b [3][]byte
-
 	// Variable length options field (starting at byte 240)
-	b[0] = make([]byte, 0, maxLen-240)
+	b[0] = s.fields[0][: 0 : maxLen-240]
 
 	// Fixed length "file" field (from byte 108 to byte 236)
 	if opts == nil || !opts.skipFile {
-		b[1] = make([]byte, 0, 236-108)
+		b[1] = s.fields[1][:0]
 	}
 
 	// Fixed length "sname" field (from byte 44 to byte 108)
 	if opts == nil || !opts.skipSName {
-		b[2] = make([]byte, 0, 108-44)
+		b[2] = s.fields[2][:0]
 	}
 
 	// Write options to one of the buffers.
-	// Iterate over options in numeric order.
-	for _, k := range p.GetSortedOptions() {
-		v := p.OptionMap[k]
-		l := 2 + len(v)
+	// Iterate over options in numeric order, or in the order requested by the
+	// client. Options that come first get the available room first.
+	keys := s.keys[:0]
+	if opts != nil && opts.parameterList != nil {
+		keys = orderOptions(keys, p.OptionMap, opts.parameterList)
+	} else {
+		for k := range p.OptionMap {
+			keys = append(keys, k)
+		}
+		sortOptions(keys)
+	}
 
-		// TODO(PN): Deal with DHCP options of length > 255
-		// https://www.pivotaltracker.com/story/show/68123382
-		if len(v) > 255 {
-			continue
+	for _, k := range keys {
+		v := p.OptionMap[k]
+		n := optionChunks(len(v))
+
+		// Find a buffer for every chunk. The receiver concatenates the chunks
+		// in the order of the options field, the file field and the sname
+		// field (RFC3396, section 7), so chunks can't go to an earlier buffer
+		// than the chunk before them. The option is skipped if not all chunks
+		// have room.
+		var used [3]int
+		if cap(s.dst) < n {
+			s.dst = make([]int, n)
 		}
+		dst := s.dst[:n]
+		i := 0
 
-		for i := range b {
-			cb := cap(b[i])
-			lb := len(b[i])
-			f := cb - lb
+		for j := 0; j < n; j++ {
+			l := 2 + len(optionChunk(v, j))
+
+			for ; i < len(b); i++ {
+				f := cap(b[i]) - len(b[i]) - used[i]
+
+				// The first buffer needs to have at least 3 bytes extra for OptionOverload
+				if i == 0 {
+					f -= 3
+				}
 
-			// The first buffer needs to have at least 3 bytes extra for OptionOverload
-			if i == 0 {
-				f -= 3
+				// Every buffer needs to have at least 1 byte extra for OptionEnd
+				f--
+
+				// Check that this buffer has room for this option
+				if f >= l {
+					break
+				}
 			}
 
-			// Every buffer needs to have at least 1 byte extra for OptionEnd
-			f--
+			if i == len(b) {
+				break
+			}
 
-			// Check that this buffer has room for this option
-			if f < l {
-				continue
+			dst[j] = i
+			used[i] += l
+		}
+
+		if i == len(b) {
+			if opts != nil && opts.strict {
+				return b, ErrPacketTooLong
 			}
+			continue
+		}
 
-			// Write option to buffer
-			b[i] = b[i][:lb+l]
+		// Write option to buffers
+		for j := 0; j < n; j++ {
+			c := optionChunk(v, j)
+			i := dst[j]
+			lb := len(b[i])
+			b[i] = b[i][:lb+2+len(c)]
 			b[i][lb+0] = byte(k)
-			b[i][lb+1] = byte(len(v))
-			copy(b[i][lb+2:], v)
-			break
+			b[i][lb+1] = byte(len(c))
+			copy(b[i][lb+2:], c)
 		}
 	}
 
@@ -351,17 +842,34 @@ func PacketToBytes(p Packet, opts *packetToBytesOptions) ([]byte, error) {
 		}
 	}
 
-	// Capacity: base packet, optional OptionOverload option, and options field
-	oc := 240 + 3 + len(b[0])
-	ol := 0
-	o := make([]byte, ol, oc)
+	return b, nil
+}
+
+// overloaded returns whether options are stored in the file or sname field.
+func overloaded(b [3][]byte) bool {
+	return len(b[1]) > 0 || len(b[2]) > 0
+}
 
+// packetLen returns the length of a packet with option fields b: the base
+// packet, the optional OptionOverload option, and the options field.
+func packetLen(b [3][]byte) int {
+	n := 240 + len(b[0])
+	if overloaded(b) {
+		n += 3
+	}
+
+	return n
+}
+
+// writePacket writes the packet p with option fields b into o, which has
+// length packetLen(b).
+func writePacket(o []byte, p Packet, b [3][]byte) {
 	// Copy base packet
 	copy(o[0:240], p.RawPacket[0:240])
-	ol = 240
+	ol := 240
 
 	// Copy options overloaded into the SName and File sections
-	if len(b[1]) > 0 || len(b[2]) > 0 {
+	if overloaded(b) {
 		overload := 0x0
 
 		// File section
@@ -377,7 +885,6 @@ func PacketToBytes(p Packet, opts *packetToBytesOptions) ([]byte, error) {
 		}
 
 		// Add OptionOverload
-		o = o[:ol+3]
 		o[ol+0] = byte(OptionOverload)
 		o[ol+1] = byte(1)
 		o[ol+2] = byte(overload)
@@ -385,8 +892,5 @@ func PacketToBytes(p Packet, opts *packetToBytesOptions) ([]byte, error) {
 	}
 
 	// Add options
-	o = o[:ol+len(b[0])]
-	copy(o[ol:ol+len(b[0])], b[0])
-
-	return o, nil
+	copy(o[ol:], b[0])
 }
@@ -1,7 +1,11 @@
 package dhcp4
 
 import (
+	"bytes"
+	"io"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -253,3 +257,470 @@ func TestPacketToBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestPacketToBytesSplitsLongOptions(t *testing.T) {
+	v := make([]byte, 600)
+	for i := range v {
+		v[i] = byte(i)
+	}
+
+	p := NewPacket(BootReply)
+	p.SetOption(OptionVendorSpecific, v)
+
+	b, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The value is split into three options, that concatenate to the value
+	var chunks [][]byte
+	walkOptions(RawPacket(b).Options(), func(o Option, c []byte) {
+		if o == OptionVendorSpecific {
+			chunks = append(chunks, c)
+		}
+	})
+
+	if assert.Len(t, chunks, 3) {
+		assert.Len(t, chunks[0], 255)
+		assert.Len(t, chunks[1], 255)
+		assert.Len(t, chunks[2], 90)
+		assert.Equal(t, v, bytes.Join(chunks, nil))
+	}
+
+	// Parsing the packet concatenates the options
+	q, err := PacketFromBytes(b)
+	if assert.NoError(t, err) {
+		assertOption(t, q.OptionMap, OptionVendorSpecific, v)
+	}
+}
+
+func TestPacketFromBytesConcatenatesOverloadedOptions(t *testing.T) {
+	// Fabricate packet with an option split over the options and `file` fields
+	p := new(testPacket)
+	p.appendToOption(OptionOverload, []byte{0x1})
+	p.appendToOption(OptionDomainSearch, []byte("foo"))
+	p.appendToOption(OptionEnd, nil)
+	p.appendToFile(OptionDomainSearch, []byte("bar"))
+	p.appendToFile(OptionEnd, nil)
+
+	if pckt, err := fromBytes(t, p.buf); assert.Nil(t, err) {
+		assertOption(t, pckt.OptionMap, OptionDomainSearch, []byte("foobar"))
+
+		// Concatenation doesn't clobber the raw packet
+		assert.Equal(t, []byte("foo"), pckt.Options()[5:8])
+	}
+}
+
+func TestPacketClientID(t *testing.T) {
+	mac := []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	// Ethernet client without Client Identifier option
+	p := NewPacket(BootRequest)
+	p.HType()[0] = 1
+	p.HLen()[0] = 6
+	copy(p.CHAddr(), mac)
+	assert.Equal(t, append([]byte{1}, mac...), p.ClientID())
+
+	// Client Identifier option takes precedence
+	p.SetOption(OptionClientID, []byte{0, 'f', 'o', 'o'})
+	assert.Equal(t, []byte{0, 'f', 'o', 'o'}, p.ClientID())
+
+	// Client without hardware address or Client Identifier option
+	p = NewPacket(BootRequest)
+	assert.Nil(t, p.ClientID())
+}
+
+func TestPacketWithoutHardwareAddress(t *testing.T) {
+	clientID := []byte{0xff, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+
+	// InfiniBand client (RFC4390): htype 32, hlen 0 and an empty chaddr
+	p := new(testPacket)
+	p.appendToOption(OptionClientID, clientID)
+	p.appendToOption(OptionEnd, nil)
+	p.buf[1] = 32
+
+	req, err := fromBytes(t, p.buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, uint8(32), req.GetHType())
+	assert.Len(t, req.GetCHAddr(), 0)
+	assert.Equal(t, clientID, req.ClientID())
+
+	// The reply copies the hardware type and address length
+	rep := NewReply(req)
+	assert.Equal(t, uint8(32), rep.GetHType())
+	assert.Equal(t, uint8(0), rep.GetHLen())
+	assert.Len(t, rep.GetCHAddr(), 0)
+}
+
+func TestPacketSetAddresses(t *testing.T) {
+	p := NewPacket(BootReply)
+
+	// The 16 octet representation of IPv4 addresses is converted
+	assert.NoError(t, p.SetYIAddr(net.IPv4(10, 0, 0, 5)))
+	assert.Equal(t, []byte{10, 0, 0, 5}, p.YIAddr())
+
+	for _, ip := range []net.IP{nil, net.IP{1, 2, 3}, net.ParseIP("2001:db8::1")} {
+		assert.Equal(t, ErrInvalidAddress, p.SetCIAddr(ip))
+		assert.Equal(t, ErrInvalidAddress, p.SetYIAddr(ip))
+		assert.Equal(t, ErrInvalidAddress, p.SetSIAddr(ip))
+		assert.Equal(t, ErrInvalidAddress, p.SetGIAddr(ip))
+	}
+
+	// Failed calls leave the field alone
+	assert.Equal(t, []byte{10, 0, 0, 5}, p.YIAddr())
+}
+
+func TestPacketSetCHAddr(t *testing.T) {
+	p := NewPacket(BootReply)
+
+	hw := net.HardwareAddr{1, 2, 3, 4, 5, 6}
+	assert.NoError(t, p.SetCHAddr(hw))
+	assert.Equal(t, hw, p.GetCHAddr())
+	assert.Equal(t, uint8(6), p.GetHLen())
+
+	// A shorter address clears the remainder of the field
+	assert.NoError(t, p.SetCHAddr(net.HardwareAddr{7, 8}))
+	assert.Equal(t, make([]byte, 14), p.CHAddr()[2:])
+
+	assert.Equal(t, ErrInvalidHardwareAddr, p.SetCHAddr(make(net.HardwareAddr, 17)))
+	assert.Equal(t, uint8(2), p.GetHLen())
+}
+
+func TestPacketCHAddrRoundTrip(t *testing.T) {
+	hw := net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x01}
+
+	p := NewPacket(BootRequest)
+	assert.NoError(t, p.SetCHAddr(hw))
+
+	b, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []byte(hw), b[28:34])
+	assert.Equal(t, make([]byte, 10), b[34:44], "padding")
+
+	q, err := PacketFromBytes(b)
+	if assert.NoError(t, err) {
+		assert.Equal(t, hw, q.GetCHAddr())
+	}
+
+	// Octets beyond 'hlen' are ignored, also in the client identifier
+	b[43] = 0xff
+	b[36] = 0x01
+	q, err = PacketFromBytes(b)
+	if assert.NoError(t, err) {
+		assert.Equal(t, hw, q.GetCHAddr())
+		assert.Equal(t, append([]byte{0}, hw...), q.ClientID())
+
+		// Replies are padded with zeroes
+		rep := NewReply(&q)
+		assert.Equal(t, append([]byte(hw), make([]byte, 10)...), []byte(rep.CHAddr()))
+	}
+}
+
+func TestPacketToBytesRejectsLongHardwareAddress(t *testing.T) {
+	p := NewPacket(BootReply)
+	p.HLen()[0] = 17
+
+	_, err := PacketToBytes(p, nil)
+	assert.Equal(t, ErrInvalidHardwareAddr, err)
+
+	// Through the reply types, too
+	req := NewPacket(BootRequest)
+	req.HLen()[0] = 20
+	offer := CreateOffer(&req)
+
+	_, err = offer.ToBytes()
+	assert.Equal(t, ErrInvalidHardwareAddr, err)
+}
+
+func TestPacketElapsed(t *testing.T) {
+	p := NewPacket(BootRequest)
+	assert.Equal(t, time.Duration(0), p.Elapsed())
+
+	// Network byte order
+	copy(p.Secs(), []byte{0x01, 0x02})
+	assert.Equal(t, 258*time.Second, p.Elapsed())
+}
+
+func TestPacketReset(t *testing.T) {
+	a := NewPacket(BootRequest)
+	a.SetMessageType(MessageTypeDiscover)
+	a.SetString(OptionHostname, "a")
+	ab, _ := PacketToBytes(a, nil)
+
+	b := NewPacket(BootRequest)
+	b.SetMessageType(MessageTypeRequest)
+	bb, _ := PacketToBytes(b, nil)
+
+	var p Packet
+	buf := make([]byte, 1500)
+
+	n := copy(buf, ab)
+	if !assert.NoError(t, p.Reset(buf[:n])) {
+		return
+	}
+	assert.Equal(t, MessageTypeDiscover, p.GetMessageType())
+	assert.Equal(t, BootRequest, OpCode(p.Op()[0]))
+
+	// Keep a copy before the buffer is reused
+	c := p.Clone()
+
+	n = copy(buf, bb)
+	if !assert.NoError(t, p.Reset(buf[:n])) {
+		return
+	}
+	assert.Equal(t, MessageTypeRequest, p.GetMessageType())
+	_, ok := p.GetString(OptionHostname)
+	assert.False(t, ok, "options of the previous packet are cleared")
+
+	assert.Equal(t, MessageTypeDiscover, c.GetMessageType())
+	hostname, _ := c.GetString(OptionHostname)
+	assert.Equal(t, "a", hostname)
+
+	assert.Equal(t, ErrShortPacket, p.Reset(buf[:100]))
+	assert.Nil(t, p.RawPacket)
+	assert.Empty(t, p.OptionMap)
+}
+
+func TestPacketRawBytes(t *testing.T) {
+	a := NewPacket(BootRequest)
+	a.SetMessageType(MessageTypeDiscover)
+	ab, _ := PacketToBytes(a, nil)
+
+	var p Packet
+	buf := append([]byte(nil), ab...)
+	if !assert.NoError(t, p.Reset(buf)) {
+		return
+	}
+
+	raw := p.RawBytes()
+	c := p.Clone()
+	assert.Equal(t, ab, raw)
+
+	// Copies survive reuse of the buffer
+	buf[0] = byte(BootReply)
+	assert.Equal(t, ab, raw)
+	assert.Equal(t, ab, c.RawBytes())
+
+	// Options are not reflected
+	c.SetString(OptionHostname, "a")
+	assert.Equal(t, ab, c.RawBytes())
+}
+
+func BenchmarkPacketGetters(b *testing.B) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeRequest)
+	p.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	p.SetOption(OptionParameterList, []byte{1, 3, 6, 15, 51})
+	buf, _ := PacketToBytes(p, nil)
+
+	q, _ := PacketFromBytes(buf)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.GetMessageType()
+		q.GetIP(OptionDHCPServerID)
+		q.GetOption(OptionParameterList)
+	}
+}
+
+func TestPacketMarshalTo(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeDiscover)
+	p.SetString(OptionHostname, "foo")
+
+	expected, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf := make([]byte, 1500)
+	n, err := p.MarshalTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf[:n])
+
+	_, err = p.MarshalTo(buf[:len(expected)-1])
+	assert.Equal(t, io.ErrShortBuffer, err)
+}
+
+func TestPacketToBytesMax(t *testing.T) {
+	p := NewPacket(BootReply)
+	p.SetMessageType(MessageTypeAck)
+	p.SetOption(OptionClientID, make([]byte, 2000))
+
+	// Dropped with the default maximum
+	b, err := PacketToBytes(p, nil)
+	if assert.NoError(t, err) {
+		q, err := PacketFromBytes(b)
+		assert.NoError(t, err)
+		_, ok := q.GetOption(OptionClientID)
+		assert.False(t, ok)
+	}
+
+	b, err = PacketToBytesMax(p, 0xffff)
+	if assert.NoError(t, err) {
+		q, err := PacketFromBytes(b)
+		assert.NoError(t, err)
+		v, _ := q.GetOption(OptionClientID)
+		assert.Len(t, v, 2000)
+	}
+
+	_, err = PacketToBytesMax(p, 1500)
+	assert.Equal(t, ErrPacketTooLong, err)
+}
+
+func TestPacketMarshalToAllocs(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeDiscover)
+	p.SetString(OptionHostname, "foo")
+	p.SetOption(OptionVendorSpecific, make([]byte, 300))
+
+	if raceEnabled {
+		t.Skip("sync.Pool drops buffers with the race detector")
+	}
+
+	buf := make([]byte, 1500)
+	allocs := testing.AllocsPerRun(100, func() {
+		p.MarshalTo(buf)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestRawPacketShort(t *testing.T) {
+	for _, n := range []int{0, 1, 20, 44, 100, 239} {
+		p := RawPacket(make([]byte, n))
+
+		assert.NotPanics(t, func() {
+			assert.Equal(t, uint8(0), p.GetHType())
+			assert.Equal(t, uint8(0), p.GetHLen())
+			assert.Equal(t, []byte{0, 0, 0, 0}, p.GetXID())
+			assert.Equal(t, []byte{0, 0}, p.GetFlags())
+			assert.Empty(t, p.GetCHAddr())
+			assert.True(t, p.GetCIAddr().Equal(net.IPv4zero))
+			assert.True(t, p.GetGIAddr().Equal(net.IPv4zero))
+			assert.Len(t, p.SName(), 64)
+			assert.Len(t, p.File(), 128)
+			assert.Len(t, p.Cookie(), 4)
+			assert.Empty(t, p.Options())
+			assert.NoError(t, p.SetYIAddr(net.IPv4(10, 0, 0, 1)))
+		}, "length %d", n)
+	}
+}
+
+func TestPacketFromBytesEndingAfterCookie(t *testing.T) {
+	p := NewPacket(BootRequest)
+	b := []byte(p.RawPacket[:240])
+
+	assert.NotPanics(t, func() {
+		_, err := PacketFromBytes(b)
+		assert.Equal(t, ErrShortPacket, err)
+	})
+
+	q := RawPacket(b)
+	assert.Len(t, q.SName(), 64)
+	assert.Len(t, q.File(), 128)
+	assert.Empty(t, q.Options())
+}
+
+func TestRawPacketForEachOption(t *testing.T) {
+	p := new(testPacket)
+	p.appendToOption(OptionOverload, []byte{0x3})
+	p.appendToOption(OptionDomainSearch, []byte("foo"))
+	p.appendToOption(OptionEnd, nil)
+	p.appendToFile(OptionDomainSearch, []byte("bar"))
+	p.appendToFile(OptionEnd, nil)
+	p.appendToSName(OptionDHCPMsgType, []byte{byte(MessageTypeRequest)})
+	p.appendToSName(OptionEnd, nil)
+
+	type option struct {
+		o Option
+		v string
+	}
+
+	var opts []option
+	raw := RawPacket(p.buf)
+	err := raw.ForEachOption(func(o Option, v []byte) bool {
+		opts = append(opts, option{o, string(v)})
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []option{
+		{OptionOverload, "\x03"},
+		{OptionDomainSearch, "foo"},
+		{OptionDomainSearch, "bar"},
+		{OptionDHCPMsgType, "\x03"},
+	}, opts)
+
+	// Stops early
+	opts = nil
+	err = raw.ForEachOption(func(o Option, v []byte) bool {
+		opts = append(opts, option{o, string(v)})
+		return o != OptionDomainSearch
+	})
+	assert.NoError(t, err)
+	assert.Len(t, opts, 2)
+
+	// Same errors as ParseOptions
+	for i := 240; i < len(p.buf)-1; i++ {
+		_, expected := RawPacket(p.buf[:i]).ParseOptions()
+		err := RawPacket(p.buf[:i]).ForEachOption(func(Option, []byte) bool { return true })
+		assert.Equal(t, expected, err, "length %d", i)
+	}
+}
+
+func TestRawPacketMessageType(t *testing.T) {
+	p := new(testPacket)
+	p.appendToOption(OptionOverload, []byte{0x1})
+	p.appendToOption(OptionEnd, nil)
+	p.appendToFile(OptionDHCPMsgType, []byte{byte(MessageTypeInform)})
+	p.appendToFile(OptionEnd, nil)
+
+	typ, err := RawPacket(p.buf).messageType()
+	assert.NoError(t, err)
+	assert.Equal(t, MessageTypeInform, typ)
+
+	// Repeated, the option is too long to be valid
+	p = new(testPacket)
+	p.appendToOption(OptionDHCPMsgType, []byte{byte(MessageTypeInform)})
+	p.appendToOption(OptionDHCPMsgType, []byte{byte(MessageTypeInform)})
+	p.appendToOption(OptionEnd, nil)
+
+	typ, err = RawPacket(p.buf).messageType()
+	assert.NoError(t, err)
+	assert.Equal(t, MessageType(0), typ)
+
+	_, err = RawPacket(p.buf[:239]).messageType()
+	assert.Equal(t, ErrShortPacket, err)
+}
+
+func TestRawPacketMessageTypeAllocs(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeDiscover)
+	p.SetOption(OptionParameterList, []byte{1, 3, 6, 15, 51})
+	buf, _ := PacketToBytes(p, nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		RawPacket(buf).messageType()
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestPacketCorrelationID(t *testing.T) {
+	p := NewPacket(BootRequest)
+	copy(p.XID(), []byte{0x39, 0x03, 0xf3, 0x26})
+	p.HType()[0] = 1
+	p.SetCHAddr(net.HardwareAddr{0x00, 0x05, 0x3c, 0x04, 0x8d, 0x59})
+	assert.Equal(t, "3903f326-00:05:3c:04:8d:59", p.CorrelationID())
+
+	// Replies share the key of the request
+	rep := NewReply(&p)
+	assert.Equal(t, p.CorrelationID(), rep.CorrelationID())
+
+	p.SetCHAddr(nil)
+	assert.Equal(t, "3903f326-", p.CorrelationID())
+}
@@ -0,0 +1,54 @@
+package dhcp4
+
+import (
+	"net"
+	"time"
+)
+
+// Offer holds an address for the client with identifier clientID for ttl,
+// waiting for the client's DHCPREQUEST, which binds it with AllocateIP. The
+// address is chosen like Allocate does. A client that already has an offered
+// or bound address, which has not expired, is offered the same address again,
+// without changing its expiry. Clients sending DHCPDISCOVER after DHCPDISCOVER,
+// e.g. while booting, thus hold a single address, instead of a new one per
+// DHCPDISCOVER, and a DHCPDISCOVER doesn't shorten the lease of a bound
+// client. Offers that are not accepted within ttl expire like leases; the
+// address is then free again, and freed by the sweeper (see StartSweeper).
+func (p *LeasePool) Offer(clientID []byte, requested net.IP, ttl time.Duration) (Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock().Now()
+	if v, ok := p.byClient[string(clientID)]; ok {
+		if l := p.leases[v]; !now.After(l.Expiry) && p.isFree(v, string(clientID), now) {
+			return *l, nil
+		}
+	}
+
+	l, err := p.allocate(clientID, requested, now, ttl)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	v, _ := ipToUint32(l.IP)
+	if p.offered == nil {
+		p.offered = make(map[uint32]bool)
+	}
+	p.offered[v] = true
+
+	return l, nil
+}
+
+// IsOffered returns whether address ip is offered to a client, and not bound
+// yet (see Offer). The offer may have expired.
+func (p *LeasePool) IsOffered(ip net.IP) bool {
+	v, ok := ipToUint32(ip)
+	if !ok {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.offered[v]
+}
@@ -0,0 +1,56 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasePoolOffer(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/29")
+
+	// Repeated offers hold one address
+	l, err := p.Offer([]byte("a"), nil, time.Minute)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		l2, err := p.Offer([]byte("a"), nil, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, l, l2)
+	}
+	assert.True(t, p.IsOffered(l.IP))
+
+	// Bound by the request
+	bound, err := p.AllocateIP([]byte("a"), l.IP, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, p.IsOffered(l.IP))
+
+	// Offering again doesn't shorten the lease
+	l2, err := p.Offer([]byte("a"), net.IPv4(10, 0, 0, 5), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, bound, l2)
+	assert.False(t, p.IsOffered(l.IP))
+}
+
+func TestLeasePoolOfferExpired(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+	assert.NoError(t, p.StartSweeper(time.Hour, 0, nil))
+	defer p.Close()
+
+	l, err := p.Offer([]byte("a"), nil, -time.Second)
+	assert.NoError(t, err)
+
+	// The expired offer is renewed
+	l2, err := p.Offer([]byte("a"), nil, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, l.IP, l2.IP)
+	assert.True(t, l2.Expiry.After(l.Expiry))
+
+	// Unaccepted offers are reclaimed
+	expired := p.Sweep(time.Now().Add(time.Hour))
+	assert.Len(t, expired, 1)
+	assert.False(t, p.IsOffered(l.IP))
+	_, ok := p.Lookup([]byte("a"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,118 @@
+package dhcp4
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeQueueSize is the number of packets a pipe end buffers for reading.
+// Packets written while the buffer is full are dropped, as on a network.
+const pipeQueueSize = 64
+
+type pipePacket struct {
+	b       []byte
+	addr    net.Addr
+	ifindex int
+}
+
+type pipeConn struct {
+	local net.Addr
+	in    chan pipePacket
+	peer  *pipeConn
+
+	mu       sync.Mutex
+	deadline time.Time
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Pipe returns the two ends of an in-memory datagram connection, for testing
+// clients and servers without sockets. A packet written to one end is read
+// from the other, with the local address of the writing end and the interface
+// index passed to WriteTo. The destination address is ignored. The first end
+// has local address 0.0.0.0:68, like a client without address, and the second
+// has 0.0.0.0:67, like a server. Both ends implement ClientConn, so they can
+// be used with Server.Serve and Client.
+func Pipe() (ClientConn, ClientConn) {
+	a := newPipeConn(&net.UDPAddr{IP: net.IPv4zero, Port: ClientPort})
+	b := newPipeConn(&net.UDPAddr{IP: net.IPv4zero, Port: ServerPort})
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func newPipeConn(local net.Addr) *pipeConn {
+	return &pipeConn{
+		local: local,
+		in:    make(chan pipePacket, pipeQueueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// ReadFrom implements PacketReader.
+func (c *pipeConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case p := <-c.in:
+		return copy(b, p.b), p.addr, p.ifindex, nil
+	case <-timeout:
+		return 0, nil, -1, os.ErrDeadlineExceeded
+	case <-c.done:
+		return 0, nil, -1, net.ErrClosed
+	}
+}
+
+// WriteTo implements PacketWriter.
+func (c *pipeConn) WriteTo(b []byte, addr net.Addr, ifindex int) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	p := pipePacket{
+		b:       append([]byte(nil), b...),
+		addr:    c.local,
+		ifindex: ifindex,
+	}
+
+	select {
+	case c.peer.in <- p:
+	case <-c.peer.done:
+	default:
+	}
+
+	return len(b), nil
+}
+
+// SetReadDeadline sets the deadline for future ReadFrom calls.
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+// Close closes the end of the pipe. Pending and future reads return
+// net.ErrClosed; packets written by the other end are dropped.
+func (c *pipeConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// LocalAddr returns the local address of the end of the pipe.
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.local
+}
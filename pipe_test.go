@@ -0,0 +1,85 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipe(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	_, err := a.WriteTo([]byte("xyz"), &net.UDPAddr{IP: net.IPv4bcast, Port: 67}, 3)
+	assert.NoError(t, err)
+
+	buf := make([]byte, 16)
+	n, addr, ifindex, err := b.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz", string(buf[:n]))
+	assert.Equal(t, a.LocalAddr(), addr)
+	assert.Equal(t, 3, ifindex)
+
+	// Deadline
+	b.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, _, _, err = b.ReadFrom(buf)
+	if ne, ok := err.(net.Error); assert.True(t, ok) {
+		assert.True(t, ne.Timeout())
+	}
+
+	// Close
+	b.SetReadDeadline(time.Time{})
+	b.Close()
+	_, _, _, err = b.ReadFrom(buf)
+	assert.Equal(t, net.ErrClosed, err)
+	_, err = a.WriteTo([]byte("xyz"), nil, 0)
+	assert.NoError(t, err, "writes to a closed peer are dropped")
+}
+
+func TestPipeDORA(t *testing.T) {
+	cc, sc := Pipe()
+	defer cc.Close()
+
+	s, err := NewSimpleServer("10.0.0.0/24", net.IPv4(10, 0, 0, 1), []net.IP{net.IPv4(10, 0, 0, 2)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Serve(sc) }()
+
+	c := Client{Conn: cc, Timeout: time.Second}
+	hw := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	discover := NewPacket(BootRequest)
+	discover.SetMessageType(MessageTypeDiscover)
+	discover.SetCHAddr(hw)
+	copy(discover.XID(), []byte{1, 2, 3, 4})
+
+	offer, err := c.Exchange(&discover)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, MessageTypeOffer, offer.GetMessageType())
+
+	request := NewPacket(BootRequest)
+	request.SetMessageType(MessageTypeRequest)
+	request.SetCHAddr(hw)
+	copy(request.XID(), []byte{1, 2, 3, 5})
+	request.SetIP(OptionAddressRequest, offer.GetYIAddr())
+	sid, _ := offer.GetIP(OptionDHCPServerID)
+	request.SetIP(OptionDHCPServerID, sid)
+
+	ack, err := c.Exchange(&request)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+	assert.Equal(t, offer.GetYIAddr(), ack.GetYIAddr())
+
+	sc.Close()
+	assert.Equal(t, net.ErrClosed, <-done)
+}
@@ -0,0 +1,473 @@
+package dhcp4
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPoolExhausted = errors.New("dhcp4: no free address in pool")
+	ErrNoLease       = errors.New("dhcp4: no lease for client")
+	ErrAddressInUse  = errors.New("dhcp4: address not available")
+	ErrNotLeaseOwner = errors.New("dhcp4: address not leased to client")
+)
+
+// LeasePool is an in-memory pool of addresses that are leased to clients.
+// Clients are identified by their client identifier (see Packet.ClientID).
+// It is safe for concurrent use.
+type LeasePool struct {
+	// ClientIDPolicy defines how clients presenting a client identifier with
+	// another hardware address are handled, see CheckClient. It must be set
+	// before the pool is used.
+	ClientIDPolicy ClientIDPolicy
+
+	// EvictForReservation drops the lease of another client when reserving
+	// its address, see Reserve.
+	EvictForReservation bool
+
+	// MaxLeases limits the number of outstanding leases, offered or bound,
+	// if greater than 0. Clients without outstanding lease then get
+	// ErrPoolExhausted while the pool is at capacity, so that a storm of new
+	// clients can't take the addresses existing clients renew. Clients with
+	// a reservation are not limited. It must be set before the pool is used.
+	MaxLeases int
+
+	// ConflictHoldDown is the time addresses marked as conflicting are not
+	// leased, see MarkConflict; DefaultConflictHoldDown if 0. It must be set
+	// before the pool is used.
+	ConflictHoldDown time.Duration
+
+	// Clock, if not nil, provides the time leases are bound and expire at,
+	// instead of the time package. It must be set before the pool is used.
+	Clock Clock
+
+	mu sync.Mutex
+
+	// Ranges of addresses in the pool
+	ranges  []addrRange
+	exclude map[uint32]bool
+
+	leases   map[uint32]*Lease
+	byClient map[string]uint32
+	byMAC    map[string]uint32
+
+	// Next address to consider for allocation
+	next uint32
+
+	// Addresses offered to clients, that are not bound yet (see Offer)
+	offered map[uint32]bool
+
+	// Reserved addresses, by client identifier and by address
+	reservations    map[string]uint32
+	reserved        map[uint32]string
+	reservedOptions map[string]OptionMap
+
+	// Expiry of the hold-down of conflicting addresses (see MarkConflict)
+	conflicts map[uint32]time.Time
+
+	// Expiries of the leases bound to clients, and the addresses whose lease
+	// expired, but is still bound, to count outstanding leases if MaxLeases
+	// is set (see atCapacity)
+	lapses expiryHeap
+	lapsed map[uint32]bool
+
+	// Expiry of leases, while the sweeper is running
+	sweeper  *sweeper
+	expiries expiryHeap
+}
+
+func ipToUint32(ip net.IP) (uint32, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(ip4), true
+}
+
+func uint32ToIP(v uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, v)
+	return ip
+}
+
+// NewLeasePool returns a pool with the host addresses of network, which must
+// be an IPv4 network. The network and broadcast addresses and the addresses
+// in exclude are not leased to clients. See NewLeasePoolRanges for pools of
+// several ranges.
+func NewLeasePool(network *net.IPNet, exclude ...net.IP) *LeasePool {
+	base, _ := ipToUint32(network.IP)
+	ones, bits := network.Mask.Size()
+	size := uint32(1) << uint(bits-ones)
+
+	r := addrRange{start: base + 1, end: base + size - 2}
+
+	// Networks without room for network and broadcast address (/31, /32)
+	if size <= 2 {
+		r = addrRange{start: base, end: base + size - 1}
+	}
+
+	p := newLeasePool(exclude)
+	p.ranges = []addrRange{r}
+	p.next = r.start
+	return p
+}
+
+func newLeasePool(exclude []net.IP) *LeasePool {
+	p := &LeasePool{
+		exclude:  make(map[uint32]bool),
+		leases:   make(map[uint32]*Lease),
+		byClient: make(map[string]uint32),
+		byMAC:    make(map[string]uint32),
+	}
+
+	for _, ip := range exclude {
+		if v, ok := ipToUint32(ip); ok {
+			p.exclude[v] = true
+		}
+	}
+
+	return p
+}
+
+// Contains returns whether ip is an address in the pool.
+func (p *LeasePool) Contains(ip net.IP) bool {
+	v, ok := ipToUint32(ip)
+	return ok && p.inRanges(v) && !p.exclude[v]
+}
+
+// isFree returns whether address v can be leased to the client with
+// identifier id. Reserved addresses can only be leased to their client, and
+// a client with a reservation only gets its reserved address. The caller must
+// hold the lock.
+func (p *LeasePool) isFree(v uint32, id string, now time.Time) bool {
+	if w, ok := p.reservations[id]; ok && w != v {
+		return false
+	}
+
+	if owner, ok := p.reserved[v]; ok {
+		if owner != id {
+			return false
+		}
+	} else if !p.inRanges(v) || p.exclude[v] {
+		return false
+	}
+
+	if p.inConflict(v, now) {
+		return false
+	}
+
+	// Declined addresses have a lease without client identifier
+	l, ok := p.leases[v]
+	return !ok || (l.ClientID != nil && string(l.ClientID) == id) || now.After(l.Expiry)
+}
+
+// atCapacity returns whether the client with identifier id can't get a new
+// lease, because it has no outstanding lease and the pool has MaxLeases
+// outstanding leases. The caller must hold the lock.
+func (p *LeasePool) atCapacity(id string, now time.Time) bool {
+	if p.MaxLeases <= 0 {
+		return false
+	}
+
+	if v, ok := p.byClient[id]; ok && !now.After(p.leases[v].Expiry) {
+		return false
+	}
+
+	// Every lease bound to a client is in byClient; declined addresses have
+	// a lease without client identifier
+	p.lapse(now)
+	return len(p.byClient)-len(p.lapsed) >= p.MaxLeases
+}
+
+// lapse records the leases bound to clients that expired before now, which
+// are not outstanding anymore. The caller must hold the lock.
+func (p *LeasePool) lapse(now time.Time) {
+	for len(p.lapses) > 0 && now.After(p.lapses[0].at) {
+		e := heap.Pop(&p.lapses).(expiry)
+
+		l, ok := p.leases[e.v]
+		if !ok || l.ClientID == nil || !l.Expiry.Equal(e.at) {
+			continue
+		}
+
+		if p.lapsed == nil {
+			p.lapsed = make(map[uint32]bool)
+		}
+		p.lapsed[e.v] = true
+	}
+}
+
+// unindexMAC removes the hardware address index entry of the lease of address
+// v, if any. The caller must hold the lock.
+func (p *LeasePool) unindexMAC(v uint32) {
+	l, ok := p.leases[v]
+	if !ok || l.HardwareAddr == nil {
+		return
+	}
+
+	if w, ok := p.byMAC[string(l.HardwareAddr)]; ok && w == v {
+		delete(p.byMAC, string(l.HardwareAddr))
+	}
+}
+
+// bind binds address v to the client with identifier id, until now+d. The
+// client's hardware address is kept from its previous lease. The caller must
+// hold the lock.
+func (p *LeasePool) bind(v uint32, id []byte, now time.Time, d time.Duration) Lease {
+	var hw net.HardwareAddr
+
+	// Drop the client's previous binding, and a stale binding of the address
+	if old, ok := p.byClient[string(id)]; ok {
+		hw = p.leases[old].HardwareAddr
+		p.unindexMAC(old)
+		delete(p.leases, old)
+		delete(p.offered, old)
+		delete(p.lapsed, old)
+	}
+	if l, ok := p.leases[v]; ok {
+		if l.ClientID != nil {
+			delete(p.byClient, string(l.ClientID))
+		}
+		p.unindexMAC(v)
+	}
+
+	l := &Lease{
+		IP:           uint32ToIP(v),
+		ClientID:     append([]byte(nil), id...),
+		HardwareAddr: hw,
+		Expiry:       now.Add(d),
+	}
+
+	p.leases[v] = l
+	p.byClient[string(id)] = v
+	delete(p.offered, v)
+	delete(p.lapsed, v)
+	p.trackExpiry(v, l)
+	if p.MaxLeases > 0 {
+		heap.Push(&p.lapses, expiry{v: v, at: l.Expiry})
+	}
+	if hw != nil {
+		p.byMAC[string(hw)] = v
+	}
+	return *l
+}
+
+// Allocate leases an address to the client with identifier clientID for
+// duration d. A client that already has a lease keeps its address, unless it
+// requests another free address. Otherwise, the requested address is leased
+// if it is free, or else the next free address. It returns ErrPoolExhausted if
+// no address is free, or if the pool is at capacity (see MaxLeases). A client
+// with a reservation always gets its reserved address (see Reserve), or
+// ErrAddressInUse while the address is declined.
+func (p *LeasePool) Allocate(clientID []byte, requested net.IP, d time.Duration) (Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.allocate(clientID, requested, p.clock().Now(), d)
+}
+
+// allocate implements Allocate. The caller must hold the lock.
+func (p *LeasePool) allocate(clientID []byte, requested net.IP, now time.Time, d time.Duration) (Lease, error) {
+	id := string(clientID)
+
+	if v, ok := p.reservations[id]; ok {
+		if !p.isFree(v, id, now) {
+			return Lease{}, ErrAddressInUse
+		}
+		return p.bind(v, clientID, now, d), nil
+	}
+
+	if p.atCapacity(id, now) {
+		return Lease{}, ErrPoolExhausted
+	}
+
+	if v, ok := ipToUint32(requested); ok && p.isFree(v, id, now) {
+		return p.bind(v, clientID, now, d), nil
+	}
+
+	if v, ok := p.byClient[id]; ok && !p.inConflict(v, now) {
+		return p.bind(v, clientID, now, d), nil
+	}
+
+	for n := p.size(); n > 0; n-- {
+		v := p.next
+		p.advance()
+
+		if p.isFree(v, id, now) {
+			return p.bind(v, clientID, now, d), nil
+		}
+	}
+
+	return Lease{}, ErrPoolExhausted
+}
+
+// AllocateIP leases address ip to the client with identifier clientID for
+// duration d, renewing the client's lease if ip is already leased to it. It
+// returns ErrAddressInUse if ip is not in the pool, or leased to another
+// client, and ErrPoolExhausted if the pool is at capacity (see MaxLeases).
+func (p *LeasePool) AllocateIP(clientID []byte, ip net.IP, d time.Duration) (Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock().Now()
+	if _, reserved := p.reservations[string(clientID)]; !reserved && p.atCapacity(string(clientID), now) {
+		return Lease{}, ErrPoolExhausted
+	}
+
+	if v, ok := ipToUint32(ip); ok && p.isFree(v, string(clientID), now) {
+		return p.bind(v, clientID, now, d), nil
+	}
+
+	return Lease{}, ErrAddressInUse
+}
+
+// Lookup returns the lease of the client with identifier clientID. The lease
+// may have expired.
+func (p *LeasePool) Lookup(clientID []byte) (Lease, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.byClient[string(clientID)]
+	if !ok {
+		return Lease{}, false
+	}
+
+	return *p.leases[v], true
+}
+
+// owned returns the address leased to the client with identifier clientID.
+// It returns ErrNoLease if the client has no lease, and ErrNotLeaseOwner if ip
+// is not nil and not the address of the lease. The caller must hold the lock.
+func (p *LeasePool) owned(clientID []byte, ip net.IP) (uint32, error) {
+	v, ok := p.byClient[string(clientID)]
+	if !ok {
+		return 0, ErrNoLease
+	}
+
+	if ip != nil {
+		if w, ok := ipToUint32(ip); !ok || w != v {
+			return 0, ErrNotLeaseOwner
+		}
+	}
+
+	return v, nil
+}
+
+// Release ends the lease of address ip of the client with identifier
+// clientID, as requested by the client with a DHCPRELEASE. It returns
+// ErrNoLease if the client has no lease, and ErrNotLeaseOwner if the client's
+// lease is for another address, so that a client can't release the address
+// of another client. A nil ip releases the client's lease, whatever its
+// address.
+func (p *LeasePool) Release(clientID []byte, ip net.IP) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, err := p.owned(clientID, ip)
+	if err != nil {
+		return err
+	}
+
+	p.drop(v)
+	return nil
+}
+
+// Decline ends the lease of address ip of the client with identifier
+// clientID, after the client found the address to be in use by another host.
+// The address is not leased again until the declined lease would have
+// expired, and the address is marked as conflicting (see MarkConflict). Like
+// Release, it returns ErrNoLease if the client has no lease, and
+// ErrNotLeaseOwner if the client's lease is for another address, so that a
+// client can't block the address of another client. A nil ip declines the
+// client's lease, whatever its address.
+func (p *LeasePool) Decline(clientID []byte, ip net.IP) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, err := p.owned(clientID, ip)
+	if err != nil {
+		return err
+	}
+
+	// Keep the address bound, to no client
+	delete(p.byClient, string(clientID))
+	delete(p.offered, v)
+	delete(p.lapsed, v)
+	p.unindexMAC(v)
+	p.leases[v].ClientID = nil
+	p.leases[v].HardwareAddr = nil
+	p.markConflict(v, p.clock().Now())
+	return nil
+}
+
+// SetHardwareAddr records the hardware address of the client with identifier
+// clientID in its lease, so that the lease can be found with FindByMAC. It
+// returns ErrNoLease if the client has no lease.
+func (p *LeasePool) SetHardwareAddr(clientID []byte, mac net.HardwareAddr) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.byClient[string(clientID)]
+	if !ok {
+		return ErrNoLease
+	}
+
+	p.unindexMAC(v)
+	p.leases[v].HardwareAddr = append(net.HardwareAddr(nil), mac...)
+	p.byMAC[string(mac)] = v
+	return nil
+}
+
+// LeaseStore is implemented by stores of leases that can answer lease
+// queries (RFC4388) without scanning all leases. The returned leases may have
+// expired.
+type LeaseStore interface {
+	FindByIP(ip net.IP) (Lease, bool)
+	FindByClientID(clientID []byte) (Lease, bool)
+	FindByMAC(mac net.HardwareAddr) (Lease, bool)
+}
+
+// FindByIP returns the lease of address ip. A declined address has a lease
+// without client identifier.
+func (p *LeasePool) FindByIP(ip net.IP) (Lease, bool) {
+	v, ok := ipToUint32(ip)
+	if !ok {
+		return Lease{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.leases[v]
+	if !ok {
+		return Lease{}, false
+	}
+
+	return *l, true
+}
+
+// FindByClientID returns the lease of the client with identifier clientID,
+// like Lookup.
+func (p *LeasePool) FindByClientID(clientID []byte) (Lease, bool) {
+	return p.Lookup(clientID)
+}
+
+// FindByMAC returns the lease of the client with hardware address mac, as
+// recorded by SetHardwareAddr. If several clients share the address, the
+// lease recorded last is returned.
+func (p *LeasePool) FindByMAC(mac net.HardwareAddr) (Lease, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.byMAC[string(mac)]
+	if !ok {
+		return Lease{}, false
+	}
+
+	return *p.leases[v], true
+}
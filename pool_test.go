@@ -0,0 +1,297 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLeasePool(t *testing.T, cidr string, exclude ...net.IP) *LeasePool {
+	_, network, err := net.ParseCIDR(cidr)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return NewLeasePool(network, exclude...)
+}
+
+func TestLeasePoolAllocate(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/29", net.IPv4(10, 0, 0, 1))
+
+	// Network, broadcast and excluded addresses are not in the pool
+	assert.False(t, p.Contains(net.IPv4(10, 0, 0, 0)))
+	assert.False(t, p.Contains(net.IPv4(10, 0, 0, 1)))
+	assert.True(t, p.Contains(net.IPv4(10, 0, 0, 2)))
+	assert.True(t, p.Contains(net.IPv4(10, 0, 0, 6)))
+	assert.False(t, p.Contains(net.IPv4(10, 0, 0, 7)))
+
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 2}, l.IP)
+
+	// A client keeps its address
+	l, err = p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 2}, l.IP)
+
+	// A requested address is leased if free
+	l, err = p.Allocate([]byte("b"), net.IPv4(10, 0, 0, 5), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 5}, l.IP)
+
+	// Otherwise, the next free address is
+	l, err = p.Allocate([]byte("c"), net.IPv4(10, 0, 0, 5), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 3}, l.IP)
+
+	_, err = p.Allocate([]byte("d"), nil, time.Hour)
+	assert.NoError(t, err)
+	_, err = p.Allocate([]byte("e"), nil, time.Hour)
+	assert.NoError(t, err)
+	_, err = p.Allocate([]byte("f"), nil, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// Released addresses are leased again
+	assert.NoError(t, p.Release([]byte("b"), nil))
+	assert.Equal(t, ErrNoLease, p.Release([]byte("b"), nil))
+	l, err = p.Allocate([]byte("f"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 5}, l.IP)
+}
+
+func TestLeasePoolExpiredLeases(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+
+	_, err := p.Allocate([]byte("a"), nil, -time.Second)
+	assert.NoError(t, err)
+	_, err = p.Allocate([]byte("b"), nil, time.Hour)
+	assert.NoError(t, err)
+
+	// The expired lease is taken over
+	l, err := p.Allocate([]byte("c"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, l.IP)
+
+	_, ok := p.Lookup([]byte("a"))
+	assert.False(t, ok)
+}
+
+func TestLeasePoolAllocateIP(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+
+	l, err := p.AllocateIP([]byte("a"), net.IPv4(10, 0, 0, 10), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 10}, l.IP)
+
+	// Renewing
+	_, err = p.AllocateIP([]byte("a"), net.IPv4(10, 0, 0, 10), time.Hour)
+	assert.NoError(t, err)
+
+	_, err = p.AllocateIP([]byte("b"), net.IPv4(10, 0, 0, 10), time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+	_, err = p.AllocateIP([]byte("b"), net.IPv4(10, 0, 1, 10), time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+	_, err = p.AllocateIP([]byte("b"), nil, time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+}
+
+func TestLeasePoolDecline(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Decline([]byte("a"), nil))
+
+	// The declined address is not leased again, not even to the same client
+	l2, err := p.Allocate([]byte("a"), l.IP, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, l.IP, l2.IP)
+
+	_, err = p.Allocate([]byte("b"), nil, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+}
+
+func TestLeasePoolFind(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	var _ LeaseStore = p
+
+	a := []byte{1, 2, 0, 0, 0, 0, 1}
+	b := []byte{1, 2, 0, 0, 0, 0, 2}
+	macA := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	l, err := p.Allocate(a, nil, time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, p.SetHardwareAddr(a, macA))
+	assert.Equal(t, ErrNoLease, p.SetHardwareAddr(b, macA))
+
+	byIP, ok := p.FindByIP(l.IP)
+	assert.True(t, ok)
+	assert.Equal(t, a, byIP.ClientID)
+	assert.Equal(t, macA, byIP.HardwareAddr)
+
+	byID, ok := p.FindByClientID(a)
+	assert.True(t, ok)
+	assert.Equal(t, l.IP, byID.IP)
+
+	byMAC, ok := p.FindByMAC(macA)
+	assert.True(t, ok)
+	assert.Equal(t, l.IP, byMAC.IP)
+
+	// Renewal keeps the hardware address
+	_, err = p.AllocateIP(a, l.IP, time.Hour)
+	assert.NoError(t, err)
+	byMAC, ok = p.FindByMAC(macA)
+	assert.True(t, ok)
+	assert.Equal(t, macA, byMAC.HardwareAddr)
+
+	// Moving to another address moves the indexes
+	m, err := p.Allocate(a, net.IPv4(10, 0, 0, 50), time.Hour)
+	assert.NoError(t, err)
+	_, ok = p.FindByIP(l.IP)
+	assert.False(t, ok)
+	byMAC, ok = p.FindByMAC(macA)
+	assert.True(t, ok)
+	assert.Equal(t, m.IP, byMAC.IP)
+
+	// Release removes all indexes
+	assert.NoError(t, p.Release(a, nil))
+	_, ok = p.FindByIP(m.IP)
+	assert.False(t, ok)
+	_, ok = p.FindByClientID(a)
+	assert.False(t, ok)
+	_, ok = p.FindByMAC(macA)
+	assert.False(t, ok)
+}
+
+func TestLeasePoolFindExpiredRebound(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/30")
+
+	a := []byte{1, 2, 0, 0, 0, 0, 1}
+	b := []byte{1, 2, 0, 0, 0, 0, 2}
+	macA := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	l, _ := p.Allocate(a, nil, -time.Second)
+	p.SetHardwareAddr(a, macA)
+
+	// Another client takes over the expired lease
+	m, err := p.AllocateIP(b, l.IP, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, l.IP, m.IP)
+
+	_, ok := p.FindByClientID(a)
+	assert.False(t, ok)
+	_, ok = p.FindByMAC(macA)
+	assert.False(t, ok)
+	byIP, _ := p.FindByIP(l.IP)
+	assert.Equal(t, b, byIP.ClientID)
+	assert.Nil(t, byIP.HardwareAddr)
+}
+
+func TestLeasePoolMaxLeases(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	p.MaxLeases = 2
+
+	_, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	_, err = p.Offer([]byte("b"), nil, time.Minute)
+	assert.NoError(t, err)
+
+	// New clients are refused at capacity
+	_, err = p.Allocate([]byte("c"), nil, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+	_, err = p.Offer([]byte("c"), nil, time.Minute)
+	assert.Equal(t, ErrPoolExhausted, err)
+	_, err = p.AllocateIP([]byte("c"), net.IPv4(10, 0, 0, 10), time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// Existing clients renew, and bind their offer
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, l.IP)
+	l, err = p.AllocateIP([]byte("b"), net.IPv4(10, 0, 0, 2), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 2}, l.IP)
+
+	// Clients with a reservation are not limited
+	assert.NoError(t, p.Reserve([]byte("r"), net.IPv4(10, 0, 0, 20)))
+	l, err = p.AllocateIP([]byte("r"), net.IPv4(10, 0, 0, 20), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 20}, l.IP)
+
+	// Released and expired leases make room
+	assert.NoError(t, p.Release([]byte("r"), nil))
+	assert.NoError(t, p.Release([]byte("a"), nil))
+	_, err = p.Allocate([]byte("c"), nil, -time.Second)
+	assert.NoError(t, err)
+	_, err = p.Allocate([]byte("d"), nil, time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestLeasePoolMaxLeasesExpiry(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	p.MaxLeases = 2
+
+	now := time.Now()
+	_, err := p.allocate([]byte("a"), nil, now, time.Minute)
+	assert.NoError(t, err)
+	_, err = p.allocate([]byte("b"), nil, now, time.Hour)
+	assert.NoError(t, err)
+	_, err = p.allocate([]byte("c"), nil, now, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// The expired lease of a is not outstanding, though still bound
+	later := now.Add(2 * time.Minute)
+	_, err = p.allocate([]byte("c"), nil, later, time.Hour)
+	assert.NoError(t, err)
+	_, ok := p.Lookup([]byte("a"))
+	assert.True(t, ok)
+
+	// The pool is at capacity again, also for a, whose lease expired
+	_, err = p.allocate([]byte("d"), nil, later, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+	_, err = p.allocate([]byte("a"), nil, later, time.Hour)
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	// Released and declined leases are not counted either
+	assert.NoError(t, p.Release([]byte("b"), nil))
+	_, err = p.allocate([]byte("a"), nil, later, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Decline([]byte("a"), nil))
+	_, err = p.allocate([]byte("d"), nil, later, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, p.lapsed)
+}
+
+func TestLeasePoolReleaseDeclineOwner(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/29")
+
+	a, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+	b, err := p.Allocate([]byte("b"), nil, time.Hour)
+	assert.NoError(t, err)
+
+	// A client can't release or decline the address of another client
+	assert.Equal(t, ErrNotLeaseOwner, p.Release([]byte("a"), b.IP))
+	assert.Equal(t, ErrNotLeaseOwner, p.Decline([]byte("a"), b.IP))
+	assert.Equal(t, ErrNotLeaseOwner, p.Decline([]byte("a"), net.IPv4zero))
+	assert.Equal(t, ErrNoLease, p.Release([]byte("c"), b.IP))
+	assert.Empty(t, p.Conflicts())
+
+	l, ok := p.Lookup([]byte("b"))
+	assert.True(t, ok)
+	assert.Equal(t, b.IP, l.IP)
+
+	// But its own
+	assert.NoError(t, p.Release([]byte("a"), a.IP))
+	_, ok = p.Lookup([]byte("a"))
+	assert.False(t, ok)
+
+	assert.NoError(t, p.Decline([]byte("b"), b.IP.To16()))
+	_, ok = p.Lookup([]byte("b"))
+	assert.False(t, ok)
+	assert.Len(t, p.Conflicts(), 1)
+}
@@ -0,0 +1,104 @@
+package dhcp4
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+// ServerOffer is a DHCPOFFER received by Probe.
+type ServerOffer struct {
+	// ServerID is the Server Identifier option of the offer, if any.
+	ServerID net.IP
+
+	// Addr is the address the offer was sent from, which is the relay agent
+	// for servers on other networks.
+	Addr net.Addr
+
+	// Offer holds the offered address ('yiaddr') and options.
+	Offer *Packet
+}
+
+// Probe broadcasts a DHCPDISCOVER on conn and returns the DHCPOFFERs of all
+// servers that reply within timeout, in the order they arrive, e.g. to detect
+// rogue servers. A server that sends several offers is reported once. Probe
+// never sends a DHCPREQUEST, so it doesn't take a lease; the servers' offers
+// expire on their own. The request has a random, locally administered
+// hardware address, and the broadcast flag set, so that servers broadcast
+// their offers. Probe returns the offers received when the timeout passes,
+// and an error only if sending or reading fails. See Client.Probe to probe
+// with a Clock.
+func Probe(conn ClientConn, timeout time.Duration) ([]ServerOffer, error) {
+	c := Client{Conn: conn}
+	return c.Probe(timeout)
+}
+
+// Probe broadcasts a DHCPDISCOVER on the client's connection and returns the
+// DHCPOFFERs of all servers that reply within timeout, like the Probe
+// function. The timeout is measured with the client's Clock, and the request
+// sent on IfIndex; Addr and Timeout are ignored.
+func (c *Client) Probe(timeout time.Duration) ([]ServerOffer, error) {
+	req := newProbeDiscover()
+	b, err := PacketToBytes(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4bcast, Port: ServerPort}
+	if _, err := c.Conn.WriteTo(b, addr, c.IfIndex); err != nil {
+		return nil, err
+	}
+
+	var offers []ServerOffer
+	seen := make(map[string]bool)
+
+	buf := make([]byte, 65536)
+	err = c.readReplies(buf, req.XID(), c.clock().Now().Add(timeout), func(p *Packet, addr net.Addr) bool {
+		if p.GetMessageType() != MessageTypeOffer {
+			return true
+		}
+
+		id, _ := p.GetIP(OptionDHCPServerID)
+		key := addr.String()
+		if id != nil {
+			key = id.String()
+		}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+
+		offers = append(offers, ServerOffer{ServerID: id, Addr: addr, Offer: p})
+		return true
+	})
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		err = nil
+	}
+
+	return offers, err
+}
+
+// newProbeDiscover returns the DHCPDISCOVER sent by Probe.
+func newProbeDiscover() Packet {
+	p := NewPacket(BootRequest)
+	rand.Read(p.XID())
+
+	// Locally administered, unicast
+	hw := make(net.HardwareAddr, 6)
+	rand.Read(hw)
+	hw[0] = hw[0]&^0x01 | 0x02
+	p.HType()[0] = 1
+	p.SetCHAddr(hw)
+
+	p.Flags()[0] |= 0x80
+	p.SetMessageType(MessageTypeDiscover)
+
+	// Ask for the parameters servers commonly hand out
+	params := make([]byte, len(DefaultParameterList))
+	for i, o := range DefaultParameterList {
+		params[i] = byte(o)
+	}
+	p.SetOption(OptionParameterList, params)
+	return p
+}
@@ -0,0 +1,85 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testProbeOffer(t *testing.T, req []byte, serverID net.IP, msgType MessageType) []byte {
+	rep := NewPacket(BootReply)
+	copy(rep.XID(), RawPacket(req).XID())
+	rep.SetMessageType(msgType)
+	rep.SetYIAddr(net.IPv4(10, 0, 0, 5))
+	if serverID != nil {
+		rep.SetIP(OptionDHCPServerID, serverID)
+	}
+
+	b, err := PacketToBytes(rep, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return b
+}
+
+func TestProbe(t *testing.T) {
+	var conn *testClientConn
+	conn = newTestClientConn(func(n int) [][]byte {
+		req := conn.writes[n-1]
+		return [][]byte{
+			testProbeOffer(t, req, net.IPv4(10, 0, 0, 1), MessageTypeOffer),
+			testClientReply(t, []byte{5, 6, 7, 8}),
+			testProbeOffer(t, req, net.IPv4(10, 0, 0, 2), MessageTypeOffer),
+			testProbeOffer(t, req, net.IPv4(10, 0, 0, 1), MessageTypeOffer),
+			testProbeOffer(t, req, net.IPv4(10, 0, 0, 3), MessageTypeNak),
+		}
+	})
+
+	offers, err := Probe(conn, 20*time.Millisecond)
+	assert.NoError(t, err)
+
+	// Every server once, other messages ignored
+	if assert.Len(t, offers, 2) {
+		assert.Equal(t, net.IPv4(10, 0, 0, 1), offers[0].ServerID)
+		assert.Equal(t, net.IPv4(10, 0, 0, 2), offers[1].ServerID)
+		assert.Equal(t, net.IP{10, 0, 0, 5}, offers[1].Offer.GetYIAddr())
+		assert.NotNil(t, offers[0].Addr)
+	}
+
+	// A single DHCPDISCOVER is sent, never a DHCPREQUEST
+	if assert.Len(t, conn.writes, 1) {
+		req, err := PacketFromBytes(conn.writes[0])
+		if assert.NoError(t, err) {
+			assert.Equal(t, MessageTypeDiscover, req.GetMessageType())
+			assert.True(t, req.GetFlags()[0]&0x80 > 0, "broadcast flag")
+			hw := req.GetCHAddr()
+			if assert.Len(t, hw, 6) {
+				assert.Equal(t, byte(0x02), hw[0]&0x03)
+			}
+		}
+	}
+}
+
+func TestProbeNoServers(t *testing.T) {
+	conn := newTestClientConn(nil)
+
+	offers, err := Probe(conn, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Empty(t, offers)
+}
+
+func TestClientProbeClock(t *testing.T) {
+	conn := newTestClientConn(nil)
+	clk := newFakeClock()
+
+	// The probe window ends at the time of the client's clock
+	c := Client{Conn: conn, Clock: clk, IfIndex: 2}
+	offers, err := c.Probe(time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, offers)
+	assert.Equal(t, clk.Now().Add(time.Minute), conn.deadline)
+	assert.Len(t, conn.writes, 1)
+}
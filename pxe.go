@@ -0,0 +1,213 @@
+package dhcp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+var (
+	ErrInvalidPXEOption = errors.New("dhcp4: invalid PXE vendor option")
+)
+
+// PXE vendor options, carried in the Vendor Specific Information option (43)
+// of clients with vendor class "PXEClient". From the Preboot Execution
+// Environment (PXE) Specification, version 2.1, section 2.4.
+const (
+	PXEDiscoveryControl = Option(6)
+	PXEBootServers      = Option(8)
+	PXEBootMenu         = Option(9)
+	PXEMenuPrompt       = Option(10)
+)
+
+// Bits of the PXE discovery control option.
+const (
+	PXEDisableBroadcast = uint8(1 << 0) // Disable broadcast discovery
+	PXEDisableMulticast = uint8(1 << 1) // Disable multicast discovery
+	PXEOnlyBootServers  = uint8(1 << 2) // Only use and accept servers in PXEBootServers
+	PXEBootFileDirect   = uint8(1 << 3) // Download the boot file without prompting or discovery
+)
+
+// PXEBootServer is an entry of the PXE boot servers option.
+type PXEBootServer struct {
+	Type  uint16
+	Addrs []net.IP
+}
+
+// PXEBootMenuItem is an entry of the PXE boot menu option. Type refers to a
+// boot server type in the PXE boot servers option.
+type PXEBootMenuItem struct {
+	Type        uint16
+	Description string
+}
+
+// PXEPrompt is the value of the PXE menu prompt option. Timeout is the number
+// of seconds to wait for a key press; 0 selects the first menu item
+// immediately and 255 waits for a key press indefinitely.
+type PXEPrompt struct {
+	Timeout uint8
+	Prompt  string
+}
+
+// PXEVendorOptions holds the PXE sub-options of option 43.
+type PXEVendorOptions struct {
+	OptionMap
+}
+
+// NewPXEVendorOptions returns an empty set of PXE vendor options.
+func NewPXEVendorOptions() PXEVendorOptions {
+	return PXEVendorOptions{make(OptionMap)}
+}
+
+// GetPXEVendorOptions parses the Vendor Specific Information option as PXE
+// vendor options. It returns an empty set if the option is not set.
+func (om OptionMap) GetPXEVendorOptions() (PXEVendorOptions, error) {
+	p := NewPXEVendorOptions()
+	if v, ok := om.GetOption(OptionVendorSpecific); ok {
+		opts := OptionMapDeserializeOptions{IgnoreMissingEndTag: true}
+		if err := p.Deserialize(v, &opts); err != nil {
+			return PXEVendorOptions{}, err
+		}
+	}
+
+	return p, nil
+}
+
+// SetPXEVendorOptions sets the Vendor Specific Information option to the
+// encoded PXE vendor options.
+func (om OptionMap) SetPXEVendorOptions(p PXEVendorOptions) {
+	om.SetOption(OptionVendorSpecific, p.Bytes())
+}
+
+// Bytes returns the encoded PXE vendor options, in numeric order, followed by
+// the end tag.
+func (p PXEVendorOptions) Bytes() []byte {
+	b := bytes.Buffer{}
+	for _, k := range p.GetSortedOptions() {
+		for _, c := range splitOption(p.OptionMap[k]) {
+			b.WriteByte(byte(k))
+			b.WriteByte(byte(len(c)))
+			b.Write(c)
+		}
+	}
+
+	b.WriteByte(byte(OptionEnd))
+	return b.Bytes()
+}
+
+// GetDiscoveryControl gets the PXE discovery control bits.
+func (p PXEVendorOptions) GetDiscoveryControl() (uint8, bool) {
+	return p.GetUint8(PXEDiscoveryControl)
+}
+
+// SetDiscoveryControl sets the PXE discovery control bits.
+func (p PXEVendorOptions) SetDiscoveryControl(v uint8) {
+	p.SetOption(PXEDiscoveryControl, []byte{v})
+}
+
+// GetBootServers gets the PXE boot servers.
+func (p PXEVendorOptions) GetBootServers() ([]PXEBootServer, error) {
+	v, ok := p.GetOption(PXEBootServers)
+	if !ok {
+		return nil, nil
+	}
+
+	var servers []PXEBootServer
+	for len(v) > 0 {
+		if len(v) < 3 || len(v) < 3+4*int(v[2]) {
+			return nil, ErrInvalidPXEOption
+		}
+
+		s := PXEBootServer{Type: binary.BigEndian.Uint16(v)}
+		n := int(v[2])
+		v = v[3:]
+		for i := 0; i < n; i++ {
+			s.Addrs = append(s.Addrs, net.IP(append([]byte(nil), v[:4]...)))
+			v = v[4:]
+		}
+
+		servers = append(servers, s)
+	}
+
+	return servers, nil
+}
+
+// SetBootServers sets the PXE boot servers. It returns ErrInvalidPXEOption if
+// a server has more than 255 addresses, or an address is not IPv4.
+func (p PXEVendorOptions) SetBootServers(servers []PXEBootServer) error {
+	var b []byte
+	for _, s := range servers {
+		if len(s.Addrs) > 255 {
+			return ErrInvalidPXEOption
+		}
+
+		b = append(b, byte(s.Type>>8), byte(s.Type), byte(len(s.Addrs)))
+		for _, ip := range s.Addrs {
+			ip4 := ip.To4()
+			if ip4 == nil {
+				return ErrInvalidPXEOption
+			}
+			b = append(b, ip4...)
+		}
+	}
+
+	p.SetOption(PXEBootServers, b)
+	return nil
+}
+
+// GetBootMenu gets the items of the PXE boot menu.
+func (p PXEVendorOptions) GetBootMenu() ([]PXEBootMenuItem, error) {
+	v, ok := p.GetOption(PXEBootMenu)
+	if !ok {
+		return nil, nil
+	}
+
+	var items []PXEBootMenuItem
+	for len(v) > 0 {
+		if len(v) < 3 || len(v) < 3+int(v[2]) {
+			return nil, ErrInvalidPXEOption
+		}
+
+		n := int(v[2])
+		items = append(items, PXEBootMenuItem{
+			Type:        binary.BigEndian.Uint16(v),
+			Description: string(v[3 : 3+n]),
+		})
+		v = v[3+n:]
+	}
+
+	return items, nil
+}
+
+// SetBootMenu sets the items of the PXE boot menu. It returns
+// ErrInvalidPXEOption if a description is longer than 255 octets.
+func (p PXEVendorOptions) SetBootMenu(items []PXEBootMenuItem) error {
+	var b []byte
+	for _, it := range items {
+		if len(it.Description) > 255 {
+			return ErrInvalidPXEOption
+		}
+
+		b = append(b, byte(it.Type>>8), byte(it.Type), byte(len(it.Description)))
+		b = append(b, it.Description...)
+	}
+
+	p.SetOption(PXEBootMenu, b)
+	return nil
+}
+
+// GetMenuPrompt gets the PXE menu prompt.
+func (p PXEVendorOptions) GetMenuPrompt() (PXEPrompt, bool) {
+	v, ok := p.GetOption(PXEMenuPrompt)
+	if !ok || len(v) < 1 {
+		return PXEPrompt{}, false
+	}
+
+	return PXEPrompt{Timeout: v[0], Prompt: string(v[1:])}, true
+}
+
+// SetMenuPrompt sets the PXE menu prompt.
+func (p PXEVendorOptions) SetMenuPrompt(v PXEPrompt) {
+	p.SetOption(PXEMenuPrompt, append([]byte{v.Timeout}, v.Prompt...))
+}
@@ -0,0 +1,121 @@
+package dhcp4
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPXEVendorOptionsRoundtrip(t *testing.T) {
+	servers := []PXEBootServer{
+		{Type: 0x8000, Addrs: []net.IP{net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4()}},
+		{Type: 0x8001, Addrs: []net.IP{net.IPv4(10, 0, 0, 3).To4()}},
+	}
+	menu := []PXEBootMenuItem{
+		{Type: 0x8000, Description: "Install"},
+		{Type: 0x8001, Description: "Rescue"},
+	}
+	prompt := PXEPrompt{Timeout: 10, Prompt: "Press F8 for boot menu"}
+
+	p := NewPXEVendorOptions()
+	p.SetDiscoveryControl(PXEDisableMulticast | PXEOnlyBootServers)
+	assert.NoError(t, p.SetBootServers(servers))
+	assert.NoError(t, p.SetBootMenu(menu))
+	p.SetMenuPrompt(prompt)
+
+	om := make(OptionMap)
+	om.SetPXEVendorOptions(p)
+
+	q, err := om.GetPXEVendorOptions()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dc, ok := q.GetDiscoveryControl()
+	assert.True(t, ok)
+	assert.Equal(t, PXEDisableMulticast|PXEOnlyBootServers, dc)
+
+	actualServers, err := q.GetBootServers()
+	assert.NoError(t, err)
+	assert.Equal(t, servers, actualServers)
+
+	actualMenu, err := q.GetBootMenu()
+	assert.NoError(t, err)
+	assert.Equal(t, menu, actualMenu)
+
+	actualPrompt, ok := q.GetMenuPrompt()
+	assert.True(t, ok)
+	assert.Equal(t, prompt, actualPrompt)
+}
+
+func TestPXEVendorOptionsInvalid(t *testing.T) {
+	p := NewPXEVendorOptions()
+	assert.Equal(t, ErrInvalidPXEOption, p.SetBootServers([]PXEBootServer{{Addrs: []net.IP{net.ParseIP("::1")}}}))
+	assert.Equal(t, ErrInvalidPXEOption, p.SetBootMenu([]PXEBootMenuItem{{Description: string(make([]byte, 256))}}))
+
+	// Truncated entries
+	p.SetOption(PXEBootServers, []byte{0x80, 0x00, 2, 10, 0, 0, 1})
+	_, err := p.GetBootServers()
+	assert.Equal(t, ErrInvalidPXEOption, err)
+
+	p.SetOption(PXEBootMenu, []byte{0x80, 0x00, 5, 'a'})
+	_, err = p.GetBootMenu()
+	assert.Equal(t, ErrInvalidPXEOption, err)
+
+	// Truncated sub-option
+	om := make(OptionMap)
+	om.SetOption(OptionVendorSpecific, []byte{byte(PXEMenuPrompt), 4, 0})
+	_, err = om.GetPXEVendorOptions()
+	assert.Equal(t, ErrShortPacket, err)
+}
+
+func ExamplePXEVendorOptions() {
+	p := NewPXEVendorOptions()
+
+	// Only use the boot servers in the list, and don't use multicast to find them
+	p.SetDiscoveryControl(PXEDisableMulticast | PXEOnlyBootServers)
+	p.SetBootServers([]PXEBootServer{
+		{Type: 0x8000, Addrs: []net.IP{net.IPv4(10, 0, 0, 1)}},
+	})
+	p.SetBootMenu([]PXEBootMenuItem{
+		{Type: 0x8000, Description: "Install"},
+	})
+	p.SetMenuPrompt(PXEPrompt{Timeout: 5, Prompt: "Boot menu"})
+
+	reply := make(OptionMap)
+	reply.SetString(OptionClassID, "PXEClient")
+	reply.SetPXEVendorOptions(p)
+
+	v, _ := reply.GetOption(OptionVendorSpecific)
+	fmt.Printf("% x\n", v)
+	// Output:
+	// 06 01 06 08 07 80 00 01 0a 00 00 01 09 0a 80 00 07 49 6e 73 74 61 6c 6c 0a 0a 05 42 6f 6f 74 20 6d 65 6e 75 ff
+}
+
+func TestPXEVendorOptionsTerminators(t *testing.T) {
+	om := make(OptionMap)
+
+	// Pad octets are skipped, and the end tag ends the sub-options
+	om.SetOption(OptionVendorSpecific, []byte{0, byte(PXEDiscoveryControl), 1, 3, 0, 255, byte(PXEMenuPrompt), 1, 0})
+	p, err := om.GetPXEVendorOptions()
+	assert.NoError(t, err)
+	v, ok := p.GetDiscoveryControl()
+	assert.True(t, ok)
+	assert.Equal(t, uint8(3), v)
+	_, ok = p.GetMenuPrompt()
+	assert.False(t, ok)
+
+	// Without end tag
+	om.SetOption(OptionVendorSpecific, []byte{byte(PXEDiscoveryControl), 1, 3})
+	p, err = om.GetPXEVendorOptions()
+	assert.NoError(t, err)
+	v, _ = p.GetDiscoveryControl()
+	assert.Equal(t, uint8(3), v)
+
+	// The encoding ends with the end tag
+	om.SetPXEVendorOptions(p)
+	b, _ := om.GetOption(OptionVendorSpecific)
+	assert.Equal(t, []byte{byte(PXEDiscoveryControl), 1, 3, 255}, b)
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package dhcp4
+
+// raceEnabled is set when testing with the race detector, which makes
+// sync.Pool drop items at random, so that pooled buffers are allocated again.
+const raceEnabled = true
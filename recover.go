@@ -0,0 +1,26 @@
+package dhcp4
+
+import "runtime/debug"
+
+type recoverHandler struct {
+	h       Handler
+	metrics Metrics
+}
+
+// Recover returns a handler that calls h, and recovers from panics in h. A
+// panic is logged with the stack trace of the panicking goroutine, after which
+// the handler returns as if h had returned normally.
+func Recover(h Handler) Handler {
+	return &recoverHandler{h: h, metrics: nopMetrics{}}
+}
+
+func (rh *recoverHandler) ServeDHCP(w ReplyWriter, p *Packet) {
+	defer func() {
+		if r := recover(); r != nil {
+			clog.Errorf("panic serving %s from %s: %v\n%s", p.GetMessageType(), p.GetCHAddr(), r, debug.Stack())
+			rh.metrics.HandlerPanicked(p.GetMessageType())
+		}
+	}()
+
+	rh.h.ServeDHCP(w, p)
+}
@@ -0,0 +1,106 @@
+package dhcp4
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testMetrics struct {
+	mock.Mock
+}
+
+func (m *testMetrics) PacketReceived(t MessageType, ifindex int) {
+	m.Called(t, ifindex)
+}
+
+func (m *testMetrics) PacketDropped(reason DropReason, ifindex int) {
+	m.Called(reason, ifindex)
+}
+
+func (m *testMetrics) HandlerPanicked(t MessageType) {
+	m.Called(t)
+}
+
+func (m *testMetrics) ReplyLatency(t MessageType, d time.Duration) {
+	m.Called(t, d)
+}
+
+func (m *testMetrics) NotificationDropped() {
+	m.Called()
+}
+
+func testRequestBytes(t *testing.T, mt MessageType) []byte {
+	return PacketSpec{Options: map[Option][]byte{OptionDHCPMsgType: {byte(mt)}}}.Bytes()
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover(HandlerFunc(func(w ReplyWriter, p *Packet) {
+		panic("boom")
+	}))
+
+	p := NewPacket(BootRequest)
+	assert.NotPanics(t, func() { h.ServeDHCP(nil, &p) })
+}
+
+func TestServerRecoversFromPanics(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeDiscover))
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeRequest))
+	pc.ReadError(io.EOF)
+
+	m := &testMetrics{}
+	m.On("PacketReceived", mock.Anything, mock.Anything).Return()
+	m.On("HandlerPanicked", MessageTypeDiscover).Return().Once()
+
+	var served []MessageType
+	s := Server{
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) {
+			if p.GetMessageType() == MessageTypeDiscover {
+				panic("boom")
+			}
+			served = append(served, p.GetMessageType())
+		}),
+		Metrics: m,
+	}
+
+	err := s.Serve(pc)
+	assert.Equal(t, io.EOF, err)
+
+	// The server keeps serving after the handler panics
+	assert.Equal(t, []MessageType{MessageTypeRequest}, served)
+	m.AssertExpectations(t)
+	m.AssertNumberOfCalls(t, "PacketReceived", 2)
+}
+
+func TestServerWithoutRecover(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeDiscover))
+	pc.ReadError(io.EOF)
+
+	s := Server{
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) {
+			panic("boom")
+		}),
+		DisableRecover: true,
+	}
+
+	assert.Panics(t, func() { s.Serve(pc) })
+}
+
+func TestServerCountsDroppedPackets(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess([]byte("this is a garbage packet"))
+	pc.ReadError(io.EOF)
+
+	m := &testMetrics{}
+	m.On("PacketDropped", DropMalformed, -1).Return().Once()
+
+	s := Server{Handler: &testHandler{}, Metrics: m}
+	s.Serve(pc)
+
+	m.AssertExpectations(t)
+}
@@ -0,0 +1,255 @@
+package dhcp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+var (
+	ErrInvalidRelayAgentInfo = errors.New("dhcp4: invalid relay agent information option")
+	ErrUnrelayedAgentInfo    = errors.New("dhcp4: relay agent information option in request that was not relayed")
+)
+
+// Sub-options of the Relay Agent Information option (RFC3046, section 2.0,
+// RFC4243, RFC5010 and RFC5107).
+const (
+	RelayAgentCircuitID        = uint8(1)
+	RelayAgentRemoteID         = uint8(2)
+	RelayAgentVendorSpecific   = uint8(9)
+	RelayAgentFlags            = uint8(10)
+	RelayAgentServerIDOverride = uint8(11)
+)
+
+// RelayAgentFlagUnicast is the unicast (U) bit of the Flags sub-option: it is
+// set if the relay agent received the request as a unicast packet, and clear
+// if it received it as a broadcast (RFC5010, section 2).
+const RelayAgentFlagUnicast = byte(0x80)
+
+// RelayAgentSubOption is a sub-option of the Relay Agent Information option.
+type RelayAgentSubOption struct {
+	Code uint8
+	Data []byte
+}
+
+// RelayAgentInfo is the value of the Relay Agent Information option (82): a
+// list of sub-options, in the order they appear in the option. Relay agents
+// that are behind other relay agents each append their sub-options, so a code
+// may appear more than once.
+type RelayAgentInfo []RelayAgentSubOption
+
+// Bytes returns the encoded sub-options.
+func (info RelayAgentInfo) Bytes() []byte {
+	b := bytes.Buffer{}
+	for _, o := range info {
+		b.WriteByte(o.Code)
+		b.WriteByte(byte(len(o.Data)))
+		b.Write(o.Data)
+	}
+
+	return b.Bytes()
+}
+
+// Get returns the data of the first sub-option with the specified code.
+func (info RelayAgentInfo) Get(code uint8) ([]byte, bool) {
+	for _, o := range info {
+		if o.Code == code {
+			return o.Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// ServerIDOverride returns the address of the Server Identifier Override
+// sub-option (RFC5107), if present and valid.
+func (info RelayAgentInfo) ServerIDOverride() (net.IP, bool) {
+	v, ok := info.Get(RelayAgentServerIDOverride)
+	if !ok || len(v) != 4 {
+		return nil, false
+	}
+
+	return net.IP(v), true
+}
+
+// Flags returns the flags of the Flags sub-option (RFC5010), e.g.
+// RelayAgentFlagUnicast, if present and valid.
+func (info RelayAgentInfo) Flags() (byte, bool) {
+	v, ok := info.Get(RelayAgentFlags)
+	if !ok || len(v) != 1 {
+		return 0, false
+	}
+
+	return v[0], true
+}
+
+// SetFlags sets the Flags sub-option (RFC5010), replacing the first Flags
+// sub-option or, if there is none, appending one.
+func (info *RelayAgentInfo) SetFlags(flags byte) {
+	for i, o := range *info {
+		if o.Code == RelayAgentFlags {
+			(*info)[i].Data = []byte{flags}
+			return
+		}
+	}
+
+	*info = append(*info, RelayAgentSubOption{Code: RelayAgentFlags, Data: []byte{flags}})
+}
+
+// RelayVendorData is the data of one enterprise in the Vendor-Specific
+// sub-option (RFC4243).
+type RelayVendorData struct {
+	Enterprise uint32
+	Data       []byte
+}
+
+// VendorSpecific decodes the Vendor-Specific sub-option, which holds data for
+// one or more enterprises, identified by their IANA enterprise number. It
+// returns nil if the sub-option is not present.
+func (info RelayAgentInfo) VendorSpecific() ([]RelayVendorData, error) {
+	v, ok := info.Get(RelayAgentVendorSpecific)
+	if !ok {
+		return nil, nil
+	}
+
+	var vendors []RelayVendorData
+	for len(v) > 0 {
+		if len(v) < 5 || len(v) < 5+int(v[4]) {
+			return nil, ErrInvalidRelayAgentInfo
+		}
+
+		vendors = append(vendors, RelayVendorData{
+			Enterprise: binary.BigEndian.Uint32(v),
+			Data:       v[5 : 5+int(v[4])],
+		})
+		v = v[5+int(v[4]):]
+	}
+
+	return vendors, nil
+}
+
+// GetRelayAgentInfo gets the sub-options of the Relay Agent Information
+// option. It returns nil if the option is not set. Like top-level options,
+// pad octets (0) are skipped and an end octet (255) ends the sub-options:
+// RFC3046 reserves both codes, and some relay agents use them as in the
+// options field.
+func (om OptionMap) GetRelayAgentInfo() (RelayAgentInfo, error) {
+	v, ok := om.GetOption(OptionRelayAgentInformation)
+	if !ok {
+		return nil, nil
+	}
+
+	var info RelayAgentInfo
+	for len(v) > 0 {
+		if Option(v[0]) == OptionPad {
+			v = v[1:]
+			continue
+		}
+		if Option(v[0]) == OptionEnd {
+			break
+		}
+
+		if len(v) < 2 || len(v) < 2+int(v[1]) {
+			return nil, ErrInvalidRelayAgentInfo
+		}
+
+		info = append(info, RelayAgentSubOption{
+			Code: v[0],
+			Data: append([]byte(nil), v[2:2+int(v[1])]...),
+		})
+		v = v[2+int(v[1]):]
+	}
+
+	return info, nil
+}
+
+// SetRelayAgentInfo sets the Relay Agent Information option, without end
+// octet, which RFC3046 doesn't define. It returns ErrInvalidRelayAgentInfo if
+// a sub-option is longer than 255 octets, or has the pad or end code.
+func (om OptionMap) SetRelayAgentInfo(info RelayAgentInfo) error {
+	for _, o := range info {
+		if len(o.Data) > 255 || Option(o.Code) == OptionPad || Option(o.Code) == OptionEnd {
+			return ErrInvalidRelayAgentInfo
+		}
+	}
+
+	om.SetOption(OptionRelayAgentInformation, info.Bytes())
+	return nil
+}
+
+// AppendRelayAgentInfo appends the sub-options in info to the Relay Agent
+// Information option of p, which is added if p doesn't have it yet. The
+// sub-options added by relay agents closer to the client are preserved. If
+// the resulting option exceeds 255 octets, it is split into multiple options
+// when the packet is serialized (RFC3396).
+func AppendRelayAgentInfo(p *Packet, info RelayAgentInfo) error {
+	chain, err := p.GetRelayAgentInfo()
+	if err != nil {
+		return err
+	}
+
+	return p.SetRelayAgentInfo(append(chain, info...))
+}
+
+// StripRelayAgentInfo removes the Relay Agent Information option from p. A
+// relay agent does this before forwarding a reply to the client (RFC3046,
+// section 2.1.1).
+func StripRelayAgentInfo(p *Packet) {
+	delete(p.OptionMap, OptionRelayAgentInformation)
+}
+
+// ServerIDOverride returns the address in the Server Identifier Override
+// sub-option of the Relay Agent Information option of p, if any. A relay agent
+// adds it to make clients use the relay agent's address as server identifier,
+// so that they unicast renewals to the relay agent instead of the server. The
+// server must then use it as Server Identifier in replies, and accept requests
+// that identify it (RFC5107, section 4). Replies are still sent to 'giaddr'.
+func (p *Packet) ServerIDOverride() (net.IP, bool) {
+	info, err := p.GetRelayAgentInfo()
+	if err != nil {
+		return nil, false
+	}
+
+	return info.ServerIDOverride()
+}
+
+// RelayAgentInfoPolicy defines how a server handles requests that have a Relay
+// Agent Information option, but were not relayed ('giaddr' is zero), see
+// Server.UnrelayedAgentInfo.
+type RelayAgentInfoPolicy int
+
+const (
+	// RelayAgentInfoDrop drops the request.
+	RelayAgentInfoDrop RelayAgentInfoPolicy = iota
+
+	// RelayAgentInfoStrip removes the option from the request, and passes
+	// the request to the handler, which then doesn't echo the option.
+	RelayAgentInfoStrip
+
+	// RelayAgentInfoTrust passes the request to the handler unchanged.
+	RelayAgentInfoTrust
+)
+
+// checkRelayAgentInfo returns ErrUnrelayedAgentInfo if request p has a Relay
+// Agent Information option but was not relayed, and the server doesn't trust
+// it, after stripping the option if the policy says so.
+func (s *Server) checkRelayAgentInfo(p *Packet) error {
+	if s.UnrelayedAgentInfo == RelayAgentInfoTrust {
+		return nil
+	}
+
+	if ip := p.GetGIAddr(); !ip.Equal(net.IPv4zero) {
+		return nil
+	}
+
+	if _, ok := p.GetOption(OptionRelayAgentInformation); !ok {
+		return nil
+	}
+
+	if s.UnrelayedAgentInfo == RelayAgentInfoStrip {
+		StripRelayAgentInfo(p)
+	}
+
+	return ErrUnrelayedAgentInfo
+}
@@ -0,0 +1,230 @@
+package dhcp4
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAppendRelayAgentInfo(t *testing.T) {
+	p := NewPacket(BootRequest)
+
+	inner := RelayAgentInfo{
+		{Code: RelayAgentCircuitID, Data: []byte("eth0")},
+	}
+	outer := RelayAgentInfo{
+		{Code: RelayAgentCircuitID, Data: []byte("ge-0/0/1")},
+		{Code: RelayAgentRemoteID, Data: []byte{1, 2, 3}},
+	}
+
+	assert.NoError(t, AppendRelayAgentInfo(&p, inner))
+	assert.NoError(t, AppendRelayAgentInfo(&p, outer))
+
+	v, _ := p.GetOption(OptionRelayAgentInformation)
+	assert.Equal(t, append(inner.Bytes(), outer.Bytes()...), v)
+
+	info, err := p.GetRelayAgentInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, append(inner, outer...), info)
+
+	StripRelayAgentInfo(&p)
+	_, ok := p.GetOption(OptionRelayAgentInformation)
+	assert.False(t, ok)
+}
+
+func TestAppendRelayAgentInfoLongChain(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeDiscover)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, AppendRelayAgentInfo(&p, RelayAgentInfo{{Code: RelayAgentRemoteID, Data: make([]byte, 100)}}))
+	}
+
+	b, err := PacketToBytes(p, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	q, err := PacketFromBytes(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	info, err := q.GetRelayAgentInfo()
+	assert.NoError(t, err)
+	assert.Len(t, info, 3)
+}
+
+func TestGetRelayAgentInfoInvalid(t *testing.T) {
+	om := make(OptionMap)
+	om.SetOption(OptionRelayAgentInformation, []byte{1, 4, 'e', 't'})
+
+	_, err := om.GetRelayAgentInfo()
+	assert.Equal(t, ErrInvalidRelayAgentInfo, err)
+	assert.Equal(t, ErrInvalidRelayAgentInfo, AppendRelayAgentInfo(&Packet{OptionMap: om}, nil))
+	assert.Equal(t, ErrInvalidRelayAgentInfo, om.SetRelayAgentInfo(RelayAgentInfo{{Data: make([]byte, 256)}}))
+}
+
+func TestRelayAgentInfoServerIDOverride(t *testing.T) {
+	p := NewPacket(BootRequest)
+	_, ok := p.ServerIDOverride()
+	assert.False(t, ok)
+
+	p.SetRelayAgentInfo(RelayAgentInfo{
+		{Code: RelayAgentCircuitID, Data: []byte("eth0")},
+		{Code: RelayAgentServerIDOverride, Data: []byte{10, 1, 0, 1}},
+	})
+	ip, ok := p.ServerIDOverride()
+	assert.True(t, ok)
+	assert.Equal(t, net.IP{10, 1, 0, 1}, ip)
+
+	// Invalid length
+	p.SetRelayAgentInfo(RelayAgentInfo{{Code: RelayAgentServerIDOverride, Data: []byte{10, 1, 0}}})
+	_, ok = p.ServerIDOverride()
+	assert.False(t, ok)
+}
+
+func TestRelayAgentInfoVendorSpecific(t *testing.T) {
+	info := RelayAgentInfo{{Code: RelayAgentVendorSpecific, Data: []byte{
+		0, 0, 0x0d, 0xe9, 2, 'a', 'b',
+		0, 0, 0, 9, 0,
+	}}}
+
+	vendors, err := info.VendorSpecific()
+	assert.NoError(t, err)
+	assert.Equal(t, []RelayVendorData{
+		{Enterprise: 3561, Data: []byte("ab")},
+		{Enterprise: 9, Data: []byte{}},
+	}, vendors)
+
+	info[0].Data = []byte{0, 0, 0, 9, 3, 'a'}
+	_, err = info.VendorSpecific()
+	assert.Equal(t, ErrInvalidRelayAgentInfo, err)
+
+	vendors, err = RelayAgentInfo{}.VendorSpecific()
+	assert.NoError(t, err)
+	assert.Nil(t, vendors)
+}
+
+func TestRelayAgentInfoFlags(t *testing.T) {
+	info := RelayAgentInfo{{Code: RelayAgentCircuitID, Data: []byte("eth0")}}
+
+	_, ok := info.Flags()
+	assert.False(t, ok)
+
+	info.SetFlags(RelayAgentFlagUnicast)
+	flags, ok := info.Flags()
+	assert.True(t, ok)
+	assert.Equal(t, RelayAgentFlagUnicast, flags)
+	assert.Equal(t, []byte{1, 4, 'e', 't', 'h', '0', 10, 1, 0x80}, info.Bytes())
+
+	// Replaced, not appended
+	info.SetFlags(0)
+	assert.Len(t, info, 2)
+	flags, ok = info.Flags()
+	assert.True(t, ok)
+	assert.Equal(t, byte(0), flags)
+
+	// Round trip through the option
+	om := make(OptionMap)
+	assert.NoError(t, om.SetRelayAgentInfo(info))
+	got, err := om.GetRelayAgentInfo()
+	assert.NoError(t, err)
+	flags, ok = got.Flags()
+	assert.True(t, ok)
+	assert.Equal(t, byte(0), flags)
+
+	// Invalid length
+	info = RelayAgentInfo{{Code: RelayAgentFlags, Data: []byte{0x80, 0}}}
+	_, ok = info.Flags()
+	assert.False(t, ok)
+}
+
+func TestGetRelayAgentInfoTerminators(t *testing.T) {
+	om := make(OptionMap)
+
+	// Pad octets are skipped, and the end octet ends the sub-options
+	om.SetOption(OptionRelayAgentInformation, []byte{0, 1, 2, 'e', 't', 0, 0, 2, 1, 9, 255, 1, 1, 'x'})
+	info, err := om.GetRelayAgentInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, RelayAgentInfo{
+		{Code: RelayAgentCircuitID, Data: []byte("et")},
+		{Code: RelayAgentRemoteID, Data: []byte{9}},
+	}, info)
+
+	// Without end octet
+	om.SetOption(OptionRelayAgentInformation, []byte{1, 2, 'e', 't', 0})
+	info, err = om.GetRelayAgentInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, RelayAgentInfo{{Code: RelayAgentCircuitID, Data: []byte("et")}}, info)
+
+	// The encoding has neither, and the codes can't be set
+	assert.NoError(t, om.SetRelayAgentInfo(info))
+	v, _ := om.GetOption(OptionRelayAgentInformation)
+	assert.Equal(t, []byte{1, 2, 'e', 't'}, v)
+
+	assert.Equal(t, ErrInvalidRelayAgentInfo, om.SetRelayAgentInfo(RelayAgentInfo{{Code: 0, Data: []byte{1}}}))
+	assert.Equal(t, ErrInvalidRelayAgentInfo, om.SetRelayAgentInfo(RelayAgentInfo{{Code: 255}}))
+}
+
+func TestServerUnrelayedAgentInfo(t *testing.T) {
+	info := []byte{RelayAgentCircuitID, 4, 'e', 't', 'h', '0'}
+	unrelayed := PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType:           {byte(MessageTypeDiscover)},
+		OptionRelayAgentInformation: info,
+	}}.Bytes()
+	relayed := PacketSpec{GIAddr: net.IPv4(10, 0, 0, 1), Options: map[Option][]byte{
+		OptionDHCPMsgType:           {byte(MessageTypeDiscover)},
+		OptionRelayAgentInformation: info,
+	}}.Bytes()
+
+	serve := func(policy RelayAgentInfoPolicy) ([]*Packet, []error, *testMetrics) {
+		pc := &testPacketConn{}
+		pc.ReadSuccess(unrelayed)
+		pc.ReadSuccess(relayed)
+		pc.ReadError(io.EOF)
+
+		m := &testMetrics{}
+		m.On("PacketDropped", mock.Anything, mock.Anything).Return()
+		m.On("PacketReceived", mock.Anything, mock.Anything).Return()
+
+		var handled []*Packet
+		var errs []error
+		s := &Server{
+			Handler:            HandlerFunc(func(w ReplyWriter, p *Packet) { q := p.Clone(); handled = append(handled, &q) }),
+			ErrorHandler:       func(p *Packet, err error) { errs = append(errs, err) },
+			Metrics:            m,
+			UnrelayedAgentInfo: policy,
+		}
+		s.Serve(pc)
+		return handled, errs, m
+	}
+
+	// Dropped by default; relayed requests are unaffected
+	handled, errs, m := serve(RelayAgentInfoDrop)
+	assert.Len(t, handled, 1)
+	assert.Equal(t, []error{ErrUnrelayedAgentInfo}, errs)
+	m.AssertCalled(t, "PacketDropped", DropRelayInfo, -1)
+
+	// Stripped
+	handled, errs, m = serve(RelayAgentInfoStrip)
+	if assert.Len(t, handled, 2) {
+		_, ok := handled[0].GetOption(OptionRelayAgentInformation)
+		assert.False(t, ok)
+		_, ok = handled[1].GetOption(OptionRelayAgentInformation)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, []error{ErrUnrelayedAgentInfo}, errs)
+	m.AssertNotCalled(t, "PacketDropped", DropRelayInfo, -1)
+
+	// Trusted
+	handled, errs, _ = serve(RelayAgentInfoTrust)
+	if assert.Len(t, handled, 2) {
+		_, ok := handled[0].GetOption(OptionRelayAgentInformation)
+		assert.True(t, ok)
+	}
+	assert.Empty(t, errs)
+}
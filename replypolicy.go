@@ -0,0 +1,45 @@
+package dhcp4
+
+// ReplyPolicy adds options to replies that RFC2131 doesn't allow in them, for
+// clients that don't work without. This is non-standard, and only meant as an
+// escape hatch for broken clients; standard clients may reject such replies.
+// For example, some management clients expect an IP Address Lease Time option
+// (51) in the DHCPACK to a DHCPINFORM, which must not have one:
+//
+//	s.ReplyPolicies = map[dhcp4.MessageType]dhcp4.ReplyPolicy{
+//		dhcp4.MessageTypeInform: {Include: leaseTime},
+//	}
+type ReplyPolicy struct {
+	// Match selects the requests the policy applies to, e.g. by vendor class.
+	// If nil, it applies to all requests.
+	Match func(req *Packet) bool
+
+	// Include lists the options added to the reply, if it doesn't have them
+	// already. They are added after the reply is validated.
+	Include OptionMap
+}
+
+// applyReplyPolicy adds the options of the server's reply policy for the
+// request type of reply r, if any (see ReplyPolicy).
+func (s *Server) applyReplyPolicy(r Reply) {
+	if s == nil || s.ReplyPolicies == nil {
+		return
+	}
+
+	msg := r.Message()
+	policy, ok := s.ReplyPolicies[msg.GetMessageType()]
+	if !ok || (policy.Match != nil && !policy.Match(msg)) {
+		return
+	}
+
+	rep := r.Reply()
+	for o, v := range policy.Include {
+		if rep != nil {
+			if _, ok := rep.GetOption(o); ok {
+				continue
+			}
+		}
+
+		r.SetOption(o, v)
+	}
+}
@@ -0,0 +1,50 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServerReplyPolicies(t *testing.T) {
+	leaseTime := make(OptionMap)
+	leaseTime.SetDuration(OptionAddressTime, time.Hour)
+
+	s := &Server{
+		ReplyPolicies: map[MessageType]ReplyPolicy{
+			MessageTypeInform: {
+				Match:   func(req *Packet) bool { return req.GetCHAddr()[5] == 1 },
+				Include: leaseTime,
+			},
+		},
+	}
+
+	inform := func(mac net.HardwareAddr) *Packet {
+		req := NewPacket(BootRequest)
+		req.SetMessageType(MessageTypeInform)
+		req.SetCHAddr(mac)
+		req.SetCIAddr(net.IPv4(10, 0, 0, 5))
+		return &req
+	}
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: s}
+
+	for i, mac := range []net.HardwareAddr{{2, 0, 0, 0, 0, 1}, {2, 0, 0, 0, 0, 2}} {
+		ack := CreateAck(inform(mac))
+		ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+		if !assert.NoError(t, rw.WriteReply(&ack)) {
+			return
+		}
+
+		rep, err := PacketFromBytes(pw.Calls[i].Arguments.Get(0).([]byte))
+		if assert.NoError(t, err) {
+			_, ok := rep.GetOption(OptionAddressTime)
+			assert.Equal(t, i == 0, ok, "lease time for %s", mac)
+		}
+	}
+}
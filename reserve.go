@@ -0,0 +1,150 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrAddressReserved = errors.New("dhcp4: address reserved for another client")
+)
+
+// ReserveOption configures a reservation, see Reserve.
+type ReserveOption func(r *reservation)
+
+// reservation holds the configuration of a reservation.
+type reservation struct {
+	options OptionMap
+}
+
+// WithOptions sets options for the reserved client that override the options
+// of the server and the client's class, e.g. a specific boot file or router.
+// SimpleServer merges them into its replies to the client, see
+// SimpleServer.Options for the precedence.
+func WithOptions(opts OptionMap) ReserveOption {
+	return func(r *reservation) {
+		r.options = opts
+	}
+}
+
+// Reserve pins address ip to the client with identifier clientID: Allocate
+// and AllocateIP only lease ip to the client, and never lease it to another
+// client, even after the client's lease expired. The address may be outside
+// the pool's ranges, e.g. in a block carved out for static addresses. A client
+// has at most one reservation; reserving again moves it, and replaces its
+// options (see WithOptions).
+//
+// If ip is currently leased to another client, Reserve returns
+// ErrAddressInUse, unless EvictForReservation is set, in which case the other
+// client's lease is dropped, and the client gets a DHCPNAK when it renews. It
+// returns ErrAddressReserved if ip is reserved for another client.
+func (p *LeasePool) Reserve(clientID []byte, ip net.IP, opts ...ReserveOption) error {
+	v, ok := ipToUint32(ip)
+	if !ok {
+		return ErrInvalidAddress
+	}
+
+	var r reservation
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.reserved[v]; ok && id != string(clientID) {
+		return ErrAddressReserved
+	}
+
+	if l, ok := p.leases[v]; ok && string(l.ClientID) != string(clientID) {
+		if !p.EvictForReservation {
+			return ErrAddressInUse
+		}
+
+		p.drop(v)
+	}
+
+	if p.reserved == nil {
+		p.reserved = make(map[uint32]string)
+		p.reservations = make(map[string]uint32)
+	}
+
+	if old, ok := p.reservations[string(clientID)]; ok {
+		delete(p.reserved, old)
+	}
+
+	p.reserved[v] = string(clientID)
+	p.reservations[string(clientID)] = v
+
+	delete(p.reservedOptions, string(clientID))
+	if len(r.options) > 0 {
+		if p.reservedOptions == nil {
+			p.reservedOptions = make(map[string]OptionMap)
+		}
+
+		// Copied, so that the caller can't modify them while they are used
+		o := make(OptionMap, len(r.options))
+		for k, v := range r.options {
+			o[k] = append([]byte(nil), v...)
+		}
+		p.reservedOptions[string(clientID)] = o
+	}
+
+	return nil
+}
+
+// Unreserve removes the reservation of the client with identifier clientID,
+// if any. Its lease, if any, lasts until it expires or is released.
+func (p *LeasePool) Unreserve(clientID []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if v, ok := p.reservations[string(clientID)]; ok {
+		delete(p.reserved, v)
+		delete(p.reservations, string(clientID))
+		delete(p.reservedOptions, string(clientID))
+	}
+}
+
+// Reservation returns the address reserved for the client with identifier
+// clientID, if any.
+func (p *LeasePool) Reservation(clientID []byte) (net.IP, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, ok := p.reservations[string(clientID)]
+	if !ok {
+		return nil, false
+	}
+
+	return uint32ToIP(v), true
+}
+
+// ReservedOptions returns the options of the reservation of the client with
+// identifier clientID (see WithOptions), or nil if it has none. The options
+// must not be modified.
+func (p *LeasePool) ReservedOptions(clientID []byte) OptionMap {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.reservedOptions[string(clientID)]
+}
+
+// drop removes the lease of address v, and its indexes. The caller must hold
+// the lock.
+func (p *LeasePool) drop(v uint32) {
+	l, ok := p.leases[v]
+	if !ok {
+		return
+	}
+
+	if l.ClientID != nil {
+		if w, ok := p.byClient[string(l.ClientID)]; ok && w == v {
+			delete(p.byClient, string(l.ClientID))
+		}
+	}
+	p.unindexMAC(v)
+	delete(p.leases, v)
+	delete(p.offered, v)
+	delete(p.lapsed, v)
+}
@@ -0,0 +1,90 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasePoolReserve(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	reserved := net.IPv4(10, 0, 0, 1)
+
+	assert.NoError(t, p.Reserve([]byte("a"), reserved))
+	assert.Equal(t, ErrAddressReserved, p.Reserve([]byte("b"), reserved))
+	ip, ok := p.Reservation([]byte("a"))
+	assert.True(t, ok)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, ip)
+
+	// Excluded from dynamic allocation
+	l, err := p.Allocate([]byte("b"), reserved, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 2}, l.IP)
+	_, err = p.AllocateIP([]byte("b"), reserved, time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+
+	// The client always gets its reserved address, even after expiry
+	l, err = p.Allocate([]byte("a"), net.IPv4(10, 0, 0, 3), -time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, l.IP)
+	_, err = p.Allocate([]byte("c"), reserved, time.Hour)
+	assert.NoError(t, err)
+	l, err = p.AllocateIP([]byte("a"), reserved, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, l.IP)
+	_, err = p.AllocateIP([]byte("a"), net.IPv4(10, 0, 0, 3), time.Hour)
+	assert.Equal(t, ErrAddressInUse, err)
+
+	// Outside the pool's ranges
+	assert.NoError(t, p.Reserve([]byte("d"), net.IPv4(10, 0, 1, 1)))
+	l, err = p.Allocate([]byte("d"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{10, 0, 1, 1}, l.IP)
+
+	p.Unreserve([]byte("a"))
+	_, ok = p.Reservation([]byte("a"))
+	assert.False(t, ok)
+}
+
+func TestLeasePoolReserveConflict(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+
+	l, err := p.Allocate([]byte("a"), nil, time.Hour)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrAddressInUse, p.Reserve([]byte("b"), l.IP))
+
+	p.EvictForReservation = true
+	assert.NoError(t, p.Reserve([]byte("b"), l.IP))
+	_, ok := p.Lookup([]byte("a"))
+	assert.False(t, ok)
+
+	l2, err := p.Allocate([]byte("b"), nil, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, l.IP, l2.IP)
+}
+
+func TestLeasePoolReserveWithOptions(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+	id := []byte("a")
+
+	opts := make(OptionMap)
+	opts.SetString(OptionBootfileName, "host-a.efi")
+	assert.NoError(t, p.Reserve(id, net.IPv4(10, 0, 0, 10), WithOptions(opts)))
+
+	// Copied
+	opts.SetString(OptionBootfileName, "other.efi")
+	name, _ := p.ReservedOptions(id).GetString(OptionBootfileName)
+	assert.Equal(t, "host-a.efi", name)
+	assert.Nil(t, p.ReservedOptions([]byte("b")))
+
+	// Reserving again replaces them, unreserving drops them
+	assert.NoError(t, p.Reserve(id, net.IPv4(10, 0, 0, 11)))
+	assert.Nil(t, p.ReservedOptions(id))
+
+	assert.NoError(t, p.Reserve(id, net.IPv4(10, 0, 0, 11), WithOptions(opts)))
+	p.Unreserve(id)
+	assert.Nil(t, p.ReservedOptions(id))
+}
@@ -0,0 +1,214 @@
+package dhcp4
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var (
+	ErrInvalidRoute = errors.New("dhcp4: invalid classless static route")
+)
+
+// OptionMSClasslessStaticRoute is the option used by Windows clients for
+// classless static routes, before they supported option 121. Its value has the
+// same encoding.
+const OptionMSClasslessStaticRoute = Option(249)
+
+// Route is a classless static route.
+type Route struct {
+	Dest    *net.IPNet
+	Gateway net.IP
+}
+
+// RouteOptionCodesFor returns the options to send classless static routes in,
+// to a client with vendor class identifier vendorClass: option 249 for
+// Microsoft clients (vendor class starting with "MSFT"), and option 121 for
+// others. See RouteOptionMode to send both, or either regardless of vendor
+// class.
+func RouteOptionCodesFor(vendorClass string) []Option {
+	if strings.HasPrefix(vendorClass, "MSFT") {
+		return []Option{OptionMSClasslessStaticRoute}
+	}
+
+	return []Option{OptionClasslessStaticRouteOption}
+}
+
+// RouteOptionMode defines the options a server sends classless static routes
+// in, see SetRoutesMode.
+type RouteOptionMode int
+
+const (
+	// RouteOptionsByVendorClass sends routes in the options
+	// RouteOptionCodesFor returns for the vendor class of the client.
+	RouteOptionsByVendorClass RouteOptionMode = iota
+
+	// RouteOptionsClassless sends routes in option 121 only.
+	RouteOptionsClassless
+
+	// RouteOptionsMS sends routes in option 249 only.
+	RouteOptionsMS
+
+	// RouteOptionsBoth sends routes in both options 121 and 249, for networks
+	// where no client chokes on the option it doesn't know.
+	RouteOptionsBoth
+)
+
+// Codes returns the options to send classless static routes in, to a
+// client with vendor class identifier vendorClass.
+func (m RouteOptionMode) Codes(vendorClass string) []Option {
+	switch m {
+	case RouteOptionsClassless:
+		return []Option{OptionClasslessStaticRouteOption}
+	case RouteOptionsMS:
+		return []Option{OptionMSClasslessStaticRoute}
+	case RouteOptionsBoth:
+		return []Option{OptionClasslessStaticRouteOption, OptionMSClasslessStaticRoute}
+	}
+
+	return RouteOptionCodesFor(vendorClass)
+}
+
+// GetClasslessRoutes gets the routes in option o, which is encoded as the
+// Classless Static Route option (RFC3442).
+func (om OptionMap) GetClasslessRoutes(o Option) ([]Route, error) {
+	v, ok := om.GetOption(o)
+	if !ok {
+		return nil, nil
+	}
+
+	var routes []Route
+	for len(v) > 0 {
+		ones := int(v[0])
+		n := (ones + 7) / 8
+		if ones > 32 || len(v) < 1+n+4 {
+			return nil, ErrInvalidRoute
+		}
+
+		dest := make(net.IP, 4)
+		copy(dest, v[1:1+n])
+
+		routes = append(routes, Route{
+			Dest:    &net.IPNet{IP: dest, Mask: net.CIDRMask(ones, 32)},
+			Gateway: net.IP(append([]byte(nil), v[1+n:1+n+4]...)),
+		})
+		v = v[1+n+4:]
+	}
+
+	return routes, nil
+}
+
+// SetClasslessRoutes sets option o to routes, encoded as the Classless Static
+// Route option (RFC3442). Note that a client that receives option 121 ignores
+// the Router option (3); include a route for 0.0.0.0/0 to set its default
+// gateway.
+func (om OptionMap) SetClasslessRoutes(o Option, routes []Route) error {
+	v, err := encodeClasslessRoutes(routes)
+	if err != nil {
+		return err
+	}
+
+	om.SetOption(o, v)
+	return nil
+}
+
+func encodeClasslessRoutes(routes []Route) ([]byte, error) {
+	var v []byte
+	for _, r := range routes {
+		if r.Dest == nil {
+			return nil, ErrInvalidRoute
+		}
+
+		dest, gw := r.Dest.IP.To4(), r.Gateway.To4()
+		ones, bits := r.Dest.Mask.Size()
+		if dest == nil || gw == nil || bits != 32 {
+			return nil, ErrInvalidRoute
+		}
+
+		v = append(v, byte(ones))
+		v = append(v, dest.Mask(r.Dest.Mask)[:(ones+7)/8]...)
+		v = append(v, gw...)
+	}
+
+	return v, nil
+}
+
+// SetRoutes sets the classless static routes of reply r, in the options
+// RouteOptionCodesFor returns for the vendor class of the client.
+func SetRoutes(r Reply, routes []Route) error {
+	return SetRoutesMode(r, routes, RouteOptionsByVendorClass)
+}
+
+// SetRoutesMode sets the classless static routes of reply r, in the options
+// mode selects for the vendor class of the client, e.g. in both options 121
+// and 249 with RouteOptionsBoth.
+func SetRoutesMode(r Reply, routes []Route, mode RouteOptionMode) error {
+	v, err := encodeClasslessRoutes(routes)
+	if err != nil {
+		return err
+	}
+
+	class, _ := r.Message().GetString(OptionClassID)
+	for _, o := range mode.Codes(class) {
+		r.SetOption(o, v)
+	}
+
+	return nil
+}
+
+// RouteConflictError is returned by GetClasslessRoutesMerged for routes to
+// the same destination with different gateways in options 121 and 249.
+// Conflicts are the routes of option 249 that were dropped.
+type RouteConflictError struct {
+	Conflicts []Route
+}
+
+func (e *RouteConflictError) Error() string {
+	dests := make([]string, len(e.Conflicts))
+	for i, r := range e.Conflicts {
+		dests[i] = r.Dest.String()
+	}
+
+	return "dhcp4: conflicting classless static routes in options 121 and 249: " + strings.Join(dests, ", ")
+}
+
+// GetClasslessRoutesMerged gets the classless static routes of options 121
+// and 249, e.g. to check the routes a server sent to a client. Routes in both
+// options are returned once, in the order of option 121, followed by the
+// routes only in option 249. Option 121 takes precedence for routes to the
+// same destination with different gateways: the routes are returned, with a
+// *RouteConflictError listing the dropped routes of option 249. It returns
+// ErrInvalidRoute if either option is malformed.
+func (om OptionMap) GetClasslessRoutesMerged() ([]Route, error) {
+	routes, err := om.GetClasslessRoutes(OptionClasslessStaticRouteOption)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := om.GetClasslessRoutes(OptionMSClasslessStaticRoute)
+	if err != nil {
+		return nil, err
+	}
+
+	gateways := make(map[string]net.IP, len(routes))
+	for _, r := range routes {
+		gateways[r.Dest.String()] = r.Gateway
+	}
+
+	var conflicts []Route
+	for _, r := range ms {
+		gw, ok := gateways[r.Dest.String()]
+		if !ok {
+			gateways[r.Dest.String()] = r.Gateway
+			routes = append(routes, r)
+		} else if !gw.Equal(r.Gateway) {
+			conflicts = append(conflicts, r)
+		}
+	}
+
+	if conflicts != nil {
+		return routes, &RouteConflictError{Conflicts: conflicts}
+	}
+
+	return routes, nil
+}
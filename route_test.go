@@ -0,0 +1,159 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRoutes() []Route {
+	_, def, _ := net.ParseCIDR("0.0.0.0/0")
+	_, a, _ := net.ParseCIDR("10.0.0.0/8")
+	_, b, _ := net.ParseCIDR("192.168.1.0/25")
+
+	return []Route{
+		{Dest: def, Gateway: net.IP{10, 0, 0, 1}},
+		{Dest: a, Gateway: net.IP{10, 0, 0, 2}},
+		{Dest: b, Gateway: net.IP{10, 0, 0, 3}},
+	}
+}
+
+func TestClasslessRoutes(t *testing.T) {
+	om := make(OptionMap)
+	assert.NoError(t, om.SetClasslessRoutes(OptionClasslessStaticRouteOption, testRoutes()))
+
+	// Examples from RFC3442, section 2
+	v, _ := om.GetOption(OptionClasslessStaticRouteOption)
+	assert.Equal(t, []byte{
+		0, 10, 0, 0, 1,
+		8, 10, 10, 0, 0, 2,
+		25, 192, 168, 1, 0, 10, 0, 0, 3,
+	}, v)
+
+	routes, err := om.GetClasslessRoutes(OptionClasslessStaticRouteOption)
+	assert.NoError(t, err)
+	assert.Equal(t, len(testRoutes()), len(routes))
+	for i, r := range testRoutes() {
+		assert.Equal(t, r.Dest.String(), routes[i].Dest.String())
+		assert.Equal(t, r.Gateway, routes[i].Gateway)
+	}
+
+	om.SetOption(OptionClasslessStaticRouteOption, []byte{24, 10, 0, 0, 10, 0})
+	_, err = om.GetClasslessRoutes(OptionClasslessStaticRouteOption)
+	assert.Equal(t, ErrInvalidRoute, err)
+
+	assert.Equal(t, ErrInvalidRoute, om.SetClasslessRoutes(OptionClasslessStaticRouteOption, []Route{{}}))
+}
+
+func TestRouteOptionCodesFor(t *testing.T) {
+	assert.Equal(t, []Option{OptionMSClasslessStaticRoute}, RouteOptionCodesFor("MSFT 5.0"))
+	assert.Equal(t, []Option{OptionClasslessStaticRouteOption}, RouteOptionCodesFor("PXEClient"))
+	assert.Equal(t, []Option{OptionClasslessStaticRouteOption}, RouteOptionCodesFor(""))
+}
+
+func TestSetRoutes(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	req.SetString(OptionClassID, "MSFT 5.0")
+
+	rep := CreateOffer(&req)
+	assert.NoError(t, SetRoutes(&rep, testRoutes()))
+
+	_, ok := rep.GetOption(OptionMSClasslessStaticRoute)
+	assert.True(t, ok)
+	_, ok = rep.GetOption(OptionClasslessStaticRouteOption)
+	assert.False(t, ok)
+}
+
+func TestRouteOptionModeCodes(t *testing.T) {
+	assert.Equal(t, []Option{OptionMSClasslessStaticRoute}, RouteOptionsByVendorClass.Codes("MSFT 5.0"))
+	assert.Equal(t, []Option{OptionClasslessStaticRouteOption}, RouteOptionsClassless.Codes("MSFT 5.0"))
+	assert.Equal(t, []Option{OptionMSClasslessStaticRoute}, RouteOptionsMS.Codes("PXEClient"))
+	assert.Equal(t, []Option{OptionClasslessStaticRouteOption, OptionMSClasslessStaticRoute}, RouteOptionsBoth.Codes(""))
+}
+
+func TestSetRoutesModeBoth(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	rep := CreateOffer(&req)
+	assert.NoError(t, SetRoutesMode(&rep, testRoutes(), RouteOptionsBoth))
+
+	// Both options have the same encoding
+	expected := []byte{
+		0, 10, 0, 0, 1,
+		8, 10, 10, 0, 0, 2,
+		25, 192, 168, 1, 0, 10, 0, 0, 3,
+	}
+	for _, o := range []Option{OptionClasslessStaticRouteOption, OptionMSClasslessStaticRoute} {
+		v, ok := rep.GetOption(o)
+		assert.True(t, ok, "option %d", o)
+		assert.Equal(t, expected, v, "option %d", o)
+	}
+
+	b, err := rep.ToBytes()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := PacketFromBytes(b)
+	if assert.NoError(t, err) {
+		routes, err := p.GetClasslessRoutesMerged()
+		assert.NoError(t, err)
+		assert.Len(t, routes, len(testRoutes()))
+	}
+}
+
+func TestClasslessRoutesMerged(t *testing.T) {
+	routes := testRoutes()
+	_, c, _ := net.ParseCIDR("172.16.0.0/12")
+
+	om := make(OptionMap)
+	assert.NoError(t, om.SetClasslessRoutes(OptionClasslessStaticRouteOption, routes[:2]))
+	assert.NoError(t, om.SetClasslessRoutes(OptionMSClasslessStaticRoute, []Route{
+		routes[1],
+		routes[2],
+	}))
+
+	// Duplicates are returned once
+	merged, err := om.GetClasslessRoutesMerged()
+	assert.NoError(t, err)
+	if assert.Len(t, merged, 3) {
+		for i, r := range routes {
+			assert.Equal(t, r.Dest.String(), merged[i].Dest.String())
+			assert.Equal(t, r.Gateway, merged[i].Gateway)
+		}
+	}
+
+	// Option 121 takes precedence on conflicts
+	conflict := Route{Dest: routes[1].Dest, Gateway: net.IP{10, 0, 0, 9}}
+	assert.NoError(t, om.SetClasslessRoutes(OptionMSClasslessStaticRoute, []Route{
+		conflict,
+		{Dest: c, Gateway: net.IP{10, 0, 0, 4}},
+	}))
+
+	merged, err = om.GetClasslessRoutesMerged()
+	if assert.IsType(t, &RouteConflictError{}, err) {
+		e := err.(*RouteConflictError)
+		if assert.Len(t, e.Conflicts, 1) {
+			assert.Equal(t, "10.0.0.0/8", e.Conflicts[0].Dest.String())
+			assert.Equal(t, net.IP{10, 0, 0, 9}, e.Conflicts[0].Gateway)
+		}
+		assert.Contains(t, err.Error(), "10.0.0.0/8")
+	}
+	if assert.Len(t, merged, 3) {
+		assert.Equal(t, net.IP{10, 0, 0, 2}, merged[1].Gateway)
+		assert.Equal(t, "172.16.0.0/12", merged[2].Dest.String())
+	}
+
+	// Either option alone
+	delete(om, OptionClasslessStaticRouteOption)
+	merged, err = om.GetClasslessRoutesMerged()
+	assert.NoError(t, err)
+	assert.Len(t, merged, 2)
+
+	om.SetOption(OptionClasslessStaticRouteOption, []byte{33})
+	_, err = om.GetClasslessRoutesMerged()
+	assert.Equal(t, ErrInvalidRoute, err)
+}
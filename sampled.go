@@ -0,0 +1,143 @@
+package dhcp4
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultSuppressedReportInterval is the interval a SampledErrorHandler
+// reports suppressed errors at, if it is created with an interval of 0.
+const DefaultSuppressedReportInterval = time.Minute
+
+// SuppressedErrors is passed to the error handler wrapped by a
+// SampledErrorHandler, with a nil packet, to summarize the errors it
+// suppressed since the previous summary.
+type SuppressedErrors struct {
+	Count  int
+	Period time.Duration
+}
+
+func (e *SuppressedErrors) Error() string {
+	return fmt.Sprintf("dhcp4: suppressed %d errors in the last %s", e.Count, e.Period)
+}
+
+// SampledErrorHandler wraps an error handler (see Server.ErrorHandler) to
+// report at most a fixed number of errors per second, e.g. while a broken
+// device or an attacker floods the server with malformed packets. Errors
+// beyond the rate are counted instead, and the count is reported to the
+// wrapped handler periodically as *SuppressedErrors, so that no error goes
+// unnoticed. Use its Handle method as the server's ErrorHandler:
+//
+//	sh := dhcp4.NewSampledErrorHandler(h, 10, time.Minute)
+//	defer sh.Close()
+//	s.ErrorHandler = sh.Handle
+type SampledErrorHandler struct {
+	h    func(p *Packet, err error)
+	rate int
+
+	mu         sync.Mutex
+	window     time.Time
+	reported   int
+	suppressed int
+	since      time.Time
+	clock      Clock
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSampledErrorHandler returns a handler that passes at most rate errors
+// per second to h, and reports the number of suppressed errors to h every
+// interval, if any were suppressed, or every DefaultSuppressedReportInterval
+// if interval is not positive. If h is nil, errors are logged, like the
+// server does without ErrorHandler. Close stops the periodic reports.
+func NewSampledErrorHandler(h func(p *Packet, err error), rate int, interval time.Duration) *SampledErrorHandler {
+	return NewSampledErrorHandlerWithClock(h, rate, interval, nil)
+}
+
+// NewSampledErrorHandlerWithClock returns a handler like
+// NewSampledErrorHandler, which measures the rate of errors and the
+// interval of reports with clk, or the time package if clk is nil.
+func NewSampledErrorHandlerWithClock(h func(p *Packet, err error), rate int, interval time.Duration, clk Clock) *SampledErrorHandler {
+	if h == nil {
+		h = logRequestError
+	}
+	if interval <= 0 {
+		interval = DefaultSuppressedReportInterval
+	}
+
+	sh := &SampledErrorHandler{
+		h:     h,
+		rate:  rate,
+		clock: clockOrReal(clk),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	sh.since = sh.clock.Now()
+
+	go sh.report(interval)
+	return sh
+}
+
+// Handle reports the error err about packet p (which may be nil) to the
+// wrapped handler, unless the rate of errors is exceeded.
+func (sh *SampledErrorHandler) Handle(p *Packet, err error) {
+	sh.mu.Lock()
+	now := sh.clock.Now()
+	if now.Sub(sh.window) >= time.Second {
+		sh.window = now
+		sh.reported = 0
+	}
+
+	ok := sh.reported < sh.rate
+	if ok {
+		sh.reported++
+	} else {
+		sh.suppressed++
+	}
+	sh.mu.Unlock()
+
+	if ok {
+		sh.h(p, err)
+	}
+}
+
+func (sh *SampledErrorHandler) report(interval time.Duration) {
+	defer close(sh.done)
+
+	for {
+		select {
+		case <-sh.clock.After(interval):
+			sh.flush()
+		case <-sh.stop:
+			return
+		}
+	}
+}
+
+// flush reports the errors suppressed since the previous report, if any.
+func (sh *SampledErrorHandler) flush() {
+	sh.mu.Lock()
+	now := sh.clock.Now()
+	e := &SuppressedErrors{Count: sh.suppressed, Period: now.Sub(sh.since)}
+	sh.suppressed = 0
+	sh.since = now
+	sh.mu.Unlock()
+
+	if e.Count > 0 {
+		sh.h(nil, e)
+	}
+}
+
+// Close stops the periodic reports, and reports the errors suppressed since
+// the last one. Closing the handler again has no effect.
+func (sh *SampledErrorHandler) Close() error {
+	sh.closeOnce.Do(func() {
+		close(sh.stop)
+		<-sh.done
+		sh.flush()
+	})
+	return nil
+}
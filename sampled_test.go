@@ -0,0 +1,84 @@
+package dhcp4
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampledErrorHandler(t *testing.T) {
+	var errs []error
+	clk := newFakeClock()
+	sh := NewSampledErrorHandlerWithClock(func(p *Packet, err error) { errs = append(errs, err) }, 2, time.Hour, clk)
+
+	for i := 0; i < 5; i++ {
+		sh.Handle(nil, ErrShortPacket)
+	}
+	assert.Equal(t, []error{ErrShortPacket, ErrShortPacket}, errs)
+
+	// The next second allows more errors
+	clk.Advance(time.Second)
+	sh.Handle(nil, ErrInvalidPacket)
+	assert.Len(t, errs, 3)
+
+	// The summary counts the suppressed errors
+	clk.Advance(time.Minute)
+	sh.flush()
+	if assert.Len(t, errs, 4) {
+		assert.Equal(t, &SuppressedErrors{Count: 3, Period: time.Minute + time.Second}, errs[3])
+		assert.Equal(t, "dhcp4: suppressed 3 errors in the last 1m1s", errs[3].Error())
+	}
+
+	// No summary without suppressed errors, including when closing
+	sh.flush()
+	assert.NoError(t, sh.Close())
+	assert.Len(t, errs, 4)
+}
+
+func TestSampledErrorHandlerReportsPeriodically(t *testing.T) {
+	errs := make(chan error, 1)
+	clk := newFakeClock()
+	sh := NewSampledErrorHandlerWithClock(func(p *Packet, err error) { errs <- err }, 0, time.Minute, clk)
+	defer sh.Close()
+
+	sh.Handle(nil, ErrShortPacket)
+	clk.waitTimers(t, 1)
+	clk.Advance(time.Minute)
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, &SuppressedErrors{Count: 1, Period: time.Minute}, err)
+	case <-time.After(time.Second):
+		t.Error("no report")
+	}
+}
+
+func TestSampledErrorHandlerCloseReports(t *testing.T) {
+	var errs []error
+	sh := NewSampledErrorHandler(func(p *Packet, err error) { errs = append(errs, err) }, 0, time.Hour)
+
+	sh.Handle(nil, ErrShortPacket)
+	assert.Empty(t, errs)
+
+	assert.NoError(t, sh.Close())
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 1, errs[0].(*SuppressedErrors).Count)
+	}
+}
+
+func TestSampledErrorHandlerDefaultInterval(t *testing.T) {
+	var errs []error
+	for _, interval := range []time.Duration{0, -time.Second} {
+		var sh *SampledErrorHandler
+		assert.NotPanics(t, func() {
+			sh = NewSampledErrorHandler(func(p *Packet, err error) { errs = append(errs, err) }, 0, interval)
+		})
+
+		// Closing again neither panics nor reports again
+		sh.Handle(nil, ErrShortPacket)
+		assert.NoError(t, sh.Close())
+		assert.NotPanics(t, func() { assert.NoError(t, sh.Close()) })
+	}
+	assert.Len(t, errs, 2)
+}
@@ -0,0 +1,587 @@
+package dhcp4
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// DefaultParameterList is the list of options included in replies to clients
+// that don't send a Parameter Request List, if the server doesn't define its
+// own list.
+var DefaultParameterList = []Option{
+	OptionSubnetMask,
+	OptionRouter,
+	OptionDomainServer,
+	OptionDomainName,
+	OptionAddressTime,
+}
+
+// RetryPolicy defines how often writing a reply is retried after a transient
+// error, such as ENOBUFS or EAGAIN. The delay before the n-th retry is
+// BaseDelay * 2^(n-1).
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy for servers that don't define their
+// own policy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  10 * time.Millisecond,
+}
+
+// Server defines parameters for running a DHCP server.
+// The zero value for Server is a valid configuration.
+type Server struct {
+	// Handler to invoke for every request.
+	Handler Handler
+
+	// DefaultParameters lists the options ApplyOptions includes in replies to
+	// clients that don't send a Parameter Request List (option 55). If nil,
+	// DefaultParameterList is used.
+	DefaultParameters []Option
+
+	// WriteRetry defines how often writing a reply is retried after a
+	// transient error. If nil, DefaultRetryPolicy is used.
+	WriteRetry *RetryPolicy
+
+	// Metrics receives events from the server, if not nil.
+	Metrics Metrics
+
+	// OnLeaseGranted is called after a DHCPACK in response to a DHCPREQUEST
+	// has been sent, if not nil. The Client FQDN option of the request is
+	// passed as fqdn, or nil if the client didn't send it; it tells whether
+	// the server should perform DNS updates for the lease (see ClientFQDN).
+	// The function is called from the goroutine writing the reply, and should
+	// not block.
+	OnLeaseGranted func(lease Lease, fqdn *ClientFQDN)
+
+	// EchoClientID copies the Client Identifier option (61) from requests
+	// into replies. RFC2131 forbids this, but RFC6842 requires it, and some
+	// clients and management tools expect it.
+	EchoClientID bool
+
+	// DisableRecover disables recovering from panics in the handler. By
+	// default, a panic is logged and the server continues serving (see
+	// Recover).
+	DisableRecover bool
+
+	// DontFragment controls the Don't Fragment (DF) bit of replies, if not
+	// nil. Large replies that exceed the path MTU are then either dropped
+	// instead of fragmented (true), or fragmented as needed (false). If nil,
+	// the operating system default is used. Setting it requires a PacketConn
+	// that implements DontFragmentSetter, which the PacketConn returned by
+	// NewPacketConn only does on Linux.
+	DontFragment *bool
+
+	// ServerIDs are the server identifiers of the server, starting with the
+	// primary identifier. Servers in an HA pair share a virtual identifier,
+	// which should be the primary one; they accept requests for any of their
+	// identifiers (see IsForServer). Replies without Server Identifier option
+	// get the primary identifier. As clients may ignore replies that are not
+	// sent from the identified address, set the source address of replies to
+	// the primary identifier as well (see SetInterfaceSource).
+	ServerIDs []net.IP
+
+	// Authoritative makes the server send a DHCPNAK to clients in the
+	// INIT-REBOOT state that request an address it doesn't recognize, as the
+	// authority for the network, like ISC dhcpd's "authoritative" statement.
+	// Otherwise, the server stays silent, so that another server can answer
+	// the client. See Server.ShouldNak.
+	Authoritative bool
+
+	// ErrorHandler is called for suspicious requests passed to the handler:
+	// ErrLinkLocalAddress, and ErrUnrelayedAgentInfo under
+	// RelayAgentInfoStrip. It is also called for dropped packets, with a nil
+	// packet for those dropped before parsing: ErrInterfaceNotAllowed,
+	// malformed packets (e.g. ErrShortPacket), and ErrUnrelayedAgentInfo
+	// under RelayAgentInfoDrop.
+	//
+	// If nil, the errors are logged. See SampledErrorHandler to limit the
+	// errors reported while the server is flooded with bad packets.
+	ErrorHandler func(p *Packet, err error)
+
+	// AllowedInterfaces are the indexes of the network interfaces to serve, if
+	// not nil; packets arriving on other interfaces are dropped. This guards
+	// against serving on an interface by accident, e.g. after a NIC was
+	// misconfigured. See InterfaceIndexes for interfaces by name. It requires
+	// a PacketConn that returns the interface index of packets, like the one
+	// returned by NewPacketConn; Serve returns ErrInterfaceIndexUnsupported
+	// on start if the PacketConn reports that it doesn't. Packets without
+	// interface index are dropped like packets on other interfaces.
+	AllowedInterfaces []int
+
+	// ReplyInterceptor is called for every reply written by a handler, if not
+	// nil, with the request it replies to. It can add, remove or modify
+	// options, e.g. to add options to every reply regardless of the handler,
+	// and returns the reply to send, or nil to drop it. It runs before the
+	// reply is validated, so it can add options the reply must have.
+	ReplyInterceptor func(req *Packet, r Reply) Reply
+
+	// DropForeignReleases drops DHCPRELEASE messages whose Server Identifier
+	// option doesn't match any of ServerIDs, without passing them to the
+	// handler. Clients send a DHCPRELEASE to the server that granted the
+	// lease; on a segment with several servers, honoring releases for other
+	// servers frees leases the server didn't grant.
+	DropForeignReleases bool
+
+	// UnrelayedAgentInfo defines how requests that have a Relay Agent
+	// Information option (82) and a zero 'giaddr' are handled. Clients don't
+	// add the option themselves, so by default these requests are dropped
+	// (DropRelayInfo) as spoofed, and reported to the ErrorHandler with
+	// ErrUnrelayedAgentInfo (RFC3046, section 2.1). Deployments whose edge
+	// switches add the option without relaying the request, e.g. with DHCP
+	// snooping, trust it with RelayAgentInfoTrust.
+	UnrelayedAgentInfo RelayAgentInfoPolicy
+
+	// ReplyPolicies are the non-standard options to include in replies, by
+	// the type of the request replied to. By default, replies only have the
+	// options RFC2131 allows. See ReplyPolicy.
+	ReplyPolicies map[MessageType]ReplyPolicy
+
+	// ReplyPort is the UDP port replies are sent to, if not zero, instead of
+	// the source port of the request, the server port (67) of relay agents,
+	// or the client port (68) for unicast replies to 'ciaddr'. It is only
+	// meant for test rigs and simulations, e.g. for servers exchanging
+	// messages on port 67, or on unprivileged ports; clients don't receive
+	// replies on other ports. The source port of replies is the local port of
+	// the PacketConn, see Listen.
+	ReplyPort int
+
+	// RelayPort is the UDP port replies to relayed requests are sent to on
+	// the relay agent ('giaddr'), if not zero. Relay agents listen on the
+	// server port (67), which is the standard (RFC2131, section 4.1) and the
+	// default; this is an interoperability workaround for the few relay
+	// agents that listen on another port. ReplyPort takes precedence.
+	RelayPort int
+
+	// Passthrough is called, if not nil, for packets the server doesn't
+	// process, instead of dropping them: packets that are not BOOTREQUEST, and
+	// requests that are not DHCPDISCOVER, DHCPREQUEST, DHCPDECLINE,
+	// DHCPRELEASE or DHCPINFORM, which are then not passed to the handler. It
+	// gets the raw packet, e.g. for a proxy to forward it; raw is reused after
+	// Passthrough returns. Malformed packets are still dropped.
+	Passthrough func(raw []byte, addr net.Addr, ifindex int)
+
+	// Clock, if not nil, provides the time requests are received at, and
+	// the delays between write retries, instead of the time package.
+	Clock Clock
+
+	mu       sync.RWMutex
+	sources  map[int]net.IP
+	events   chan Event
+	notReady bool
+}
+
+// SetReady sets whether the server is ready to serve requests. A server that
+// is not ready drops all packets (DropNotReady), e.g. until the lease
+// database it depends on is loaded, so that it doesn't lease addresses, or
+// send DHCPNAKs, without knowing the existing leases. Clients retransmit
+// their requests, which are served once the server is ready. A server is
+// ready unless SetReady(false) is called; call it before Serve to gate the
+// server from the start. It is safe to call while the server is serving.
+func (s *Server) SetReady(ready bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notReady = !ready
+}
+
+// Ready returns whether the server is ready to serve requests, see SetReady.
+func (s *Server) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.notReady
+}
+
+// SetInterfaceSource sets the source address for replies sent on the network
+// interface with index ifindex. Clients may ignore replies that are not sent
+// from the address in the Server Identifier option, which the kernel doesn't
+// necessarily pick on interfaces with multiple addresses. Setting the source
+// address requires a PacketConn that implements ControlMessageWriter. A nil
+// ip removes the source address for the interface.
+func (s *Server) SetInterfaceSource(ifindex int, ip net.IP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ip == nil {
+		delete(s.sources, ifindex)
+		return
+	}
+
+	if s.sources == nil {
+		s.sources = make(map[int]net.IP)
+	}
+
+	s.sources[ifindex] = ip
+}
+
+// interfaceSource returns the source address for replies sent on the network
+// interface with index ifindex, if one is set.
+func (s *Server) interfaceSource(ifindex int) net.IP {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sources[ifindex]
+}
+
+// writeRetry returns the retry policy for writing replies.
+func (s *Server) writeRetry() RetryPolicy {
+	if s == nil || s.WriteRetry == nil {
+		return DefaultRetryPolicy
+	}
+
+	return *s.WriteRetry
+}
+
+// relayPort returns the port replies to relayed requests are sent to.
+func (s *Server) relayPort() int {
+	if s == nil || s.RelayPort == 0 {
+		return ServerPort
+	}
+
+	return s.RelayPort
+}
+
+// replyPort returns the port replies are sent to, or 0 for the default.
+func (s *Server) replyPort() int {
+	if s == nil {
+		return 0
+	}
+
+	return s.ReplyPort
+}
+
+// metrics returns the server's metrics, or a no-op implementation.
+func (s *Server) metrics() Metrics {
+	if s == nil || s.Metrics == nil {
+		return nopMetrics{}
+	}
+
+	return s.Metrics
+}
+
+// handler returns the server's handler, wrapped to recover from panics unless
+// disabled.
+func (s *Server) handler() Handler {
+	if s.DisableRecover {
+		return s.Handler
+	}
+
+	return &recoverHandler{h: s.Handler, metrics: s.metrics()}
+}
+
+// PrimaryServerID returns the primary server identifier, or nil if the server
+// has no identifiers.
+func (s *Server) PrimaryServerID() net.IP {
+	if s == nil || len(s.ServerIDs) == 0 {
+		return nil
+	}
+
+	return s.ServerIDs[0]
+}
+
+// IsForServer returns whether the DHCPREQUEST p is meant for the server, using
+// the server's identifiers. See Packet.IsForServer.
+func (s *Server) IsForServer(p *Packet) bool {
+	return p.IsForServer(s.ServerIDs...)
+}
+
+// NewReplyBuilder returns a builder for a reply to req, with the server's
+// primary identifier.
+func (s *Server) NewReplyBuilder(req *Packet) *ReplyBuilder {
+	b := NewReplyBuilder(req)
+	if sid := s.PrimaryServerID(); sid != nil {
+		b.ServerID(sid)
+	}
+
+	return b
+}
+
+// requestError reports the error err about request p to the server's
+// ErrorHandler.
+func (s *Server) requestError(p *Packet, err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(p, err)
+		return
+	}
+
+	logRequestError(p, err)
+}
+
+// logRequestError logs the error err about request p, which may be nil.
+func logRequestError(p *Packet, err error) {
+	if p == nil {
+		clog.Warning(err)
+		return
+	}
+
+	clog.Warningf("%s from %s: %s", p.GetMessageType(), p.GetCHAddr(), err)
+}
+
+// interfaceAllowed returns whether the server serves the network interface
+// with index ifindex.
+func (s *Server) interfaceAllowed(ifindex int) bool {
+	if s.AllowedInterfaces == nil {
+		return true
+	}
+
+	for _, i := range s.AllowedInterfaces {
+		if i == ifindex {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interceptReply calls the server's ReplyInterceptor, if any.
+func (s *Server) interceptReply(r Reply) Reply {
+	if s == nil || s.ReplyInterceptor == nil {
+		return r
+	}
+
+	return s.ReplyInterceptor(r.Message(), r)
+}
+
+// setServerID sets the Server Identifier option of reply r to the primary
+// server identifier, if r doesn't have the option yet. A Server Identifier
+// Override sub-option in the request takes precedence (see
+// Packet.ServerIDOverride).
+func (s *Server) setServerID(r Reply) {
+	rep := r.Reply()
+	if rep == nil {
+		return
+	}
+
+	if ip, ok := r.Message().ServerIDOverride(); ok {
+		r.SetIP(OptionDHCPServerID, ip)
+		return
+	}
+
+	sid := s.PrimaryServerID()
+	if sid == nil {
+		return
+	}
+
+	if _, ok := rep.GetOption(OptionDHCPServerID); !ok {
+		r.SetIP(OptionDHCPServerID, sid)
+	}
+}
+
+// echoOptions copies options from the request into reply r: the Relay Agent
+// Information option, which the server must echo unmodified, including all the
+// sub-options of nested relay agents (RFC3046, section 2.2), and other options
+// as configured.
+func (s *Server) echoOptions(r Reply) {
+	if s == nil {
+		return
+	}
+
+	msg := r.Message()
+	if v, ok := msg.GetOption(OptionRelayAgentInformation); ok {
+		r.SetOption(OptionRelayAgentInformation, v)
+	}
+
+	if !s.EchoClientID {
+		return
+	}
+
+	if v, ok := msg.GetOption(OptionClientID); ok {
+		r.SetOption(OptionClientID, v)
+	}
+}
+
+// Serve reads packets off the network and calls the server's handler.
+func (s *Server) Serve(pc PacketConn) error {
+	h := s.handler()
+	m := s.metrics()
+	clk := s.clock()
+
+	if s.DontFragment != nil {
+		df, ok := pc.(DontFragmentSetter)
+		if !ok {
+			return ErrDontFragmentUnsupported
+		}
+		if err := df.SetDontFragment(*s.DontFragment); err != nil {
+			return err
+		}
+	}
+
+	cr, _ := pc.(ControlMessageReader)
+
+	ir, _ := pc.(InterfaceIndexSupporter)
+	if err := s.checkInterfaceIndex(ir); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, ifindex, cm, err := readFrom(pc, cr, buf)
+		if err != nil {
+			return err
+		}
+		now := clk.Now()
+
+		if !s.interfaceAllowed(ifindex) {
+			s.requestError(nil, ErrInterfaceNotAllowed)
+			m.PacketDropped(DropInterface, ifindex)
+			continue
+		}
+
+		if !s.Ready() {
+			clog.Debugf("not ready, ignoring packet from %s", addr)
+			m.PacketDropped(DropNotReady, ifindex)
+			continue
+		}
+
+		// Filter packets by op and message type before parsing them, so
+		// that dropped packets don't allocate
+		raw := RawPacket(buf[:n])
+		typ, err := raw.messageType()
+		if err != nil {
+			s.requestError(nil, err)
+			m.PacketDropped(DropMalformed, ifindex)
+			continue
+		}
+
+		// Filter everything but requests
+		if op := OpCode(raw.Op()[0]); op != BootRequest {
+			if s.Passthrough != nil {
+				s.Passthrough(buf[:n], addr, ifindex)
+				continue
+			}
+
+			clog.Warningf("ignoring op=%d mac=%s", op, raw.GetCHAddr())
+			m.PacketDropped(DropNotRequest, ifindex)
+			continue
+		}
+
+		if s.Passthrough != nil && !isServerMessageType(typ) {
+			s.Passthrough(buf[:n], addr, ifindex)
+			continue
+		}
+
+		p, err := PacketFromBytes(buf[:n])
+		if err != nil {
+			s.requestError(nil, err)
+			m.PacketDropped(DropMalformed, ifindex)
+			continue
+		}
+		p.receivedAt = now
+		p.cm = cm
+
+		if s.DropForeignReleases && typ == MessageTypeRelease && !s.IsForServer(&p) {
+			sid, _ := p.ServerID()
+			clog.Debugf("ignoring release for server %s mac=%s", sid, p.GetCHAddr())
+			m.PacketDropped(DropNotForUs, ifindex)
+			continue
+		}
+
+		if err := s.checkRelayAgentInfo(&p); err != nil {
+			s.requestError(&p, err)
+			if s.UnrelayedAgentInfo == RelayAgentInfoDrop {
+				m.PacketDropped(DropRelayInfo, ifindex)
+				continue
+			}
+		}
+
+		if err := checkLinkLocal(&p); err != nil {
+			s.requestError(&p, err)
+		}
+
+		a := addr.(*net.UDPAddr)
+		clog.Debug(&serverRecv{msg: &p, ip: a.IP, ifindex: ifindex})
+
+		var rw ReplyWriter
+		switch typ {
+		case MessageTypeDiscover, MessageTypeRequest, MessageTypeInform:
+			rw = &replyWriter{
+				pw:  pc,
+				srv: s,
+
+				addr:    *a,
+				ifindex: ifindex,
+			}
+		}
+		m.PacketReceived(typ, ifindex)
+		h.ServeDHCP(rw, &p)
+	}
+}
+
+// readFrom reads a packet from pc, with its control message if pc implements
+// ControlMessageReader (cr is not nil).
+func readFrom(pc PacketConn, cr ControlMessageReader, b []byte) (int, net.Addr, int, *ipv4.ControlMessage, error) {
+	if cr == nil {
+		n, addr, ifindex, err := pc.ReadFrom(b)
+		return n, addr, ifindex, nil, err
+	}
+
+	n, addr, cm, err := cr.ReadFromCM(b)
+	if err != nil {
+		return n, addr, -1, nil, err
+	}
+
+	ifindex := -1
+	if cm != nil {
+		ifindex = cm.IfIndex
+	}
+
+	return n, addr, ifindex, cm, nil
+}
+
+// isServerMessageType returns whether t is the type of a message clients send
+// to servers.
+func isServerMessageType(t MessageType) bool {
+	switch t {
+	case MessageTypeDiscover, MessageTypeRequest, MessageTypeDecline, MessageTypeRelease, MessageTypeInform:
+		return true
+	}
+
+	return false
+}
+
+// ShouldNak tells how to reply to the DHCPREQUEST req, like the function
+// ShouldNak, for a server that manages the addresses in pool. If the server is
+// not Authoritative, clients in the INIT-REBOOT state requesting an address
+// outside pool are not replied to.
+func (s *Server) ShouldNak(req *Packet, pool *net.IPNet) (nak bool, silent bool) {
+	nak, silent = ShouldNak(req, pool)
+	if nak && !s.Authoritative && req.State() == StateInitReboot {
+		return false, true
+	}
+
+	return nak, silent
+}
+
+// ParameterList returns the options the client sending the request wants to
+// have included in the reply. This is the client's Parameter Request List, or
+// the server's default list if the client didn't send one.
+func (s *Server) ParameterList(req *Packet) []Option {
+	if params, ok := req.GetParameterList(); ok {
+		return params
+	}
+
+	if s.DefaultParameters != nil {
+		return s.DefaultParameters
+	}
+
+	return DefaultParameterList
+}
+
+// ApplyOptions sets the options from opts in reply r, if they are in the
+// parameter list of the request the reply is for (see ParameterList).
+// Options already present in the reply are overwritten.
+func (s *Server) ApplyOptions(r Reply, opts OptionMap) {
+	for _, o := range s.ParameterList(r.Message()) {
+		if v, ok := opts.GetOption(o); ok {
+			r.SetOption(o, v)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package dhcp4
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testServerOptions() OptionMap {
+	opts := make(OptionMap)
+	opts.SetIP(OptionSubnetMask, net.IPv4(255, 255, 255, 0))
+	opts.SetIP(OptionRouter, net.IPv4(10, 0, 0, 1))
+	opts.SetIP(OptionDomainServer, net.IPv4(10, 0, 0, 2))
+	opts.SetString(OptionDomainName, "example.com")
+	opts.SetDuration(OptionAddressTime, time.Hour)
+	opts.SetIP(OptionNTPServers, net.IPv4(10, 0, 0, 3))
+	return opts
+}
+
+func TestServerApplyOptionsWithParameterList(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	req.SetOption(OptionParameterList, []byte{byte(OptionRouter), byte(OptionNTPServers), byte(OptionHostname)})
+
+	s := Server{}
+	rep := CreateOffer(&req)
+	s.ApplyOptions(&rep, testServerOptions())
+
+	assert.Equal(t, []Option{OptionRouter, OptionNTPServers, OptionDHCPMsgType}, rep.GetSortedOptions())
+}
+
+func TestServerApplyOptionsWithoutParameterList(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	s := Server{}
+	rep := CreateOffer(&req)
+	s.ApplyOptions(&rep, testServerOptions())
+
+	expected := []Option{
+		OptionSubnetMask,
+		OptionRouter,
+		OptionDomainServer,
+		OptionDomainName,
+		OptionAddressTime,
+		OptionDHCPMsgType,
+	}
+	assert.Equal(t, expected, rep.GetSortedOptions())
+
+	// The default list is configurable
+	s.DefaultParameters = []Option{OptionNTPServers}
+	rep = CreateOffer(&req)
+	s.ApplyOptions(&rep, testServerOptions())
+	assert.Equal(t, []Option{OptionNTPServers, OptionDHCPMsgType}, rep.GetSortedOptions())
+}
+
+func TestServerEchoClientID(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	req.SetOption(OptionClientID, []byte{0, 'f', 'o', 'o'})
+
+	for _, echo := range []bool{false, true} {
+		s := &Server{EchoClientID: echo}
+
+		pw := &testPacketConn{}
+		pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+		rw := replyWriter{pw: pw, srv: s}
+
+		offer := CreateOffer(&req)
+		offer.SetDuration(OptionAddressTime, time.Hour)
+		offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+		// The option doesn't fail validation
+		if !assert.NoError(t, rw.WriteReply(&offer)) {
+			continue
+		}
+
+		rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+		if assert.NoError(t, err) {
+			v, ok := rep.GetOption(OptionClientID)
+			assert.Equal(t, echo, ok)
+			if echo {
+				assert.Equal(t, []byte{0, 'f', 'o', 'o'}, v)
+			}
+		}
+	}
+}
+
+func TestServerDontFragmentUnsupported(t *testing.T) {
+	df := true
+	s := Server{Handler: HandlerFunc(func(ReplyWriter, *Packet) {}), DontFragment: &df}
+
+	// testPacketConn doesn't implement DontFragmentSetter
+	pc := &testPacketConn{}
+	assert.Equal(t, ErrDontFragmentUnsupported, s.Serve(pc))
+	pc.AssertNotCalled(t, "ReadFrom", mock.Anything)
+}
+
+func TestServerEchoesRelayAgentInfo(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+	AppendRelayAgentInfo(&req, RelayAgentInfo{{Code: RelayAgentCircuitID, Data: []byte("eth0")}})
+	AppendRelayAgentInfo(&req, RelayAgentInfo{{Code: RelayAgentCircuitID, Data: []byte("eth1")}})
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: &Server{}}
+
+	offer := CreateOffer(&req)
+	offer.SetDuration(OptionAddressTime, time.Hour)
+	offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+
+	if !assert.NoError(t, rw.WriteReply(&offer)) {
+		return
+	}
+
+	rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+	if assert.NoError(t, err) {
+		expected, _ := req.GetOption(OptionRelayAgentInformation)
+		actual, _ := rep.GetOption(OptionRelayAgentInformation)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestServerServerIDs(t *testing.T) {
+	vip := net.IPv4(10, 0, 0, 100)
+	s := &Server{ServerIDs: []net.IP{vip, net.IPv4(10, 0, 0, 1)}}
+	assert.Equal(t, vip, s.PrimaryServerID())
+
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetIP(OptionAddressRequest, net.IPv4(10, 0, 0, 5))
+	req.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	assert.True(t, s.IsForServer(&req))
+
+	// Builders and replies without identifier get the primary identifier
+	r, err := s.NewReplyBuilder(&req).LeaseTime(time.Hour).Build()
+	if assert.NoError(t, err) {
+		sid, _ := r.Reply().GetIP(OptionDHCPServerID)
+		assert.Equal(t, vip, sid)
+	}
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: s}
+
+	ack := CreateAck(&req)
+	ack.SetDuration(OptionAddressTime, time.Hour)
+	if assert.NoError(t, rw.WriteReply(&ack)) {
+		sid, _ := ack.GetIP(OptionDHCPServerID)
+		assert.Equal(t, vip, sid)
+	}
+}
+
+func TestServerDropForeignReleases(t *testing.T) {
+	release := func(sid net.IP) []byte {
+		return PacketSpec{Options: map[Option][]byte{
+			OptionDHCPMsgType:  {byte(MessageTypeRelease)},
+			OptionDHCPServerID: sid.To4(),
+		}}.Bytes()
+	}
+
+	m := &testMetrics{}
+	m.On("PacketReceived", MessageTypeRelease, -1).Return()
+	m.On("PacketDropped", DropNotForUs, -1).Return()
+
+	pc := &testPacketConn{}
+	pc.ReadSuccess(release(net.IPv4(10, 0, 0, 2)))
+	pc.ReadSuccess(release(net.IPv4(10, 0, 0, 1)))
+	pc.ReadError(io.EOF)
+
+	var handled []net.IP
+	s := Server{
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) {
+			sid, _ := p.ServerID()
+			handled = append(handled, sid)
+		}),
+		Metrics:             m,
+		ServerIDs:           []net.IP{net.IPv4(10, 0, 0, 1)},
+		DropForeignReleases: true,
+	}
+	s.Serve(pc)
+
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1)}, handled)
+	m.AssertCalled(t, "PacketDropped", DropNotForUs, -1)
+}
+
+func TestServerReplyInterceptor(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeDiscover)
+
+	s := &Server{
+		ReplyInterceptor: func(msg *Packet, r Reply) Reply {
+			assert.Equal(t, &req, msg)
+
+			// Options the reply must have satisfy validation
+			r.SetDuration(OptionAddressTime, time.Hour)
+			r.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+			r.SetString(OptionDomainName, "example.com")
+			return r
+		},
+	}
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: s}
+
+	offer := CreateOffer(&req)
+	if !assert.NoError(t, rw.WriteReply(&offer)) {
+		return
+	}
+
+	rep, err := PacketFromBytes(pw.Calls[0].Arguments.Get(0).([]byte))
+	if assert.NoError(t, err) {
+		v, _ := rep.GetString(OptionDomainName)
+		assert.Equal(t, "example.com", v)
+	}
+
+	// Dropping the reply
+	s.ReplyInterceptor = func(*Packet, Reply) Reply { return nil }
+	assert.NoError(t, rw.WriteReply(&offer))
+	pw.AssertNumberOfCalls(t, "WriteTo", 1)
+}
+
+func TestServerErrorHandler(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType:    {byte(MessageTypeRequest)},
+		OptionAddressRequest: {169, 254, 0, 5},
+	}}.Bytes())
+	pc.ReadError(io.EOF)
+
+	var errs []error
+	handled := false
+	s := Server{
+		Handler:      HandlerFunc(func(ReplyWriter, *Packet) { handled = true }),
+		ErrorHandler: func(p *Packet, err error) { errs = append(errs, err) },
+	}
+	s.Serve(pc)
+
+	assert.Equal(t, []error{ErrLinkLocalAddress}, errs)
+	assert.True(t, handled)
+}
+
+func TestServerServerIDOverride(t *testing.T) {
+	override := net.IPv4(10, 1, 0, 1).To4()
+	s := &Server{ServerIDs: []net.IP{net.IPv4(10, 0, 0, 1)}}
+
+	req := NewPacket(BootRequest)
+	req.SetMessageType(MessageTypeRequest)
+	req.SetGIAddr(net.IPv4(10, 1, 0, 1))
+	req.SetCIAddr(net.IPv4(10, 1, 0, 5))
+	req.SetIP(OptionDHCPServerID, override)
+	req.SetRelayAgentInfo(RelayAgentInfo{{Code: RelayAgentServerIDOverride, Data: override}})
+	assert.True(t, s.IsForServer(&req))
+
+	r, err := s.NewReplyBuilder(&req).LeaseTime(time.Hour).Build()
+	if assert.NoError(t, err) {
+		sid, _ := r.Reply().GetIP(OptionDHCPServerID)
+		assert.Equal(t, net.IPv4(10, 1, 0, 1), sid)
+	}
+
+	pw := &testPacketConn{}
+	pw.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	rw := replyWriter{pw: pw, srv: s, addr: net.UDPAddr{IP: net.IPv4(10, 1, 0, 1), Port: ServerPort}}
+
+	ack := CreateAck(&req)
+	ack.SetDuration(OptionAddressTime, time.Hour)
+	ack.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+	if assert.NoError(t, rw.WriteReply(&ack)) {
+		sid, _ := ack.GetIP(OptionDHCPServerID)
+		assert.Equal(t, net.IPv4(10, 1, 0, 1), sid)
+
+		// Sent to the relay agent, not to the client
+		addr := pw.Calls[0].Arguments.Get(1).(*net.UDPAddr)
+		assert.Equal(t, net.IPv4(10, 1, 0, 1).To4(), addr.IP.To4())
+		assert.Equal(t, ServerPort, addr.Port)
+	}
+}
+
+func TestServerPassthrough(t *testing.T) {
+	reply := PacketSpec{Op: BootReply, Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeOffer)},
+	}}.Bytes()
+	query := PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeLeaseQuery)},
+	}}.Bytes()
+	discover := PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeDiscover)},
+	}}.Bytes()
+
+	pc := &testPacketConn{}
+	pc.ReadSuccess(reply)
+	pc.ReadSuccess(query)
+	pc.ReadSuccess(discover)
+	pc.ReadError(io.EOF)
+
+	var forwarded [][]byte
+	var handled []MessageType
+	s := Server{
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) { handled = append(handled, p.GetMessageType()) }),
+		Passthrough: func(raw []byte, addr net.Addr, ifindex int) {
+			forwarded = append(forwarded, append([]byte(nil), raw...))
+		},
+	}
+	s.Serve(pc)
+
+	assert.Equal(t, [][]byte{reply, query}, forwarded)
+	assert.Equal(t, []MessageType{MessageTypeDiscover}, handled)
+}
+
+func TestServerReplyLatency(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeDiscover))
+	pc.ReadError(io.EOF)
+	pc.On("WriteTo", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+
+	m := &testMetrics{}
+	m.On("PacketReceived", MessageTypeDiscover, -1).Return()
+	m.On("ReplyLatency", MessageTypeDiscover, mock.Anything).Return()
+
+	start := time.Now()
+	s := Server{
+		Metrics: m,
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) {
+			assert.False(t, p.ReceivedAt().Before(start))
+			time.Sleep(time.Millisecond)
+
+			offer := CreateOffer(p)
+			offer.SetDuration(OptionAddressTime, time.Hour)
+			offer.SetIP(OptionDHCPServerID, net.IPv4(10, 0, 0, 1))
+			assert.NoError(t, w.WriteReply(&offer))
+		}),
+	}
+	s.Serve(pc)
+
+	m.AssertExpectations(t)
+	if d, ok := m.Calls[1].Arguments.Get(1).(time.Duration); assert.True(t, ok) {
+		assert.True(t, d >= time.Millisecond)
+	}
+}
+
+func TestServerAllowedInterfaces(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeDiscover))
+	pc.ReadError(io.EOF)
+
+	m := &testMetrics{}
+	m.On("PacketDropped", DropInterface, -1).Return()
+
+	var errs []error
+	s := Server{
+		Metrics:           m,
+		AllowedInterfaces: []int{2},
+		Handler:           HandlerFunc(func(ReplyWriter, *Packet) { t.Error("packet handled") }),
+		ErrorHandler: func(p *Packet, err error) {
+			assert.Nil(t, p)
+			errs = append(errs, err)
+		},
+	}
+	s.Serve(pc)
+
+	m.AssertExpectations(t)
+	assert.Equal(t, []error{ErrInterfaceNotAllowed}, errs)
+}
+
+func TestServerReady(t *testing.T) {
+	pc := &testPacketConn{}
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeDiscover))
+	pc.ReadSuccess(testRequestBytes(t, MessageTypeRequest))
+	pc.ReadError(io.EOF)
+
+	var handled []MessageType
+	s := &Server{
+		Handler: HandlerFunc(func(w ReplyWriter, p *Packet) { handled = append(handled, p.GetMessageType()) }),
+	}
+	assert.True(t, s.Ready())
+
+	// The server becomes ready after dropping the first request
+	m := &testMetrics{}
+	m.On("PacketDropped", DropNotReady, -1).Return().Once().Run(func(mock.Arguments) { s.SetReady(true) })
+	m.On("PacketReceived", MessageTypeRequest, -1).Return()
+	s.Metrics = m
+
+	s.SetReady(false)
+	assert.False(t, s.Ready())
+	s.Serve(pc)
+
+	m.AssertExpectations(t)
+	assert.Equal(t, []MessageType{MessageTypeRequest}, handled)
+}
+
+// benchPacketConn returns the same packet n times, then io.EOF. Unlike
+// testPacketConn, it doesn't allocate on reads.
+type benchPacketConn struct {
+	b    []byte
+	n    int
+	addr net.UDPAddr
+}
+
+func (pc *benchPacketConn) ReadFrom(b []byte) (int, net.Addr, int, error) {
+	if pc.n == 0 {
+		return 0, nil, -1, io.EOF
+	}
+	pc.n--
+	return copy(b, pc.b), &pc.addr, 1, nil
+}
+
+func (pc *benchPacketConn) WriteTo(b []byte, addr net.Addr, ifindex int) (int, error) {
+	return len(b), nil
+}
+
+func (pc *benchPacketConn) Close() error        { return nil }
+func (pc *benchPacketConn) LocalAddr() net.Addr { return &pc.addr }
+
+func benchmarkServe(b *testing.B, spec PacketSpec) {
+	pc := &benchPacketConn{b: spec.Bytes(), n: b.N, addr: net.UDPAddr{IP: net.IPv4zero, Port: ClientPort}}
+	s := Server{
+		Handler:     HandlerFunc(func(ReplyWriter, *Packet) {}),
+		Passthrough: func([]byte, net.Addr, int) {},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	s.Serve(pc)
+}
+
+func BenchmarkServeReply(b *testing.B) {
+	benchmarkServe(b, PacketSpec{Op: BootReply, Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeOffer)},
+	}})
+}
+
+func BenchmarkServePassthrough(b *testing.B) {
+	benchmarkServe(b, PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeLeaseQuery)},
+	}})
+}
+
+func BenchmarkServeDiscover(b *testing.B) {
+	benchmarkServe(b, PacketSpec{Options: map[Option][]byte{
+		OptionDHCPMsgType: {byte(MessageTypeDiscover)},
+	}})
+}
+
+func TestServerShouldNakAuthoritative(t *testing.T) {
+	_, pool, _ := net.ParseCIDR("10.0.0.0/24")
+	sid := net.IPv4(10, 0, 0, 1)
+	other := net.IPv4(192, 168, 0, 5)
+
+	testCases := []struct {
+		req                 *Packet
+		authNak, authSilent bool
+		nak, silent         bool
+	}{
+		// INIT-REBOOT
+		{testStateRequest(nil, other, nil), true, false, false, true},
+		// SELECTING
+		{testStateRequest(sid, other, nil), true, false, true, false},
+		// RENEWING
+		{testStateRequest(nil, nil, other), false, true, false, true},
+		// In pool
+		{testStateRequest(nil, net.IPv4(10, 0, 0, 5), nil), false, false, false, false},
+	}
+
+	for i, tc := range testCases {
+		s := Server{Authoritative: true}
+		nak, silent := s.ShouldNak(tc.req, pool)
+		assert.Equal(t, tc.authNak, nak, "case %d", i)
+		assert.Equal(t, tc.authSilent, silent, "case %d", i)
+
+		s.Authoritative = false
+		nak, silent = s.ShouldNak(tc.req, pool)
+		assert.Equal(t, tc.nak, nak, "case %d", i)
+		assert.Equal(t, tc.silent, silent, "case %d", i)
+	}
+}
@@ -0,0 +1,62 @@
+package dhcp4
+
+import "net"
+
+// Accessors for the application and service parameters of RFC2132, section 8.
+// Their values are checked against the kinds registered for the options, by
+// the setters and by the getters.
+
+// GetNISDomain gets the Network Information Service Domain option (40).
+func (om OptionMap) GetNISDomain() (string, bool) {
+	return om.getCheckedString(OptionNISDomain)
+}
+
+// SetNISDomain sets the Network Information Service Domain option (40). The
+// domain must not be empty.
+func (om OptionMap) SetNISDomain(domain string) error {
+	return om.SetString(OptionNISDomain, domain)
+}
+
+// GetNISServers gets the Network Information Servers option (41), in order
+// of preference.
+func (om OptionMap) GetNISServers() ([]net.IP, bool) {
+	return om.getCheckedIPs(OptionNISServers)
+}
+
+// SetNISServers sets the Network Information Servers option (41). The list
+// must not be empty.
+func (om OptionMap) SetNISServers(ips []net.IP) error {
+	return om.SetIPs(OptionNISServers, ips)
+}
+
+// GetNTPServers gets the Network Time Protocol Servers option (42), in order
+// of preference.
+func (om OptionMap) GetNTPServers() ([]net.IP, bool) {
+	return om.getCheckedIPs(OptionNTPServers)
+}
+
+// SetNTPServers sets the Network Time Protocol Servers option (42). The list
+// must not be empty.
+func (om OptionMap) SetNTPServers(ips []net.IP) error {
+	return om.SetIPs(OptionNTPServers, ips)
+}
+
+// getCheckedString gets the string value of option o, if it matches the kind
+// registered for the option.
+func (om OptionMap) getCheckedString(o Option) (string, bool) {
+	if v, ok := om.GetOption(o); !ok || checkOption(o, v) != nil {
+		return "", false
+	}
+
+	return om.GetString(o)
+}
+
+// getCheckedIPs gets the list of IPs value of option o, if it matches the
+// kind registered for the option.
+func (om OptionMap) getCheckedIPs(o Option) ([]net.IP, bool) {
+	if v, ok := om.GetOption(o); !ok || checkOption(o, v) != nil {
+		return nil, false
+	}
+
+	return om.GetIPs(o)
+}
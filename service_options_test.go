@@ -0,0 +1,58 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceOptions(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.NoError(t, om.SetNISDomain("example"))
+	assert.NoError(t, om.SetNISServers([]net.IP{net.IPv4(10, 0, 0, 1)}))
+	assert.NoError(t, om.SetNTPServers([]net.IP{net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 3)}))
+
+	assert.Equal(t, []byte("example"), om[OptionNISDomain])
+	assert.Equal(t, []byte{10, 0, 0, 2, 10, 0, 0, 3}, om[OptionNTPServers])
+
+	domain, ok := om.GetNISDomain()
+	assert.True(t, ok)
+	assert.Equal(t, "example", domain)
+	ips, ok := om.GetNISServers()
+	assert.True(t, ok)
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1)}, ips)
+	ips, ok = om.GetNTPServers()
+	assert.True(t, ok)
+	assert.Equal(t, []net.IP{net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 3)}, ips)
+}
+
+func TestServiceOptionsInvalid(t *testing.T) {
+	om := make(OptionMap)
+
+	assert.Error(t, om.SetNISDomain(""))
+	assert.Error(t, om.SetNISServers(nil))
+	assert.Error(t, om.SetNTPServers([]net.IP{net.ParseIP("2001:db8::1")}))
+	assert.Empty(t, om)
+
+	// Values not matching the registered kind
+	om.SetOption(OptionNISDomain, []byte{})
+	_, ok := om.GetNISDomain()
+	assert.False(t, ok)
+
+	for _, v := range [][]byte{{}, {10, 0, 0}} {
+		om.SetOption(OptionNTPServers, v)
+		_, ok = om.GetNTPServers()
+		assert.False(t, ok, "value %v", v)
+
+		om.SetOption(OptionNISServers, v)
+		_, ok = om.GetNISServers()
+		assert.False(t, ok, "value %v", v)
+	}
+
+	// Missing
+	om = make(OptionMap)
+	_, ok = om.GetNTPServers()
+	assert.False(t, ok)
+}
@@ -0,0 +1,375 @@
+package dhcp4
+
+import (
+	"net"
+	"time"
+)
+
+// Defaults for SimpleServer.
+const (
+	DefaultLeaseTime = 12 * time.Hour
+	DefaultOfferTime = time.Minute
+)
+
+// SimpleServer is a DHCP server for a single network, leasing addresses from
+// an in-memory LeasePool. It handles DHCPDISCOVER, DHCPREQUEST, DHCPDECLINE,
+// DHCPRELEASE and DHCPINFORM.
+type SimpleServer struct {
+	Server
+
+	// Network served by the server.
+	Network *net.IPNet
+
+	// Pool to lease addresses from.
+	Pool *LeasePool
+
+	// ServerID is the address of the server, sent in the Server Identifier
+	// option. It must be an address clients can reach the server on.
+	ServerID net.IP
+
+	// LeaseTime is the duration of leases.
+	LeaseTime time.Duration
+
+	// OfferTime is the duration an offered address is held for the client,
+	// waiting for its DHCPREQUEST. Repeated DHCPDISCOVERs within it are
+	// offered the same address (see LeasePool.Offer).
+	OfferTime time.Duration
+
+	// Options to include in replies, if requested by the client. See
+	// Server.ApplyOptions. The options of the client's class (see Classes)
+	// take precedence over them, and the options of the client's reservation
+	// (see WithOptions) over both: for every option, replies get the value of
+	// the reservation, else of the class, else of Options. Whichever value
+	// wins, only options in the client's Parameter Request List (55), or the
+	// server's default parameters, are included.
+	Options OptionMap
+
+	// Classifier assigns requests to the classes in Classes, if not nil.
+	// Clients should be classified the same in all their requests, including
+	// the DHCPRELEASE and DHCPDECLINE, to lease from the same pool.
+	Classifier Classifier
+	Classes    map[string]ClassConfig
+
+	// AllocateFunc is called, if not nil, with the address the pool chose for
+	// the client sending req (the candidate) before it is offered or
+	// acknowledged, e.g. to defer address assignment to an external IPAM
+	// system. Returning another address overrides the pool's choice; it is
+	// leased from the pool if it is in the pool, and the candidate is
+	// released. Addresses outside the pool must be in Network; the pool
+	// doesn't track them, so a DHCPREQUEST for one is passed to AllocateFunc
+	// as the candidate, to confirm. Returning an error releases the
+	// candidate, and the server stays silent to a DHCPDISCOVER and sends a
+	// DHCPNAK to a DHCPREQUEST.
+	//
+	// It is called on the reply path, in the serve loop: no other request is
+	// handled until it returns, and clients retransmit after about 4 seconds
+	// without reply (RFC2131, section 4.1). It should return well within a
+	// second, e.g. by querying the IPAM system with a timeout.
+	AllocateFunc func(req *Packet, candidate net.IP) (net.IP, error)
+}
+
+// NewSimpleServer returns a server leasing the addresses of the network
+// cidr, e.g. "192.168.1.0/24", with the specified gateway and DNS servers. The
+// server identifier is the gateway address; set ServerID if the server is not
+// running on the gateway. The gateway and server addresses are not leased.
+// The server is Authoritative for the network; clear Authoritative if other
+// servers serve it as well.
+func NewSimpleServer(cidr string, gateway net.IP, dns []net.IP) (*SimpleServer, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SimpleServer{
+		Server:    Server{Authoritative: true},
+		Network:   network,
+		ServerID:  gateway,
+		LeaseTime: DefaultLeaseTime,
+		OfferTime: DefaultOfferTime,
+		Options:   make(OptionMap),
+	}
+
+	s.Handler = s
+	s.Pool = NewLeasePool(network, append([]net.IP{gateway}, dns...)...)
+
+	if err := s.Options.SetSubnetMask(network.Mask); err != nil {
+		return nil, err
+	}
+	if err := s.Options.SetIP(OptionRouter, gateway); err != nil {
+		return nil, err
+	}
+	if len(dns) > 0 {
+		if err := s.Options.SetIPs(OptionDomainServer, dns); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// ListenAndServe listens on the UDP address addr (see Listen) and serves
+// requests.
+func (s *SimpleServer) ListenAndServe(addr string) error {
+	pc, err := Listen(addr)
+	if err != nil {
+		return err
+	}
+
+	defer pc.Close()
+	return s.Serve(pc)
+}
+
+// ServeDHCP implements Handler.
+func (s *SimpleServer) ServeDHCP(w ReplyWriter, p *Packet) {
+	// Clients refused by the pool's client identifier policy are not served
+	err := s.checkClient(p)
+
+	if err == nil {
+		switch p.GetMessageType() {
+		case MessageTypeDiscover:
+			err = s.serveDiscover(w, p)
+		case MessageTypeRequest:
+			err = s.serveRequest(w, p)
+		case MessageTypeDecline:
+			err = s.serveDecline(p)
+		case MessageTypeRelease:
+			err = s.serveRelease(p)
+		case MessageTypeInform:
+			err = s.serveInform(w, p)
+		}
+	}
+
+	if err != nil {
+		clog.Warningf("%s from %s: %s", p.GetMessageType(), p.GetCHAddr(), err)
+	}
+}
+
+// class returns the configuration of the class of request p, if any.
+func (s *SimpleServer) class(p *Packet) (ClassConfig, bool) {
+	if s.Classifier == nil {
+		return ClassConfig{}, false
+	}
+
+	name, ok := s.Classifier.Classify(p)
+	if !ok {
+		return ClassConfig{}, false
+	}
+
+	c, ok := s.Classes[name]
+	return c, ok
+}
+
+// pool returns the pool to lease addresses to the client sending p from.
+func (s *SimpleServer) pool(p *Packet) *LeasePool {
+	if c, ok := s.class(p); ok && c.Pool != nil {
+		return c.Pool
+	}
+
+	return s.Pool
+}
+
+// options returns the options to include in replies to request p: the
+// server's options, overridden by the options of the client's class, then by
+// the options of the client's reservation.
+func (s *SimpleServer) options(p *Packet) OptionMap {
+	layers := []OptionMap{s.Options}
+	if c, ok := s.class(p); ok && c.Options != nil {
+		layers = append(layers, c.Options)
+	}
+	if r := s.pool(p).ReservedOptions(p.ClientID()); r != nil {
+		layers = append(layers, r)
+	}
+
+	if len(layers) == 1 {
+		return s.Options
+	}
+
+	n := 0
+	for _, l := range layers {
+		n += len(l)
+	}
+
+	opts := make(OptionMap, n)
+	for _, l := range layers {
+		for o, v := range l {
+			opts[o] = v
+		}
+	}
+
+	return opts
+}
+
+// checkClient checks the client identifier of p against the pool, sending a
+// ClientIDConflictEvent for conflicts (see LeasePool.CheckClient).
+func (s *SimpleServer) checkClient(p *Packet) error {
+	c, err := s.pool(p).CheckClient(p.ClientID(), p.GetCHAddr())
+	if c != nil {
+		s.notify(*c)
+	}
+
+	return err
+}
+
+// serveDecline declines the address of the DHCPDECLINE p, sending a
+// DeclineEvent if the pool accepted it.
+func (s *SimpleServer) serveDecline(p *Packet) error {
+	ip := declinedIP(p)
+	if err := s.pool(p).Decline(p.ClientID(), ip); err != nil {
+		return err
+	}
+
+	s.notify(DeclineEvent{IP: ip, ClientID: p.ClientID()})
+	return nil
+}
+
+// serveRelease releases the address of the DHCPRELEASE p, sending a
+// ReleaseEvent if the client held the lease.
+func (s *SimpleServer) serveRelease(p *Packet) error {
+	ip := p.GetCIAddr()
+	if err := s.pool(p).Release(p.ClientID(), ip); err != nil {
+		return err
+	}
+
+	s.notify(ReleaseEvent{IP: ip, ClientID: p.ClientID()})
+	return nil
+}
+
+func (s *SimpleServer) serveDiscover(w ReplyWriter, p *Packet) error {
+	l, err := s.pool(p).Offer(p.ClientID(), p.RequestedIP(), s.OfferTime)
+	if err != nil {
+		return err
+	}
+
+	ip, err := s.allocate(p, l, s.OfferTime)
+	if err != nil {
+		return err
+	}
+	s.pool(p).SetHardwareAddr(p.ClientID(), p.GetCHAddr())
+
+	r := CreateOffer(p)
+	r.SetYIAddr(ip)
+	r.SetDuration(OptionAddressTime, s.LeaseTime)
+	return s.reply(w, &r)
+}
+
+func (s *SimpleServer) serveRequest(w ReplyWriter, p *Packet) error {
+	// The client selected another server (RFC2131, section 4.3.2)
+	if !p.IsForServer(s.ServerID) {
+		s.pool(p).Release(p.ClientID(), nil)
+		return nil
+	}
+
+	if _, ambiguous := p.ClassifyState(); ambiguous {
+		clog.Infof("%s from %s: renewing with requested address; using ciaddr", p.GetMessageType(), p.GetCHAddr())
+	}
+
+	nak, silent := s.ShouldNak(p, s.Network)
+	if silent {
+		return nil
+	}
+
+	l, err := s.pool(p).AllocateIP(p.ClientID(), p.RequestedIP(), s.LeaseTime)
+	if err != nil && !nak && s.AllocateFunc != nil && !s.pool(p).Contains(p.RequestedIP()) {
+		// An address outside the pool, as AllocateFunc may have offered
+		l, err = Lease{IP: p.RequestedIP(), ClientID: p.ClientID()}, nil
+	}
+	if err != nil && !nak && !s.recognizes(p) {
+		return nil
+	}
+	if nak || err != nil {
+		r := CreateNak(p)
+		return s.reply(w, &r)
+	}
+
+	ip, err := s.allocate(p, l, s.LeaseTime)
+	if err != nil {
+		clog.Infof("%s from %s: %s", p.GetMessageType(), p.GetCHAddr(), err)
+		r := CreateNak(p)
+		return s.reply(w, &r)
+	}
+
+	s.pool(p).SetHardwareAddr(p.ClientID(), p.GetCHAddr())
+
+	r := CreateAck(p)
+	r.SetYIAddr(ip)
+	r.SetDuration(OptionAddressTime, s.LeaseTime)
+	return s.reply(w, &r)
+}
+
+// declinedIP returns the address declined by the DHCPDECLINE p, from its
+// Requested IP Address option (RFC2131, section 4.4.3). It returns the
+// unspecified address if the client didn't include the option, which matches
+// no lease.
+func declinedIP(p *Packet) net.IP {
+	if ip, ok := p.GetIP(OptionAddressRequest); ok {
+		return ip
+	}
+
+	return net.IPv4zero
+}
+
+// recognizes returns whether the server should send a DHCPNAK to the
+// DHCPREQUEST p for an address it can't lease to the client: the server is
+// Authoritative, or it has a record of the client, or the client is not in
+// the INIT-REBOOT state. A server without record of an INIT-REBOOT client
+// must stay silent (RFC2131, section 4.3.2).
+func (s *SimpleServer) recognizes(p *Packet) bool {
+	if s.Authoritative || p.State() != StateInitReboot {
+		return true
+	}
+
+	_, ok := s.pool(p).Lookup(p.ClientID())
+	return ok
+}
+
+// allocate returns the address to lease to the client sending p, which the
+// pool leased l to, for duration d: the address AllocateFunc returns, if set.
+func (s *SimpleServer) allocate(p *Packet, l Lease, d time.Duration) (net.IP, error) {
+	if s.AllocateFunc == nil {
+		return l.IP, nil
+	}
+
+	pool := s.pool(p)
+	ip, err := s.AllocateFunc(p, l.IP)
+	if err == nil && ip != nil && ip.To4() == nil {
+		err = ErrInvalidAddress
+	}
+	if err != nil {
+		pool.Release(p.ClientID(), l.IP)
+		return nil, err
+	}
+
+	if ip == nil || ip.Equal(l.IP) {
+		return l.IP, nil
+	}
+
+	pool.Release(p.ClientID(), l.IP)
+	if s.Network != nil && !s.Network.Contains(ip) {
+		return nil, ErrInvalidAddress
+	}
+	if pool.Contains(ip) {
+		if _, err := pool.AllocateIP(p.ClientID(), ip, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return ip, nil
+}
+
+func (s *SimpleServer) serveInform(w ReplyWriter, p *Packet) error {
+	r := CreateAck(p)
+	return s.reply(w, &r)
+}
+
+func (s *SimpleServer) reply(w ReplyWriter, r Reply) error {
+	if err := r.SetIP(OptionDHCPServerID, s.ServerID); err != nil {
+		return err
+	}
+
+	// A DHCPNAK carries no configuration parameters
+	if r.Reply().GetMessageType() != MessageTypeNak {
+		s.ApplyOptions(r, s.options(r.Message()))
+	}
+
+	return w.WriteReply(r)
+}
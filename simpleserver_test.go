@@ -0,0 +1,415 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReplyRecorder struct {
+	replies []Reply
+}
+
+func (w *testReplyRecorder) WriteReply(r Reply) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	w.replies = append(w.replies, r)
+	return nil
+}
+
+func (w *testReplyRecorder) last() *Packet {
+	if len(w.replies) == 0 {
+		return nil
+	}
+
+	return w.replies[len(w.replies)-1].Reply()
+}
+
+func testSimpleServer(t *testing.T) *SimpleServer {
+	s, err := NewSimpleServer("192.168.1.0/24", net.IPv4(192, 168, 1, 1), []net.IP{net.IPv4(192, 168, 1, 2)})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return s
+}
+
+func testSimpleRequest(mt MessageType, mac net.HardwareAddr) *Packet {
+	p := NewPacket(BootRequest)
+	p.HType()[0] = 1
+	p.SetCHAddr(mac)
+	p.SetMessageType(mt)
+	return &p
+}
+
+func TestSimpleServerDORA(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	// Discover
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+	offer := w.last()
+	if !assert.NotNil(t, offer) {
+		return
+	}
+
+	assert.Equal(t, MessageTypeOffer, offer.GetMessageType())
+	assert.Equal(t, net.IP{192, 168, 1, 3}, offer.GetYIAddr())
+	ip, _ := offer.GetIP(OptionRouter)
+	assert.Equal(t, net.IPv4(192, 168, 1, 1), ip)
+	ips, _ := offer.GetIPs(OptionDomainServer)
+	assert.Equal(t, []net.IP{net.IPv4(192, 168, 1, 2)}, ips)
+
+	// Request
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, offer.GetYIAddr())
+	req.SetIP(OptionDHCPServerID, net.IPv4(192, 168, 1, 1))
+	s.ServeDHCP(w, req)
+
+	ack := w.last()
+	assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+	assert.Equal(t, net.IP{192, 168, 1, 3}, ack.GetYIAddr())
+	d, _ := ack.GetDuration(OptionAddressTime)
+	assert.Equal(t, DefaultLeaseTime, d)
+
+	// Another client requesting the same address gets a DHCPNAK
+	req = testSimpleRequest(MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, 2})
+	req.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 3))
+	s.ServeDHCP(w, req)
+	assert.Equal(t, MessageTypeNak, w.last().GetMessageType())
+
+	// Releases of another address are ignored
+	rel := testSimpleRequest(MessageTypeRelease, mac)
+	rel.SetCIAddr(net.IPv4(192, 168, 1, 4))
+	s.ServeDHCP(nil, rel)
+
+	_, ok := s.Pool.Lookup(rel.ClientID())
+	assert.True(t, ok)
+
+	// Release
+	rel.SetCIAddr(net.IPv4(192, 168, 1, 3))
+	s.ServeDHCP(nil, rel)
+
+	_, ok = s.Pool.Lookup(rel.ClientID())
+	assert.False(t, ok)
+}
+
+func TestSimpleServerIgnoresRequestForOtherServer(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, w.last().GetYIAddr())
+	req.SetIP(OptionDHCPServerID, net.IPv4(192, 168, 1, 254))
+	s.ServeDHCP(w, req)
+
+	// No reply, and the offered address is freed
+	assert.Len(t, w.replies, 1)
+	_, ok := s.Pool.Lookup(req.ClientID())
+	assert.False(t, ok)
+}
+
+func TestSimpleServerInform(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+
+	req := testSimpleRequest(MessageTypeInform, net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	req.SetCIAddr(net.IPv4(192, 168, 1, 100))
+	s.ServeDHCP(w, req)
+
+	if assert.Len(t, w.replies, 1) {
+		ack := w.last()
+		assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+		_, ok := ack.GetOption(OptionAddressTime)
+		assert.False(t, ok)
+	}
+}
+
+func TestSimpleServerRequestOutsideNetwork(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	// INIT-REBOOT client from another network gets a DHCPNAK
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, net.IPv4(10, 0, 0, 5))
+	s.ServeDHCP(w, req)
+	if assert.Len(t, w.replies, 1) {
+		assert.Equal(t, MessageTypeNak, w.last().GetMessageType())
+	}
+
+	// RENEWING client from another network is not replied to
+	req = testSimpleRequest(MessageTypeRequest, mac)
+	req.SetCIAddr(net.IPv4(10, 0, 0, 5))
+	s.ServeDHCP(w, req)
+	assert.Len(t, w.replies, 1)
+}
+
+func TestSimpleServerClientIDConflict(t *testing.T) {
+	s := testSimpleServer(t)
+	events := s.Notifications()
+	w := &testReplyRecorder{}
+
+	dis := testSimpleRequest(MessageTypeDiscover, net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	dis.SetOption(OptionClientID, []byte("client"))
+	s.ServeDHCP(w, dis)
+	assert.Len(t, w.replies, 1)
+
+	// Another host presenting the same client identifier
+	dis = testSimpleRequest(MessageTypeDiscover, net.HardwareAddr{2, 0, 0, 0, 0, 2})
+	dis.SetOption(OptionClientID, []byte("client"))
+	s.ServeDHCP(w, dis)
+	assert.Len(t, w.replies, 1)
+
+	if assert.Len(t, events, 1) {
+		e := (<-events).(ClientIDConflictEvent)
+		assert.Equal(t, net.HardwareAddr{2, 0, 0, 0, 0, 2}, e.HardwareAddr)
+	}
+}
+
+func TestSimpleServerNotifications(t *testing.T) {
+	s := testSimpleServer(t)
+	events := s.Notifications()
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 3))
+	s.ServeDHCP(w, req)
+	assert.Equal(t, MessageTypeAck, w.last().GetMessageType())
+
+	// Releases of an address the client doesn't hold are not reported
+	rel := testSimpleRequest(MessageTypeRelease, mac)
+	rel.SetCIAddr(net.IPv4(192, 168, 1, 4))
+	s.ServeDHCP(nil, rel)
+	assert.Len(t, events, 0)
+
+	rel.SetCIAddr(net.IPv4(192, 168, 1, 3))
+	s.ServeDHCP(nil, rel)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ReleaseEvent{IP: net.IP{192, 168, 1, 3}, ClientID: rel.ClientID()}, <-events)
+	}
+
+	// Neither are declines of an address the client wasn't offered
+	dec := testSimpleRequest(MessageTypeDecline, mac)
+	dec.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 5))
+	s.ServeDHCP(nil, dec)
+	assert.Len(t, events, 0)
+
+	s.ServeDHCP(w, req)
+	assert.Equal(t, MessageTypeAck, w.last().GetMessageType())
+
+	dec.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 3))
+	s.ServeDHCP(nil, dec)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, DeclineEvent{IP: net.IPv4(192, 168, 1, 3), ClientID: dec.ClientID()}, <-events)
+	}
+}
+
+func TestSimpleServerAllocateFunc(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	var candidates []net.IP
+	s.AllocateFunc = func(req *Packet, candidate net.IP) (net.IP, error) {
+		candidates = append(candidates, candidate)
+		return net.IPv4(192, 168, 1, 50), nil
+	}
+
+	// The address returned by the hook overrides the pool
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+	offer := w.last()
+	if !assert.NotNil(t, offer) {
+		return
+	}
+	assert.Equal(t, net.IP{192, 168, 1, 50}, offer.GetYIAddr().To4())
+	assert.False(t, s.Pool.IsOffered(net.IPv4(192, 168, 1, 3)))
+
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, offer.GetYIAddr())
+	req.SetIP(OptionDHCPServerID, net.IPv4(192, 168, 1, 1))
+	s.ServeDHCP(w, req)
+
+	ack := w.last()
+	assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+	assert.Equal(t, net.IP{192, 168, 1, 50}, ack.GetYIAddr().To4())
+	assert.Equal(t, []net.IP{{192, 168, 1, 3}, {192, 168, 1, 50}}, candidates)
+
+	l, ok := s.Pool.Lookup(req.ClientID())
+	assert.True(t, ok)
+	assert.Equal(t, net.IP{192, 168, 1, 50}, l.IP)
+
+	// Vetoed requests get a DHCPNAK, and lose their lease
+	s.AllocateFunc = func(*Packet, net.IP) (net.IP, error) { return nil, ErrAddressInUse }
+	s.ServeDHCP(w, req)
+	assert.Equal(t, MessageTypeNak, w.last().GetMessageType())
+	_, ok = s.Pool.Lookup(req.ClientID())
+	assert.False(t, ok)
+
+	// Vetoed discovers get no reply
+	n := len(w.replies)
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+	assert.Len(t, w.replies, n)
+}
+
+func TestSimpleServerAllocateFuncOutsidePool(t *testing.T) {
+	s := testSimpleServer(t)
+	pool, err := NewLeasePoolRanges([]IPRange{{Start: net.IPv4(192, 168, 1, 10), End: net.IPv4(192, 168, 1, 99)}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	s.Pool = pool
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	var candidates []net.IP
+	s.AllocateFunc = func(req *Packet, candidate net.IP) (net.IP, error) {
+		candidates = append(candidates, candidate.To4())
+		return net.IPv4(192, 168, 1, 200), nil
+	}
+
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+	offer := w.last()
+	if !assert.NotNil(t, offer) {
+		return
+	}
+	assert.Equal(t, net.IP{192, 168, 1, 200}, offer.GetYIAddr().To4())
+
+	// The offered address is acknowledged, though the pool doesn't lease it
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, offer.GetYIAddr())
+	req.SetIP(OptionDHCPServerID, net.IPv4(192, 168, 1, 1))
+	s.ServeDHCP(w, req)
+
+	ack := w.last()
+	assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+	assert.Equal(t, net.IP{192, 168, 1, 200}, ack.GetYIAddr().To4())
+	assert.Equal(t, []net.IP{{192, 168, 1, 10}, {192, 168, 1, 200}}, candidates)
+
+	_, ok := s.Pool.Lookup(req.ClientID())
+	assert.False(t, ok)
+
+	// Unless the hook vetoes it
+	s.AllocateFunc = func(*Packet, net.IP) (net.IP, error) { return nil, ErrAddressInUse }
+	s.ServeDHCP(w, req)
+	assert.Equal(t, MessageTypeNak, w.last().GetMessageType())
+
+	// Overrides outside the network are not offered
+	n := len(w.replies)
+	s.AllocateFunc = func(*Packet, net.IP) (net.IP, error) { return net.IPv4(10, 0, 0, 5), nil }
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, mac))
+	assert.Len(t, w.replies, n)
+}
+
+func TestSimpleServerRenewingWithRequestedIP(t *testing.T) {
+	s := testSimpleServer(t)
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	_, err := s.Pool.AllocateIP(req.ClientID(), net.IPv4(192, 168, 1, 10), time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The client renews its 'ciaddr', not the address of option 50
+	req.SetCIAddr(net.IPv4(192, 168, 1, 10))
+	req.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 20))
+	s.ServeDHCP(w, req)
+
+	ack := w.last()
+	if assert.NotNil(t, ack) {
+		assert.Equal(t, MessageTypeAck, ack.GetMessageType())
+		assert.Equal(t, net.IP{192, 168, 1, 10}, ack.GetYIAddr().To4())
+	}
+	_, ok := s.Pool.FindByIP(net.IPv4(192, 168, 1, 20))
+	assert.False(t, ok)
+}
+
+func TestSimpleServerNotAuthoritative(t *testing.T) {
+	s := testSimpleServer(t)
+	s.Authoritative = false
+	w := &testReplyRecorder{}
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+	// INIT-REBOOT client from another network is not replied to
+	req := testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, net.IPv4(10, 0, 0, 5))
+	s.ServeDHCP(w, req)
+	assert.Empty(t, w.replies)
+
+	// Neither is an unknown client requesting a leased address
+	_, err := s.Pool.AllocateIP([]byte("other"), net.IPv4(192, 168, 1, 10), time.Hour)
+	assert.NoError(t, err)
+
+	req = testSimpleRequest(MessageTypeRequest, mac)
+	req.SetIP(OptionAddressRequest, net.IPv4(192, 168, 1, 10))
+	s.ServeDHCP(w, req)
+	assert.Empty(t, w.replies)
+
+	// A known client gets a DHCPNAK
+	_, err = s.Pool.AllocateIP(req.ClientID(), net.IPv4(192, 168, 1, 11), time.Hour)
+	assert.NoError(t, err)
+
+	s.ServeDHCP(w, req)
+	if assert.Len(t, w.replies, 1) {
+		assert.Equal(t, MessageTypeNak, w.last().GetMessageType())
+	}
+}
+
+func TestSimpleServerReservedOptions(t *testing.T) {
+	s := testSimpleServer(t)
+	s.Options.SetString(OptionDomainName, "example.com")
+	s.Options.SetString(OptionBootfileName, "default.efi")
+
+	class := make(OptionMap)
+	class.SetString(OptionDomainName, "pxe.example.com")
+	class.SetString(OptionBootfileName, "pxe.efi")
+	s.Classifier = Classes{{Name: "pxe", VendorClass: "PXEClient"}}
+	s.Classes = map[string]ClassConfig{"pxe": {Options: class}}
+
+	host := make(OptionMap)
+	host.SetIP(OptionRouter, net.IPv4(192, 168, 1, 254))
+	host.SetString(OptionBootfileName, "host.efi")
+	host.SetString(OptionHostname, "host")
+
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	dis := testSimpleRequest(MessageTypeDiscover, mac)
+	dis.SetString(OptionClassID, "PXEClient")
+	dis.SetOption(OptionParameterList, []byte{byte(OptionRouter), byte(OptionDomainName), byte(OptionBootfileName)})
+	assert.NoError(t, s.Pool.Reserve(dis.ClientID(), net.IPv4(192, 168, 1, 50), WithOptions(host)))
+
+	w := &testReplyRecorder{}
+	s.ServeDHCP(w, dis)
+
+	// The reservation wins over the class, which wins over the server, and
+	// only requested options are included
+	offer := w.last()
+	if assert.NotNil(t, offer) {
+		assert.Equal(t, net.IPv4(192, 168, 1, 50).To4(), offer.GetYIAddr().To4())
+		ip, _ := offer.GetIP(OptionRouter)
+		assert.Equal(t, net.IPv4(192, 168, 1, 254), ip)
+		name, _ := offer.GetString(OptionDomainName)
+		assert.Equal(t, "pxe.example.com", name)
+		file, _ := offer.GetString(OptionBootfileName)
+		assert.Equal(t, "host.efi", file)
+		_, ok := offer.GetOption(OptionHostname)
+		assert.False(t, ok, "not requested")
+	}
+
+	// Other clients get the server's options
+	s.ServeDHCP(w, testSimpleRequest(MessageTypeDiscover, net.HardwareAddr{2, 0, 0, 0, 0, 2}))
+	if offer := w.last(); assert.NotNil(t, offer) {
+		ip, _ := offer.GetIP(OptionRouter)
+		assert.Equal(t, net.IPv4(192, 168, 1, 1), ip)
+	}
+}
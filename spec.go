@@ -0,0 +1,102 @@
+package dhcp4
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// PacketSpec describes a packet declaratively, for building test fixtures
+// with BuildPacket. Zero fields are left zero in the packet, except Op, which
+// defaults to BootRequest.
+type PacketSpec struct {
+	Op     OpCode
+	HType  uint8
+	Hops   uint8
+	XID    uint32
+	Secs   uint16
+	Flags  uint16
+	CIAddr net.IP
+	YIAddr net.IP
+	SIAddr net.IP
+	GIAddr net.IP
+
+	// CHAddr sets the hardware address and its length. If HType is zero and
+	// CHAddr is set, the hardware type is Ethernet (1).
+	CHAddr net.HardwareAddr
+
+	// Options of the packet. Values are not checked.
+	Options map[Option][]byte
+
+	// RawOptions, if not nil, replaces the encoded options field of the
+	// packet, after the cookie, for building malformed packets. It is only
+	// used by Bytes.
+	RawOptions []byte
+}
+
+// BuildPacket returns the packet described by s. It panics if an address in s
+// is not an IPv4 address, or the hardware address is longer than 16 octets.
+func BuildPacket(s PacketSpec) *Packet {
+	op := s.Op
+	if op == 0 {
+		op = BootRequest
+	}
+
+	p := NewPacket(op)
+
+	htype := s.HType
+	if htype == 0 && s.CHAddr != nil {
+		htype = 1
+	}
+
+	p.HType()[0] = htype
+	p.Hops()[0] = s.Hops
+	binary.BigEndian.PutUint32(p.XID(), s.XID)
+	binary.BigEndian.PutUint16(p.Secs(), s.Secs)
+	binary.BigEndian.PutUint16(p.Flags(), s.Flags)
+
+	must := func(err error) {
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if s.CHAddr != nil {
+		must(p.SetCHAddr(s.CHAddr))
+	}
+	for _, f := range []struct {
+		ip  net.IP
+		set func(net.IP) error
+	}{
+		{s.CIAddr, p.SetCIAddr},
+		{s.YIAddr, p.SetYIAddr},
+		{s.SIAddr, p.SetSIAddr},
+		{s.GIAddr, p.SetGIAddr},
+	} {
+		if f.ip != nil {
+			must(f.set(f.ip))
+		}
+	}
+
+	for o, v := range s.Options {
+		p.SetOption(o, v)
+	}
+
+	return &p
+}
+
+// Bytes returns the wire representation of the packet described by s. If
+// RawOptions is set, it follows the cookie verbatim; otherwise the options are
+// encoded like PacketToBytes does.
+func (s PacketSpec) Bytes() []byte {
+	p := BuildPacket(s)
+	if s.RawOptions != nil {
+		return append(append([]byte(nil), p.RawPacket[:240]...), s.RawOptions...)
+	}
+
+	b, err := PacketToBytes(*p, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
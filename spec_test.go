@@ -0,0 +1,51 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPacket(t *testing.T) {
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	p := BuildPacket(PacketSpec{
+		XID:    0x1234,
+		Flags:  0x8000,
+		CHAddr: mac,
+		GIAddr: net.IPv4(10, 0, 1, 1),
+		Options: map[Option][]byte{
+			OptionDHCPMsgType: {byte(MessageTypeDiscover)},
+		},
+	})
+
+	assert.Equal(t, BootRequest, OpCode(p.Op()[0]))
+	assert.Equal(t, uint8(1), p.GetHType())
+	assert.Equal(t, []byte{0, 0, 0x12, 0x34}, p.GetXID())
+	assert.Equal(t, []byte{0x80, 0}, p.GetFlags())
+	assert.Equal(t, mac, p.GetCHAddr())
+	assert.Equal(t, net.IP{10, 0, 1, 1}, p.GetGIAddr())
+	assert.Equal(t, MessageTypeDiscover, p.GetMessageType())
+
+	assert.Panics(t, func() { BuildPacket(PacketSpec{CIAddr: net.ParseIP("::1")}) })
+}
+
+func TestPacketSpecBytes(t *testing.T) {
+	spec := PacketSpec{
+		Op:      BootReply,
+		Options: map[Option][]byte{OptionDHCPMsgType: {byte(MessageTypeAck)}},
+	}
+
+	q, err := PacketFromBytes(spec.Bytes())
+	if assert.NoError(t, err) {
+		assert.Equal(t, MessageTypeAck, q.GetMessageType())
+	}
+
+	// Malformed options: truncated option without end tag
+	spec.RawOptions = []byte{byte(OptionDHCPMsgType), 4, 1}
+	b := spec.Bytes()
+	assert.Equal(t, spec.RawOptions, b[240:])
+
+	_, err = PacketFromBytes(b)
+	assert.Equal(t, ErrShortPacket, err)
+}
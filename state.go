@@ -0,0 +1,147 @@
+package dhcp4
+
+import "net"
+
+// State is the state of a client sending a DHCPREQUEST, as described in
+// RFC2131, section 4.3.2.
+type State int
+
+const (
+	StateUnknown State = iota
+
+	// StateSelecting is the state of a client requesting an offered address.
+	// It includes the Server Identifier and Requested IP Address options,
+	// and has no 'ciaddr'.
+	StateSelecting
+
+	// StateInitReboot is the state of a client verifying a previously
+	// allocated address after rebooting. It includes the Requested IP
+	// Address option, but not the Server Identifier option, and has no
+	// 'ciaddr'.
+	StateInitReboot
+
+	// StateRenewing is the state of a client extending its lease. It
+	// includes neither the Server Identifier nor the Requested IP Address
+	// option, and has its address in 'ciaddr'. A renewing client (unicast
+	// request) and a rebinding client (broadcast request) send the same
+	// packet, so this state covers both.
+	StateRenewing
+)
+
+var stateStrings = map[State]string{
+	StateUnknown:    "UNKNOWN",
+	StateSelecting:  "SELECTING",
+	StateInitReboot: "INIT-REBOOT",
+	StateRenewing:   "RENEWING",
+}
+
+func (s State) String() string {
+	return stateStrings[s]
+}
+
+// RequestedIP returns the address the client requests: the Requested IP
+// Address option if set, or else the 'ciaddr' field. A DHCPREQUEST classified
+// as StateRenewing with both (see ClassifyState) requests its 'ciaddr'. It
+// returns nil if the packet has neither.
+func (p *Packet) RequestedIP() net.IP {
+	if p.GetMessageType() == MessageTypeRequest && p.State() == StateRenewing {
+		return p.GetCIAddr()
+	}
+
+	if ip, ok := p.GetIP(OptionAddressRequest); ok {
+		return ip
+	}
+
+	if ip := p.GetCIAddr(); !ip.Equal(net.IPv4zero) {
+		return ip
+	}
+
+	return nil
+}
+
+// State returns the state of the client sending the DHCPREQUEST p. See
+// ClassifyState.
+func (p *Packet) State() State {
+	s, _ := p.ClassifyState()
+	return s
+}
+
+// ClassifyState returns the state of the client sending the DHCPREQUEST p,
+// and whether the request was ambiguous. Some clients include the Requested
+// IP Address option when renewing, which RFC2131 forbids. A request with
+// 'ciaddr' and the option, but without Server Identifier option, is
+// classified as StateRenewing, and reported as ambiguous, so that handlers
+// can log the non-compliance and still reply.
+func (p *Packet) ClassifyState() (s State, ambiguous bool) {
+	_, sid := p.GetOption(OptionDHCPServerID)
+	_, requested := p.GetOption(OptionAddressRequest)
+	ciaddr := !p.GetCIAddr().Equal(net.IPv4zero)
+
+	switch {
+	case sid && requested && !ciaddr:
+		return StateSelecting, false
+	case !sid && requested && !ciaddr:
+		return StateInitReboot, false
+	case !sid && !requested && ciaddr:
+		return StateRenewing, false
+	case !sid && requested && ciaddr:
+		return StateRenewing, true
+	}
+
+	return StateUnknown, false
+}
+
+// ServerID returns the Server Identifier option (54) of p.
+func (p *Packet) ServerID() (net.IP, bool) {
+	return p.GetIP(OptionDHCPServerID)
+}
+
+// IsForServer returns whether the DHCPREQUEST p is meant for a server with
+// identifiers serverIDs. A client in the SELECTING state identifies the server
+// it selected with the Server Identifier option; servers not selected must not
+// reply, and may release the address they offered (RFC2131, section 4.3.2).
+// Servers in an HA pair that share a virtual identifier pass both it and their
+// own identifier. Requests without the option, from clients in the
+// INIT-REBOOT, RENEWING or REBINDING state, are meant for any server. So are
+// requests identifying the address of a Server Identifier Override sub-option
+// added by the relay agent (see ServerIDOverride).
+func (p *Packet) IsForServer(serverIDs ...net.IP) bool {
+	sid, ok := p.ServerID()
+	if !ok {
+		return true
+	}
+
+	if ip, ok := p.ServerIDOverride(); ok && sid.Equal(ip) {
+		return true
+	}
+
+	for _, id := range serverIDs {
+		if sid.Equal(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ShouldNak tells how to reply to the DHCPREQUEST req, if its requested
+// address (see RequestedIP) is not in network pool. From RFC2131 section
+// 4.3.2, a client in the SELECTING or INIT-REBOOT state is sent a DHCPNAK, so
+// that it restarts in the INIT state. A client in the RENEWING or REBINDING
+// state is not replied to; it keeps its lease until it expires. Requests that
+// don't match any state are not replied to either.
+//
+// If the requested address is in pool, both nak and silent are false, and the
+// request can be handled as usual.
+func ShouldNak(req *Packet, pool *net.IPNet) (nak bool, silent bool) {
+	if ip := req.RequestedIP(); ip != nil && pool.Contains(ip) {
+		return false, false
+	}
+
+	switch req.State() {
+	case StateSelecting, StateInitReboot:
+		return true, false
+	}
+
+	return false, true
+}
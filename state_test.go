@@ -0,0 +1,132 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStateRequest(sid, requested, ciaddr net.IP) *Packet {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeRequest)
+	if sid != nil {
+		p.SetIP(OptionDHCPServerID, sid)
+	}
+	if requested != nil {
+		p.SetIP(OptionAddressRequest, requested)
+	}
+	if ciaddr != nil {
+		p.SetCIAddr(ciaddr)
+	}
+	return &p
+}
+
+func TestPacketState(t *testing.T) {
+	sid := net.IPv4(10, 0, 0, 1)
+	ip := net.IPv4(10, 0, 0, 5)
+
+	assert.Equal(t, StateSelecting, testStateRequest(sid, ip, nil).State())
+	assert.Equal(t, StateInitReboot, testStateRequest(nil, ip, nil).State())
+	assert.Equal(t, StateRenewing, testStateRequest(nil, nil, ip).State())
+	assert.Equal(t, StateUnknown, testStateRequest(sid, nil, ip).State())
+	assert.Equal(t, StateUnknown, testStateRequest(nil, nil, nil).State())
+}
+
+func TestPacketRequestedIP(t *testing.T) {
+	assert.Nil(t, testStateRequest(nil, nil, nil).RequestedIP())
+	assert.Equal(t, net.IPv4(10, 0, 0, 5), testStateRequest(nil, net.IPv4(10, 0, 0, 5), nil).RequestedIP())
+	assert.Equal(t, net.IP{10, 0, 0, 6}, testStateRequest(nil, nil, net.IPv4(10, 0, 0, 6)).RequestedIP())
+
+	// Renewing clients that include the option request their 'ciaddr'
+	assert.Equal(t, net.IP{10, 0, 0, 6}, testStateRequest(nil, net.IPv4(10, 0, 0, 5), net.IPv4(10, 0, 0, 6)).RequestedIP())
+}
+
+func TestShouldNak(t *testing.T) {
+	_, pool, _ := net.ParseCIDR("10.0.0.0/24")
+	sid := net.IPv4(10, 0, 0, 1)
+	in := net.IPv4(10, 0, 0, 5)
+	out := net.IPv4(10, 0, 1, 5)
+
+	testCases := []struct {
+		req         *Packet
+		nak, silent bool
+	}{
+		// Address in pool
+		{testStateRequest(sid, in, nil), false, false},
+		{testStateRequest(nil, in, nil), false, false},
+		{testStateRequest(nil, nil, in), false, false},
+
+		// Address outside pool
+		{testStateRequest(sid, out, nil), true, false},
+		{testStateRequest(nil, out, nil), true, false},
+		{testStateRequest(nil, nil, out), false, true},
+		{testStateRequest(nil, nil, nil), false, true},
+
+		// Renewing with the option: 'ciaddr' decides
+		{testStateRequest(nil, out, in), false, false},
+		{testStateRequest(nil, in, out), false, true},
+	}
+
+	for _, tc := range testCases {
+		nak, silent := ShouldNak(tc.req, pool)
+		assert.Equal(t, tc.nak, nak, "state %s", tc.req.State())
+		assert.Equal(t, tc.silent, silent, "state %s", tc.req.State())
+	}
+}
+
+func TestPacketIsForServer(t *testing.T) {
+	sid := net.IPv4(10, 0, 0, 1)
+	other := net.IPv4(10, 0, 0, 2)
+	ip := net.IPv4(10, 0, 0, 5)
+
+	assert.True(t, testStateRequest(sid, ip, nil).IsForServer(sid))
+	assert.False(t, testStateRequest(other, ip, nil).IsForServer(sid))
+	assert.True(t, testStateRequest(nil, ip, nil).IsForServer(sid))
+	assert.True(t, testStateRequest(nil, nil, ip).IsForServer(sid))
+}
+
+func ExamplePacket_IsForServer() {
+	serverID := net.IPv4(10, 0, 0, 1)
+
+	h := HandlerFunc(func(w ReplyWriter, p *Packet) {
+		// Don't reply to requests meant for another server, so that only the
+		// server selected by the client sends a DHCPACK
+		if p.GetMessageType() == MessageTypeRequest && !p.IsForServer(serverID) {
+			return
+		}
+
+		// ...
+	})
+
+	ListenAndServe(":67", h)
+}
+
+func TestPacketClassifyState(t *testing.T) {
+	sid := net.IPv4(10, 0, 0, 1)
+	ip := net.IPv4(10, 0, 0, 5)
+
+	s, ambiguous := testStateRequest(nil, nil, ip).ClassifyState()
+	assert.Equal(t, StateRenewing, s)
+	assert.False(t, ambiguous)
+
+	// Renewing with Requested IP Address option
+	s, ambiguous = testStateRequest(nil, ip, ip).ClassifyState()
+	assert.Equal(t, StateRenewing, s)
+	assert.True(t, ambiguous)
+
+	s, ambiguous = testStateRequest(sid, ip, ip).ClassifyState()
+	assert.Equal(t, StateUnknown, s)
+	assert.False(t, ambiguous)
+}
+
+func TestPacketIsForServerMultipleIDs(t *testing.T) {
+	vip := net.IPv4(10, 0, 0, 100)
+	own := net.IPv4(10, 0, 0, 1)
+	ip := net.IPv4(10, 0, 0, 5)
+
+	assert.True(t, testStateRequest(vip, ip, nil).IsForServer(vip, own))
+	assert.True(t, testStateRequest(own, ip, nil).IsForServer(vip, own))
+	assert.False(t, testStateRequest(net.IPv4(10, 0, 0, 2), ip, nil).IsForServer(vip, own))
+	assert.False(t, testStateRequest(vip, ip, nil).IsForServer())
+}
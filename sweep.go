@@ -0,0 +1,141 @@
+package dhcp4
+
+import (
+	"container/heap"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+var (
+	ErrSweeperRunning = errors.New("dhcp4: lease pool sweeper already running")
+)
+
+// expiry is an entry in the expiry heap of a LeasePool. Entries are not
+// removed when a lease is renewed or released; an entry is stale if the
+// address has no lease with the same expiry anymore.
+type expiry struct {
+	v  uint32
+	at time.Time
+}
+
+// expiryHeap is a min-heap of expiries, implementing heap.Interface.
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiry)) }
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// sweeper is the background goroutine of a LeasePool freeing expired leases.
+type sweeper struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// trackExpiry adds the lease of address v to the expiry heap, if the sweeper
+// is running. The caller must hold the lock.
+func (p *LeasePool) trackExpiry(v uint32, l *Lease) {
+	if p.sweeper == nil {
+		return
+	}
+
+	heap.Push(&p.expiries, expiry{v: v, at: l.Expiry})
+}
+
+// StartSweeper starts a goroutine that frees the expired leases of the pool
+// (see Sweep) every interval, plus a random delay of up to jitter, so that
+// the sweeps of several pools don't run in lockstep. It calls notify, if not
+// nil, with an ExpireEvent for every freed lease, from the goroutine. The
+// goroutine runs until Close is called. It returns ErrSweeperRunning if the
+// sweeper is already running.
+func (p *LeasePool) StartSweeper(interval, jitter time.Duration, notify func(Event)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sweeper != nil {
+		return ErrSweeperRunning
+	}
+
+	// Track the leases bound before the sweeper started
+	p.sweeper = &sweeper{stop: make(chan struct{}), done: make(chan struct{})}
+	p.expiries = p.expiries[:0]
+	for v, l := range p.leases {
+		p.expiries = append(p.expiries, expiry{v: v, at: l.Expiry})
+	}
+	heap.Init(&p.expiries)
+
+	go p.sweep(p.sweeper, interval, jitter, notify)
+	return nil
+}
+
+func (p *LeasePool) sweep(s *sweeper, interval, jitter time.Duration, notify func(Event)) {
+	defer close(s.done)
+
+	for {
+		d := interval
+		if jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(jitter)))
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case now := <-p.clock().After(d):
+			for _, l := range p.Sweep(now) {
+				if notify != nil {
+					notify(ExpireEvent{Lease: l})
+				}
+			}
+		}
+	}
+}
+
+// Sweep frees the leases that expired before now, including those of
+// declined addresses, and returns them. Expired leases are only tracked while
+// the sweeper is running (see StartSweeper); otherwise, Sweep does nothing.
+// Expired addresses are leased again without sweeping, but their leases stay
+// in the pool until the address is reused.
+func (p *LeasePool) Sweep(now time.Time) []Lease {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var expired []Lease
+	for len(p.expiries) > 0 && now.After(p.expiries[0].at) {
+		e := heap.Pop(&p.expiries).(expiry)
+
+		l, ok := p.leases[e.v]
+		if !ok || !l.Expiry.Equal(e.at) {
+			continue
+		}
+
+		p.drop(e.v)
+		expired = append(expired, *l)
+	}
+
+	return expired
+}
+
+// Close stops the sweeper, if it is running, and waits for it to return.
+func (p *LeasePool) Close() error {
+	p.mu.Lock()
+	s := p.sweeper
+	p.sweeper = nil
+	p.expiries = nil
+	p.mu.Unlock()
+
+	if s == nil {
+		return nil
+	}
+
+	close(s.stop)
+	<-s.done
+	return nil
+}
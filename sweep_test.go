@@ -0,0 +1,72 @@
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeasePoolSweep(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+
+	// Not tracked before the sweeper starts
+	_, err := p.Allocate([]byte("a"), nil, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, p.Sweep(time.Now().Add(time.Hour)))
+
+	assert.NoError(t, p.StartSweeper(time.Hour, 0, nil))
+	defer p.Close()
+	assert.Equal(t, ErrSweeperRunning, p.StartSweeper(time.Hour, 0, nil))
+
+	_, err = p.Allocate([]byte("b"), nil, 2*time.Minute)
+	assert.NoError(t, err)
+	_, err = p.Allocate([]byte("c"), nil, 2*time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, p.SetHardwareAddr([]byte("c"), net.HardwareAddr{1, 2, 3, 4, 5, 6}))
+
+	// Renewed
+	_, err = p.Allocate([]byte("b"), nil, time.Hour)
+	assert.NoError(t, err)
+
+	expired := p.Sweep(time.Now().Add(5 * time.Minute))
+	if assert.Len(t, expired, 2) {
+		assert.Equal(t, []byte("a"), expired[0].ClientID)
+		assert.Equal(t, []byte("c"), expired[1].ClientID)
+	}
+
+	_, ok := p.Lookup([]byte("a"))
+	assert.False(t, ok)
+	_, ok = p.FindByMAC(net.HardwareAddr{1, 2, 3, 4, 5, 6})
+	assert.False(t, ok)
+	_, ok = p.Lookup([]byte("b"))
+	assert.True(t, ok)
+}
+
+func TestLeasePoolSweeper(t *testing.T) {
+	p := testLeasePool(t, "10.0.0.0/24")
+
+	events := make(chan Event, 1)
+	assert.NoError(t, p.StartSweeper(time.Millisecond, time.Millisecond, func(e Event) { events <- e }))
+
+	l, err := p.Allocate([]byte("a"), nil, 0)
+	assert.NoError(t, err)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, ExpireEvent{Lease: l}, e)
+	case <-time.After(time.Second):
+		t.Error("no ExpireEvent")
+	}
+
+	assert.NoError(t, p.Close())
+	assert.NoError(t, p.Close())
+
+	// Stopped
+	_, err = p.Allocate([]byte("b"), nil, 0)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, ok := p.Lookup([]byte("b"))
+	assert.True(t, ok)
+}
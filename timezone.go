@@ -0,0 +1,218 @@
+package dhcp4
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidTZPosix = errors.New("dhcp4: invalid POSIX time zone string")
+	ErrInvalidTZName  = errors.New("dhcp4: unknown time zone database name")
+
+	ErrInvalidTimeOffset = errors.New("dhcp4: time offset out of range")
+)
+
+// GetTimeOffset gets the Time Offset option (2): the offset of the client's
+// subnet from UTC, in whole seconds, which is negative west of the prime
+// meridian (RFC2132, section 3.4). The option is deprecated in favor of the
+// time zone options 100 and 101 (RFC4833), which account for
+// daylight saving time, but old clients only support it.
+func (om OptionMap) GetTimeOffset() (time.Duration, bool) {
+	v, ok := om.GetInt32(OptionTimeOffset)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Duration(v) * time.Second, true
+}
+
+// SetTimeOffset sets the Time Offset option (2), truncated to whole seconds.
+// It returns ErrInvalidTimeOffset if the number of seconds doesn't fit in a
+// signed 32-bit integer. Servers may set it alongside options 100 and 101 for
+// clients that don't support them.
+func (om OptionMap) SetTimeOffset(d time.Duration) error {
+	s := d / time.Second
+	if s < math.MinInt32 || s > math.MaxInt32 {
+		return ErrInvalidTimeOffset
+	}
+
+	return om.SetInt32(OptionTimeOffset, int32(s))
+}
+
+// GetTZPosix gets the POSIX time zone string (option 100), e.g.
+// "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00".
+func (om OptionMap) GetTZPosix() (string, bool) {
+	return om.GetString(OptionPCode)
+}
+
+// SetTZPosix sets the POSIX time zone string (option 100). It returns
+// ErrInvalidTZPosix if the string isn't formatted as the TZ environment
+// variable specified in IEEE 1003.1.
+func (om OptionMap) SetTZPosix(tz string) error {
+	if !utf8.ValidString(tz) || !validTZPosix(tz) {
+		return ErrInvalidTZPosix
+	}
+
+	om.SetOption(OptionPCode, []byte(tz))
+	return nil
+}
+
+// GetTZName gets the time zone database name (option 101), e.g.
+// "Europe/Zurich".
+func (om OptionMap) GetTZName() (string, bool) {
+	return om.GetString(OptionTCode)
+}
+
+// SetTZName sets the time zone database name (option 101). It returns
+// ErrInvalidTZName if the name can't be loaded with time.LoadLocation.
+func (om OptionMap) SetTZName(name string) error {
+	// LoadLocation accepts "" and "Local", which don't name a zone
+	if name == "" || name == "Local" || !utf8.ValidString(name) {
+		return ErrInvalidTZName
+	}
+
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTZName
+	}
+
+	om.SetOption(OptionTCode, []byte(name))
+	return nil
+}
+
+// validTZPosix checks the format std offset [dst [offset] [,start[/time],end[/time]]].
+func validTZPosix(s string) bool {
+	var ok bool
+
+	if s, ok = tzName(s); !ok {
+		return false
+	}
+	if s, ok = tzOffset(s, 24); !ok {
+		return false
+	}
+	if s == "" {
+		return true
+	}
+
+	// Daylight saving time
+	if s, ok = tzName(s); !ok {
+		return false
+	}
+	if s != "" && s[0] != ',' {
+		if s, ok = tzOffset(s, 24); !ok {
+			return false
+		}
+	}
+	if s == "" {
+		return true
+	}
+
+	// Rule for start and end of daylight saving time
+	for i := 0; i < 2; i++ {
+		if s == "" || s[0] != ',' {
+			return false
+		}
+		if s, ok = tzDate(s[1:]); !ok {
+			return false
+		}
+		if s != "" && s[0] == '/' {
+			// Times may extend up to 167 hours (RFC8536 section 3.3.1)
+			if s, ok = tzOffset(s[1:], 167); !ok {
+				return false
+			}
+		}
+	}
+
+	return s == ""
+}
+
+// tzName consumes a time zone abbreviation of at least 3 characters, which is
+// either alphabetic or quoted in angle brackets.
+func tzName(s string) (string, bool) {
+	if strings.HasPrefix(s, "<") {
+		i := strings.IndexByte(s, '>')
+		if i < 4 {
+			return s, false
+		}
+		for _, c := range s[1:i] {
+			if !(isAlpha(c) || isDigit(c) || c == '+' || c == '-') {
+				return s, false
+			}
+		}
+		return s[i+1:], true
+	}
+
+	i := 0
+	for i < len(s) && isAlpha(rune(s[i])) {
+		i++
+	}
+	if i < 3 {
+		return s, false
+	}
+	return s[i:], true
+}
+
+// tzOffset consumes [+-]hh[:mm[:ss]] with hours up to max.
+func tzOffset(s string, max int) (string, bool) {
+	var ok bool
+	var n int
+
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if s, n, ok = tzNumber(s); !ok || n > max {
+		return s, false
+	}
+	for i := 0; i < 2 && s != "" && s[0] == ':'; i++ {
+		if s, n, ok = tzNumber(s[1:]); !ok || n > 59 {
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// tzDate consumes Jn, n or Mm.w.d.
+func tzDate(s string) (string, bool) {
+	var ok bool
+	var n int
+
+	switch {
+	case strings.HasPrefix(s, "J"):
+		if s, n, ok = tzNumber(s[1:]); !ok || n < 1 || n > 365 {
+			return s, false
+		}
+	case strings.HasPrefix(s, "M"):
+		s = s[1:]
+		for i, max := range []int{12, 5, 6} {
+			if i > 0 {
+				if s == "" || s[0] != '.' {
+					return s, false
+				}
+				s = s[1:]
+			}
+			if s, n, ok = tzNumber(s); !ok || n > max || (i < 2 && n < 1) {
+				return s, false
+			}
+		}
+	default:
+		if s, n, ok = tzNumber(s); !ok || n > 365 {
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// tzNumber consumes a decimal number of up to 3 digits.
+func tzNumber(s string) (string, int, bool) {
+	i, n := 0, 0
+	for i < len(s) && i < 3 && isDigit(rune(s[i])) {
+		n = n*10 + int(s[i]-'0')
+		i++
+	}
+	return s[i:], n, i > 0
+}
+
+func isAlpha(c rune) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c rune) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,105 @@
+package dhcp4
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTZPosix(t *testing.T) {
+	valid := []string{
+		"UTC0",
+		"EST5EDT",
+		"EST5EDT4,M3.2.0/02:00,M11.1.0/02:00",
+		"CET-1CEST,M3.5.0,M10.5.0/3",
+		"<+0330>-3:30",
+		"NZST-12NZDT,J270/2,J95/3",
+		"WART4WARST,0/0,365/25",
+		"EST5EDT,M3.2.0/-1,M11.1.0/167",
+	}
+
+	for _, tz := range valid {
+		om := make(OptionMap)
+		if assert.NoError(t, om.SetTZPosix(tz), "expected %q to be valid", tz) {
+			v, ok := om.GetTZPosix()
+			assert.True(t, ok)
+			assert.Equal(t, tz, v)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"EST",
+		"ES5",
+		"EST25",
+		"EST5EDT,M3.2.0",
+		"EST5EDT,M13.2.0,M11.1.0",
+		"EST5EDT,M3.6.0,M11.1.0",
+		"EST5EDT,M3.2.7,M11.1.0",
+		"EST5EDT,J0,J100",
+		"EST5EDT,M3.2.0,M11.1.0,",
+		"<ES>5",
+		"Europe/Zurich",
+		"EST5\xff",
+	}
+
+	for _, tz := range invalid {
+		om := make(OptionMap)
+		assert.Equal(t, ErrInvalidTZPosix, om.SetTZPosix(tz), "expected %q to be invalid", tz)
+		_, ok := om.GetTZPosix()
+		assert.False(t, ok)
+	}
+}
+
+func TestSetTZName(t *testing.T) {
+	om := make(OptionMap)
+	if assert.NoError(t, om.SetTZName("UTC")) {
+		v, ok := om.GetTZName()
+		assert.True(t, ok)
+		assert.Equal(t, "UTC", v)
+	}
+
+	for _, name := range []string{"", "Local", "Nowhere/Special", "EST5EDT4,M3.2.0,M11.1.0"} {
+		om := make(OptionMap)
+		assert.Equal(t, ErrInvalidTZName, om.SetTZName(name), "expected %q to be invalid", name)
+		_, ok := om.GetTZName()
+		assert.False(t, ok)
+	}
+}
+
+func TestTimeOffset(t *testing.T) {
+	om := make(OptionMap)
+	_, ok := om.GetTimeOffset()
+	assert.False(t, ok)
+
+	// West of the prime meridian, e.g. EST
+	assert.NoError(t, om.SetTimeOffset(-5*time.Hour))
+	v, _ := om.GetOption(OptionTimeOffset)
+	assert.Equal(t, []byte{0xff, 0xff, 0xb9, 0xb0}, v)
+	d, ok := om.GetTimeOffset()
+	assert.True(t, ok)
+	assert.Equal(t, -5*time.Hour, d)
+
+	// East, truncated to seconds
+	assert.NoError(t, om.SetTimeOffset(5*time.Hour+30*time.Minute+time.Millisecond))
+	v, _ = om.GetOption(OptionTimeOffset)
+	assert.Equal(t, []byte{0x00, 0x00, 0x4d, 0x58}, v)
+	d, _ = om.GetTimeOffset()
+	assert.Equal(t, 5*time.Hour+30*time.Minute, d)
+
+	assert.NoError(t, om.SetTimeOffset(math.MinInt32*time.Second))
+	d, _ = om.GetTimeOffset()
+	assert.Equal(t, math.MinInt32*time.Second, d)
+
+	assert.Equal(t, ErrInvalidTimeOffset, om.SetTimeOffset((math.MaxInt32+1)*time.Second))
+	assert.Equal(t, ErrInvalidTimeOffset, om.SetTimeOffset((math.MinInt32-1)*time.Second))
+	d, _ = om.GetTimeOffset()
+	assert.Equal(t, math.MinInt32*time.Second, d)
+
+	// Of the wrong length
+	om.SetOption(OptionTimeOffset, []byte{0xff, 0xff})
+	_, ok = om.GetTimeOffset()
+	assert.False(t, ok)
+}
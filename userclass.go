@@ -0,0 +1,40 @@
+package dhcp4
+
+// GetUserClasses gets the user classes of the User Class option (77).
+//
+// RFC3004 defines the option as a list of instances, each prefixed with its
+// length. Some clients, such as iPXE, instead send a single string without
+// length prefix. The value is parsed as a list if the lengths of its instances
+// add up to the length of the option, and as a single string otherwise.
+func (om OptionMap) GetUserClasses() ([]string, bool) {
+	v, ok := om.GetOption(OptionUserClass)
+	if !ok || len(v) == 0 {
+		return nil, false
+	}
+
+	var classes []string
+	for b := v; len(b) > 0; {
+		n := int(b[0])
+		if n == 0 || len(b) < 1+n {
+			return []string{string(v)}, true
+		}
+
+		classes = append(classes, string(b[1:1+n]))
+		b = b[1+n:]
+	}
+
+	return classes, true
+}
+
+// UserClassKey returns the first user class of the User Class option of msg,
+// for handlers that serve clients differently by user class, e.g. a boot
+// server chainloading iPXE for clients that aren't iPXE yet (user class
+// "iPXE"). See GetUserClasses for the formats of the option.
+func UserClassKey(msg *Packet) (string, bool) {
+	classes, ok := msg.GetUserClasses()
+	if !ok {
+		return "", false
+	}
+
+	return classes[0], true
+}
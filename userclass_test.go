@@ -0,0 +1,44 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserClasses(t *testing.T) {
+	testCases := []struct {
+		v       []byte
+		classes []string
+	}{
+		// RFC3004 list
+		{[]byte{4, 'i', 'P', 'X', 'E'}, []string{"iPXE"}},
+		{[]byte{1, 'a', 2, 'b', 'c'}, []string{"a", "bc"}},
+
+		// Single string
+		{[]byte("iPXE"), []string{"iPXE"}},
+		{[]byte("gPXE"), []string{"gPXE"}},
+		{[]byte{3, 'a', 'b'}, []string{"\x03ab"}},
+	}
+
+	for _, tc := range testCases {
+		om := make(OptionMap)
+		om.SetOption(OptionUserClass, tc.v)
+
+		classes, ok := om.GetUserClasses()
+		assert.True(t, ok)
+		assert.Equal(t, tc.classes, classes)
+	}
+}
+
+func TestUserClassKey(t *testing.T) {
+	p := NewPacket(BootRequest)
+
+	_, ok := UserClassKey(&p)
+	assert.False(t, ok)
+
+	p.SetOption(OptionUserClass, []byte{4, 'i', 'P', 'X', 'E', 3, 'f', 'o', 'o'})
+	key, ok := UserClassKey(&p)
+	assert.True(t, ok)
+	assert.Equal(t, "iPXE", key)
+}
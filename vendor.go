@@ -0,0 +1,92 @@
+package dhcp4
+
+import "strings"
+
+// VendorFormat is the format of the Vendor Specific Information option (43)
+// of a vendor. RFC2132, section 8.4, leaves it to the vendor, identified by
+// the vendor class identifier (option 60), whether the option is opaque or
+// encapsulates sub-options in the format of the options field.
+type VendorFormat int
+
+const (
+	// VendorFormatOpaque is an option of vendor-defined bytes.
+	VendorFormatOpaque VendorFormat = iota
+
+	// VendorFormatEncapsulated is an option of encapsulated sub-options,
+	// with pad and end octets like the options field.
+	VendorFormatEncapsulated
+)
+
+// vendorFormats are the formats of option 43 by prefix of the vendor class
+// identifier.
+var vendorFormats = map[string]VendorFormat{
+	"PXEClient": VendorFormatEncapsulated, // PXE 2.1
+	"MSFT":      VendorFormatEncapsulated, // Microsoft DHCP clients
+}
+
+// RegisterVendorFormat registers the format of option 43 of the vendors whose
+// vendor class identifier starts with prefix, overriding any format already
+// registered for the prefix. The longest registered prefix of a vendor class
+// identifier applies. Like RegisterOptionKind, this should be called before
+// packets are handled.
+func RegisterVendorFormat(prefix string, f VendorFormat) {
+	vendorFormats[prefix] = f
+}
+
+// LookupVendorFormat returns the format of option 43 registered for the
+// vendor with vendor class identifier vendorClass, if any.
+func LookupVendorFormat(vendorClass string) (VendorFormat, bool) {
+	var (
+		format VendorFormat
+		best   = -1
+	)
+
+	for prefix, f := range vendorFormats {
+		if len(prefix) > best && strings.HasPrefix(vendorClass, prefix) {
+			format, best = f, len(prefix)
+		}
+	}
+
+	return format, best >= 0
+}
+
+// VendorOptions is the Vendor Specific Information option (43), decoded by
+// GetVendorOptionsFor.
+type VendorOptions struct {
+	Format VendorFormat
+
+	// Raw is the value of the option.
+	Raw []byte
+
+	// Options are the sub-options, if Format is VendorFormatEncapsulated.
+	Options OptionMap
+}
+
+// GetVendorOptionsFor gets the Vendor Specific Information option (43) in
+// the format registered for the vendor with vendor class identifier
+// vendorClass, usually the Class Identifier option (60) of the client (see
+// RegisterVendorFormat). The option is opaque for vendors without registered
+// format, leaving its interpretation to the caller, as parsing opaque bytes
+// as sub-options gives garbage. Raw is nil if the option is not set.
+func (om OptionMap) GetVendorOptionsFor(vendorClass string) (VendorOptions, error) {
+	format, _ := LookupVendorFormat(vendorClass)
+	vo := VendorOptions{Format: format}
+
+	v, ok := om.GetOption(OptionVendorSpecific)
+	if !ok {
+		return vo, nil
+	}
+
+	vo.Raw = v
+	if format != VendorFormatEncapsulated {
+		return vo, nil
+	}
+
+	vo.Options = make(OptionMap)
+	opts := OptionMapDeserializeOptions{IgnoreMissingEndTag: true}
+	if err := vo.Options.Deserialize(v, &opts); err != nil {
+		return VendorOptions{}, err
+	}
+
+	return vo, nil
+}
@@ -0,0 +1,59 @@
+package dhcp4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVendorOptionsFor(t *testing.T) {
+	om := make(OptionMap)
+	om.SetOption(OptionVendorSpecific, []byte{byte(PXEDiscoveryControl), 1, 3, 255})
+
+	// Encapsulated for known vendors
+	vo, err := om.GetVendorOptionsFor("PXEClient:Arch:00007:UNDI:003016")
+	assert.NoError(t, err)
+	assert.Equal(t, VendorFormatEncapsulated, vo.Format)
+	assert.Equal(t, []byte{byte(PXEDiscoveryControl), 1, 3, 255}, vo.Raw)
+	v, ok := vo.Options.GetUint8(PXEDiscoveryControl)
+	assert.True(t, ok)
+	assert.Equal(t, uint8(3), v)
+
+	// Opaque for unknown vendors
+	vo, err = om.GetVendorOptionsFor("acme-phone")
+	assert.NoError(t, err)
+	assert.Equal(t, VendorFormatOpaque, vo.Format)
+	assert.Equal(t, []byte{byte(PXEDiscoveryControl), 1, 3, 255}, vo.Raw)
+	assert.Nil(t, vo.Options)
+
+	// Invalid sub-options
+	om.SetOption(OptionVendorSpecific, []byte{1, 4, 0})
+	_, err = om.GetVendorOptionsFor("MSFT 5.0")
+	assert.Error(t, err)
+
+	// Not set
+	vo, err = make(OptionMap).GetVendorOptionsFor("MSFT 5.0")
+	assert.NoError(t, err)
+	assert.Nil(t, vo.Raw)
+}
+
+func TestRegisterVendorFormat(t *testing.T) {
+	defer delete(vendorFormats, "acme")
+	defer delete(vendorFormats, "acme-phone")
+
+	_, ok := LookupVendorFormat("acme-phone-2")
+	assert.False(t, ok)
+
+	RegisterVendorFormat("acme", VendorFormatEncapsulated)
+	f, ok := LookupVendorFormat("acme-phone-2")
+	assert.True(t, ok)
+	assert.Equal(t, VendorFormatEncapsulated, f)
+
+	// The longest prefix applies
+	RegisterVendorFormat("acme-phone", VendorFormatOpaque)
+	f, ok = LookupVendorFormat("acme-phone-2")
+	assert.True(t, ok)
+	assert.Equal(t, VendorFormatOpaque, f)
+	f, _ = LookupVendorFormat("acme-router")
+	assert.Equal(t, VendorFormatEncapsulated, f)
+}